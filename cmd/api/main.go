@@ -14,6 +14,7 @@ import (
 
 	"github.com/provideplatform/ident/common"
 	"github.com/provideplatform/ident/token"
+	vaultcrypto "github.com/provideplatform/vault/crypto"
 	"github.com/provideplatform/vault/vault"
 
 	provide "github.com/provideplatform/provide-go/common"
@@ -82,6 +83,9 @@ func main() {
 
 	common.Log.Debug("exiting vault API")
 	cancelF()
+
+	// destroy the unsealer key and any other key material held in guarded memory
+	vaultcrypto.PurgeSecureMemory()
 }
 
 func installSignalHandlers() {
@@ -105,6 +109,8 @@ func runAPI() {
 	r.Use(provide.CORSMiddleware())
 
 	r.GET("/status", statusHandler)
+	r.GET("/livez", livenessHandler)
+	r.GET("/readyz", readinessHandler)
 
 	r.Use(token.AuthMiddleware())
 	r.Use(common.AccountingMiddleware())
@@ -118,6 +124,9 @@ func runAPI() {
 		common.Log.Warningf("error automatically unsealing vault; %s", err.Error())
 	}
 
+	go vault.RunClusterSync(shutdownCtx)
+	go vault.RunMasterKeyRewrap(shutdownCtx)
+
 	srv = &http.Server{
 		Addr:    util.ListenAddr,
 		Handler: r,
@@ -136,6 +145,28 @@ func statusHandler(c *gin.Context) {
 	provide.Render(nil, 204, c)
 }
 
+// livenessHandler reports the health of the vault instance; the instance is live
+// for as long as it is able to serve requests, regardless of its seal state
+func livenessHandler(c *gin.Context) {
+	provide.Render(vault.GetHealthStatus(), 200, c)
+}
+
+// readinessHandler reports the health of the vault instance; the instance is not
+// ready while sealed, shutting down, or if the database or redis is unreachable
+func readinessHandler(c *gin.Context) {
+	status := vault.GetHealthStatus()
+	if shuttingDown() {
+		status.Ready = false
+	}
+
+	if !status.Ready {
+		provide.Render(status, 503, c)
+		return
+	}
+
+	provide.Render(status, 200, c)
+}
+
 func shuttingDown() bool {
 	return (atomic.LoadUint32(&closing) > 0)
 }
@@ -0,0 +1,24 @@
+package main
+
+import (
+	dbconf "github.com/kthomas/go-db-config"
+	"github.com/provideplatform/vault/common"
+	"github.com/provideplatform/vault/vault"
+)
+
+// main re-encodes the key material of RSA keys stored in the legacy JSON encoding as
+// DER-encoded PKCS#8 private and PKIX public keys; the vault is unsealed using the
+// configured seal/unseal provider
+func main() {
+	err := vault.AutoUnseal()
+	if err != nil {
+		common.Log.Panicf("RSA key material migration failed; error automatically unsealing vault; %s", err.Error())
+	}
+
+	migrated, err := vault.MigrateRSAKeyMaterial(dbconf.DatabaseConnection())
+	if err != nil {
+		common.Log.Panicf("RSA key material migration failed after migrating %d key(s) and key version(s); %s", migrated, err.Error())
+	}
+
+	common.Log.Infof("migrated RSA key material of %d key(s) and key version(s)", migrated)
+}
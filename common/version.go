@@ -0,0 +1,14 @@
+package common
+
+// Version, BuildCommit and BuildDate identify the running build; these are set at
+// build time using i.e. -ldflags "-X github.com/provideplatform/vault/common.Version=..."
+var (
+	// Version is the version of the running build
+	Version = "dev"
+
+	// BuildCommit is the git commit from which the running build was built
+	BuildCommit = ""
+
+	// BuildDate is the date on which the running build was built
+	BuildDate = ""
+)
@@ -36,6 +36,12 @@ func CreateAES256GCMSeed() ([]byte, error) {
 // if nonce is nil, a random nonce is generated
 // never use more than 2^32 random nonces with a given key because of the risk of a repeat.
 func (k *AES256GCM) Encrypt(plaintext []byte, nonce []byte) ([]byte, error) {
+	return k.EncryptWithAAD(plaintext, nonce, nil)
+}
+
+// EncryptWithAAD encrypts byte array using AES256GCM key, authenticating the given
+// additional data, which must be provided to decrypt the ciphertext; aad is optional
+func (k *AES256GCM) EncryptWithAAD(plaintext, nonce, aad []byte) ([]byte, error) {
 
 	block, err := aes.NewCipher(k.PrivateKey)
 	if err != nil {
@@ -66,7 +72,7 @@ func (k *AES256GCM) Encrypt(plaintext []byte, nonce []byte) ([]byte, error) {
 		return nil, ErrCannotEncrypt
 	}
 
-	ciphertext := aesgcm.Seal(nil, nonce, plaintext, nil)
+	ciphertext := aesgcm.Seal(nil, nonce, plaintext, aad)
 	//append the nonce to the ciphertext
 	ciphertext = append(nonce[:], ciphertext[:]...)
 
@@ -75,6 +81,12 @@ func (k *AES256GCM) Encrypt(plaintext []byte, nonce []byte) ([]byte, error) {
 
 // Decrypt decrypts byte array using AES256GCM key and input nonce
 func (k *AES256GCM) Decrypt(ciphertext []byte, nonce []byte) ([]byte, error) {
+	return k.DecryptWithAAD(ciphertext, nonce, nil)
+}
+
+// DecryptWithAAD decrypts byte array using AES256GCM key and input nonce; decryption
+// fails unless the additional data matches the additional data given on encryption
+func (k *AES256GCM) DecryptWithAAD(ciphertext, nonce, aad []byte) ([]byte, error) {
 
 	block, err := aes.NewCipher(k.PrivateKey)
 	if err != nil {
@@ -86,7 +98,7 @@ func (k *AES256GCM) Decrypt(ciphertext []byte, nonce []byte) ([]byte, error) {
 		return nil, ErrCannotDecrypt
 	}
 
-	plaintext, err := aesgcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := aesgcm.Open(nil, nonce, ciphertext, aad)
 	if err != nil {
 		return nil, ErrCannotDecrypt
 	}
@@ -94,8 +106,8 @@ func (k *AES256GCM) Decrypt(ciphertext []byte, nonce []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
-// Wipe will randomize the contents of the seed key
+// Wipe will zero the contents of the seed key
 func (k *AES256GCM) Wipe() {
-	io.ReadFull(rand.Reader, k.PrivateKey)
+	WipeBytes(k.PrivateKey)
 	k.PrivateKey = nil
 }
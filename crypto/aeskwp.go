@@ -0,0 +1,108 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+)
+
+// aesKWPSemiblockSize is the size of a key wrap semiblock in bytes
+const aesKWPSemiblockSize = 8
+
+// aesKWPAIV is the alternative initial value of AES key wrap with padding, which is followed
+// by the 32-bit message length indicator, per RFC 5649 section 3
+var aesKWPAIV = []byte{0xa6, 0x59, 0x59, 0xa6}
+
+// AESKeyWrapPad wraps the given key material of any length using the AES key encryption key,
+// per RFC 5649
+func AESKeyWrapPad(kek, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	if len(plaintext) == 0 || uint64(len(plaintext)) > 0xffffffff {
+		return nil, ErrCannotEncrypt
+	}
+
+	n := (len(plaintext) + aesKWPSemiblockSize - 1) / aesKWPSemiblockSize
+	out := make([]byte, aesKWPSemiblockSize*(n+1))
+	copy(out, aesKWPAIV)
+	binary.BigEndian.PutUint32(out[4:aesKWPSemiblockSize], uint32(len(plaintext)))
+	copy(out[aesKWPSemiblockSize:], plaintext)
+
+	if n == 1 {
+		block.Encrypt(out, out)
+		return out, nil
+	}
+
+	b := make([]byte, aes.BlockSize)
+	for j := 0; j <= 5; j++ {
+		for i := 1; i <= n; i++ {
+			copy(b, out[:aesKWPSemiblockSize])
+			copy(b[aesKWPSemiblockSize:], out[i*aesKWPSemiblockSize:(i+1)*aesKWPSemiblockSize])
+			block.Encrypt(b, b)
+
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out[:aesKWPSemiblockSize], binary.BigEndian.Uint64(b[:aesKWPSemiblockSize])^t)
+			copy(out[i*aesKWPSemiblockSize:], b[aesKWPSemiblockSize:])
+		}
+	}
+
+	return out, nil
+}
+
+// AESKeyUnwrapPad unwraps the given wrapped key material using the AES key encryption key,
+// verifying the integrity check and padding, per RFC 5649
+func AESKeyUnwrapPad(kek, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	if len(ciphertext) < aes.BlockSize || len(ciphertext)%aesKWPSemiblockSize != 0 {
+		return nil, ErrCannotDecrypt
+	}
+
+	n := len(ciphertext)/aesKWPSemiblockSize - 1
+	out := make([]byte, len(ciphertext))
+	copy(out, ciphertext)
+
+	if n == 1 {
+		block.Decrypt(out, out)
+	} else {
+		b := make([]byte, aes.BlockSize)
+		for j := 5; j >= 0; j-- {
+			for i := n; i >= 1; i-- {
+				t := uint64(n*j + i)
+				binary.BigEndian.PutUint64(b[:aesKWPSemiblockSize], binary.BigEndian.Uint64(out[:aesKWPSemiblockSize])^t)
+				copy(b[aesKWPSemiblockSize:], out[i*aesKWPSemiblockSize:(i+1)*aesKWPSemiblockSize])
+				block.Decrypt(b, b)
+
+				copy(out[:aesKWPSemiblockSize], b[:aesKWPSemiblockSize])
+				copy(out[i*aesKWPSemiblockSize:], b[aesKWPSemiblockSize:])
+			}
+		}
+	}
+
+	mli := int(binary.BigEndian.Uint32(out[4:aesKWPSemiblockSize]))
+	valid := subtle.ConstantTimeCompare(out[:4], aesKWPAIV) == 1
+	valid = valid && mli > aesKWPSemiblockSize*(n-1) && mli <= aesKWPSemiblockSize*n
+
+	if valid {
+		// the padding must be zero
+		padding := out[aesKWPSemiblockSize+mli:]
+		valid = subtle.ConstantTimeCompare(padding, make([]byte, len(padding))) == 1
+	}
+
+	if !valid {
+		WipeBytes(out)
+		return nil, ErrCannotDecrypt
+	}
+
+	plaintext := make([]byte, mli)
+	copy(plaintext, out[aesKWPSemiblockSize:aesKWPSemiblockSize+mli])
+	WipeBytes(out)
+
+	return plaintext, nil
+}
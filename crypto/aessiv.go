@@ -0,0 +1,265 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"io"
+)
+
+// AESSIVKeySize is the size of an AES-256-SIV key in bytes; the first half of the key is
+// used to compute the synthetic iv (S2V) and the second half is used for AES-CTR encryption
+const AESSIVKeySize = 64
+
+// NonceSizeAESSIV is the size of the random nonce used in non-deterministic AES-SIV operations
+const NonceSizeAESSIV = 16
+
+// aesSIVBlockSize is the AES block size, which is also the size of the synthetic iv
+const aesSIVBlockSize = aes.BlockSize
+
+// aesSIVMaxComponents is the maximum number of associated data components, per RFC 5297
+const aesSIVMaxComponents = 126
+
+// AES256SIV is the internal struct for an AES-SIV (RFC 5297) key; identical plaintext and
+// associated data always produce identical ciphertext when encrypted deterministically
+type AES256SIV struct {
+	PrivateKey []byte
+}
+
+// CreateAES256SIVKey creates a random key for a new AES-256-SIV key
+func CreateAES256SIVKey() ([]byte, error) {
+	key := make([]byte, AESSIVKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, ErrCannotGenerateKey
+	}
+
+	return key, nil
+}
+
+// Encrypt encrypts byte array using AES-SIV key, using a random nonce as an additional
+// associated data component such that the ciphertext is not deterministic; nonce is optional
+// and a random nonce is generated if nil; the nonce is prepended to the ciphertext
+func (k *AES256SIV) Encrypt(plaintext, nonce, aad []byte) ([]byte, error) {
+	if nonce == nil {
+		nonce = make([]byte, NonceSizeAESSIV)
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, ErrCannotGenerateNonce
+		}
+	}
+
+	if len(nonce) > NonceSizeAESSIV {
+		return nil, ErrNonceTooLong
+	}
+
+	if len(nonce) < NonceSizeAESSIV {
+		return nil, ErrNonceTooShort
+	}
+
+	ciphertext, err := k.seal(plaintext, append(aesSIVComponents(aad), nonce)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(nonce[:], ciphertext...), nil
+}
+
+// Decrypt decrypts byte array using AES-SIV key and input nonce
+func (k *AES256SIV) Decrypt(ciphertext, nonce, aad []byte) ([]byte, error) {
+	return k.open(ciphertext, append(aesSIVComponents(aad), nonce)...)
+}
+
+// EncryptDeterministic encrypts byte array using AES-SIV key without a nonce; the
+// returned synthetic iv and ciphertext are identical for identical plaintext and aad
+func (k *AES256SIV) EncryptDeterministic(plaintext, aad []byte) ([]byte, error) {
+	return k.seal(plaintext, aesSIVComponents(aad)...)
+}
+
+// DecryptDeterministic decrypts byte array which was deterministically encrypted using AES-SIV key
+func (k *AES256SIV) DecryptDeterministic(ciphertext, aad []byte) ([]byte, error) {
+	return k.open(ciphertext, aesSIVComponents(aad)...)
+}
+
+// Wipe will zero the contents of the private key
+func (k *AES256SIV) Wipe() {
+	WipeBytes(k.PrivateKey)
+	k.PrivateKey = nil
+}
+
+// keys returns the S2V and CTR keys, i.e., the first and second half of the private key;
+// AES-128-SIV and AES-192-SIV keys are accepted in addition to AES-256-SIV keys
+func (k *AES256SIV) keys() ([]byte, []byte, error) {
+	switch len(k.PrivateKey) {
+	case 32, 48, AESSIVKeySize:
+		return k.PrivateKey[:len(k.PrivateKey)/2], k.PrivateKey[len(k.PrivateKey)/2:], nil
+	}
+	return nil, nil, ErrInvalidKey
+}
+
+// aesSIVComponents returns the associated data components for the optional aad
+func aesSIVComponents(aad []byte) [][]byte {
+	if len(aad) == 0 {
+		return [][]byte{}
+	}
+	return [][]byte{aad}
+}
+
+// seal returns the synthetic iv followed by the ciphertext, per RFC 5297 section 2.6
+func (k *AES256SIV) seal(plaintext []byte, ad ...[]byte) ([]byte, error) {
+	macKey, ctrKey, err := k.keys()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ad) > aesSIVMaxComponents {
+		return nil, ErrCannotEncrypt
+	}
+
+	v, err := aesSIVS2V(macKey, append(ad, plaintext)...)
+	if err != nil {
+		return nil, ErrCannotEncrypt
+	}
+
+	ciphertext, err := aesSIVCTR(ctrKey, v, plaintext)
+	if err != nil {
+		return nil, ErrCannotEncrypt
+	}
+
+	return append(v, ciphertext...), nil
+}
+
+// open verifies the synthetic iv and returns the plaintext, per RFC 5297 section 2.7
+func (k *AES256SIV) open(ciphertext []byte, ad ...[]byte) ([]byte, error) {
+	macKey, ctrKey, err := k.keys()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aesSIVBlockSize || len(ad) > aesSIVMaxComponents {
+		return nil, ErrCannotDecrypt
+	}
+
+	v := ciphertext[:aesSIVBlockSize]
+	plaintext, err := aesSIVCTR(ctrKey, v, ciphertext[aesSIVBlockSize:])
+	if err != nil {
+		return nil, ErrCannotDecrypt
+	}
+
+	t, err := aesSIVS2V(macKey, append(ad, plaintext)...)
+	if err != nil || subtle.ConstantTimeCompare(t, v) != 1 {
+		WipeBytes(plaintext)
+		return nil, ErrCannotDecrypt
+	}
+
+	return plaintext, nil
+}
+
+// aesSIVCTR encrypts or decrypts the input using AES-CTR with the counter derived from the
+// synthetic iv by clearing the 31st and 63rd bits (from the right), per RFC 5297 section 2.5
+func aesSIVCTR(key, v, in []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	q := make([]byte, aesSIVBlockSize)
+	copy(q, v)
+	q[8] &= 0x7f
+	q[12] &= 0x7f
+
+	out := make([]byte, len(in))
+	cipher.NewCTR(block, q).XORKeyStream(out, in)
+	return out, nil
+}
+
+// aesSIVS2V computes the synthetic iv of the given strings, per RFC 5297 section 2.4
+func aesSIVS2V(key []byte, s ...[]byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s) == 0 {
+		one := make([]byte, aesSIVBlockSize)
+		one[aesSIVBlockSize-1] = 0x01
+		return aesCMAC(block, one), nil
+	}
+
+	d := aesCMAC(block, make([]byte, aesSIVBlockSize))
+	for _, si := range s[:len(s)-1] {
+		d = aesSIVDouble(d)
+		aesSIVXor(d, aesCMAC(block, si))
+	}
+
+	sn := s[len(s)-1]
+	var t []byte
+	if len(sn) >= aesSIVBlockSize {
+		t = make([]byte, len(sn))
+		copy(t, sn)
+		aesSIVXor(t[len(t)-aesSIVBlockSize:], d)
+	} else {
+		t = aesSIVDouble(d)
+		padded := make([]byte, aesSIVBlockSize)
+		copy(padded, sn)
+		padded[len(sn)] = 0x80
+		aesSIVXor(t, padded)
+	}
+
+	return aesCMAC(block, t), nil
+}
+
+// aesCMAC computes the AES-CMAC of the message, per RFC 4493
+func aesCMAC(block cipher.Block, msg []byte) []byte {
+	l := make([]byte, aesSIVBlockSize)
+	block.Encrypt(l, l)
+	k1 := aesSIVDouble(l)
+	k2 := aesSIVDouble(k1)
+
+	n := (len(msg) + aesSIVBlockSize - 1) / aesSIVBlockSize
+	complete := n > 0 && len(msg)%aesSIVBlockSize == 0
+	if n == 0 {
+		n = 1
+	}
+
+	last := make([]byte, aesSIVBlockSize)
+	if complete {
+		copy(last, msg[(n-1)*aesSIVBlockSize:])
+		aesSIVXor(last, k1)
+	} else {
+		tail := msg[(n-1)*aesSIVBlockSize:]
+		copy(last, tail)
+		last[len(tail)] = 0x80
+		aesSIVXor(last, k2)
+	}
+
+	x := make([]byte, aesSIVBlockSize)
+	for i := 0; i < n-1; i++ {
+		aesSIVXor(x, msg[i*aesSIVBlockSize:(i+1)*aesSIVBlockSize])
+		block.Encrypt(x, x)
+	}
+	aesSIVXor(x, last)
+	block.Encrypt(x, x)
+
+	return x
+}
+
+// aesSIVDouble returns the given block multiplied by x in GF(2^128)
+func aesSIVDouble(in []byte) []byte {
+	out := make([]byte, aesSIVBlockSize)
+	carry := in[0] >> 7
+	for i := 0; i < aesSIVBlockSize-1; i++ {
+		out[i] = in[i]<<1 | in[i+1]>>7
+	}
+	out[aesSIVBlockSize-1] = in[aesSIVBlockSize-1] << 1
+	if carry == 1 {
+		out[aesSIVBlockSize-1] ^= 0x87
+	}
+	return out
+}
+
+// aesSIVXor xors src into dst in place
+func aesSIVXor(dst, src []byte) {
+	for i := range src {
+		dst[i] ^= src[i]
+	}
+}
@@ -34,6 +34,24 @@ func CreateBLS12381KeyPair() (*BLS12381KeyPair, error) {
 	return &BLSKeyPair, nil
 }
 
+// BLS12381KeyPairFromPrivateKey returns the BLS12-381 keypair of the given serialized
+// private key, i.e., for imported key material
+func BLS12381KeyPairFromPrivateKey(privateKey []byte) (*BLS12381KeyPair, error) {
+	var blsPrivateKey bls.SecretKey
+	err := blsPrivateKey.Deserialize(privateKey)
+	if err != nil || blsPrivateKey.IsZero() {
+		return nil, ErrInvalidKey
+	}
+
+	privkey := blsPrivateKey.Serialize()
+	pubkey := blsPrivateKey.GetPublicKey().Serialize()
+
+	return &BLS12381KeyPair{
+		PrivateKey: &privkey,
+		PublicKey:  &pubkey,
+	}, nil
+}
+
 // Sign uses BLS12381 private key to sign the payload
 func (k *BLS12381KeyPair) Sign(payload []byte) ([]byte, error) {
 
@@ -23,3 +23,24 @@ func CreateC25519KeyPair() (*C25519, error) {
 
 	return &c25519, nil
 }
+
+// Encrypt encrypts the payload to the C25519 public key using RFC 9180 hybrid public key
+// encryption with DHKEM(X25519, HKDF-SHA256), HKDF-SHA256 and ChaCha20Poly1305; returns
+// the 32-byte encapsulated key followed by the ciphertext
+func (k *C25519) Encrypt(plaintext, info, aad []byte) ([]byte, error) {
+	hpke := HPKE{
+		PublicKey: k.PublicKey,
+		AEAD:      HPKEAEADChaCha20Poly1305,
+	}
+	return hpke.Seal(plaintext, info, aad)
+}
+
+// Decrypt decrypts the encapsulated key and ciphertext using the C25519 private key
+func (k *C25519) Decrypt(ciphertext, info, aad []byte) ([]byte, error) {
+	hpke := HPKE{
+		PrivateKey: k.PrivateKey,
+		PublicKey:  k.PublicKey,
+		AEAD:       HPKEAEADChaCha20Poly1305,
+	}
+	return hpke.Open(ciphertext, info, aad)
+}
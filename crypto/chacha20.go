@@ -86,8 +86,8 @@ func (k *ChaCha) Decrypt(ciphertext []byte, nonce []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
-// Wipe will randomize the contents of the seed key
+// Wipe will zero the contents of the seed key
 func (k *ChaCha) Wipe() {
-	io.ReadFull(rand.Reader, k.Seed)
+	WipeBytes(k.Seed)
 	k.Seed = nil
 }
@@ -0,0 +1,200 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+)
+
+// ECDSASignatureFormatDER is the ASN.1 DER signature encoding, i.e., as used by X.509 and WebAuthn
+const ECDSASignatureFormatDER = "der"
+
+// ECDSASignatureFormatRaw is the fixed-length r||s signature encoding, i.e., as used by JWS
+const ECDSASignatureFormatRaw = "raw"
+
+// ECDSAKeyPair is the internal struct for an asymmetric NIST P-256, P-384 or P-521 keypair;
+// the private key is the big-endian scalar and the public key is the uncompressed point
+type ECDSAKeyPair struct {
+	Curve      elliptic.Curve
+	PrivateKey []byte
+	PublicKey  []byte
+}
+
+// ecdsaSignature is the ASN.1 structure of a DER-encoded ECDSA signature
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// CreateECDSAKeyPair creates a keypair on the given NIST curve
+func CreateECDSAKeyPair(curve elliptic.Curve) (*ECDSAKeyPair, error) {
+	privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, ErrCannotGenerateKey
+	}
+
+	keypair := &ECDSAKeyPair{
+		Curve:      curve,
+		PrivateKey: padScalar(privateKey.D, curve),
+		PublicKey:  elliptic.Marshal(curve, privateKey.PublicKey.X, privateKey.PublicKey.Y),
+	}
+
+	return keypair, nil
+}
+
+// ECDSAHash returns the hash function used to sign using the given curve, i.e.,
+// SHA-256 for P-256 (ES256), SHA-384 for P-384 (ES384) and SHA-512 for P-521 (ES512)
+func ECDSAHash(curve elliptic.Curve) crypto.Hash {
+	switch curve.Params().BitSize {
+	case 384:
+		return crypto.SHA384
+	case 521:
+		return crypto.SHA512
+	}
+	return crypto.SHA256
+}
+
+// Sign uses the ECDSA private key to sign the hash of the payload, returning the
+// signature using the given encoding (der or raw)
+func (k *ECDSAKeyPair) Sign(payload []byte, format string) ([]byte, error) {
+	if k.PrivateKey == nil {
+		return nil, ErrNilPrivateKey
+	}
+
+	if format != ECDSASignatureFormatDER && format != ECDSASignatureFormatRaw {
+		return nil, ErrInvalidEncoding
+	}
+
+	privateKey := &ecdsa.PrivateKey{
+		D: new(big.Int).SetBytes(k.PrivateKey),
+	}
+	privateKey.Curve = k.Curve
+	privateKey.PublicKey.X, privateKey.PublicKey.Y = k.Curve.ScalarBaseMult(k.PrivateKey)
+
+	r, s, err := ecdsa.Sign(rand.Reader, privateKey, digest(ECDSAHash(k.Curve), payload))
+	if err != nil {
+		return nil, ErrCannotSignPayload
+	}
+
+	if format == ECDSASignatureFormatRaw {
+		return append(padScalar(r, k.Curve), padScalar(s, k.Curve)...), nil
+	}
+
+	sig, err := asn1.Marshal(ecdsaSignature{r, s})
+	if err != nil {
+		return nil, ErrCannotSignPayload
+	}
+
+	return sig, nil
+}
+
+// Verify uses the ECDSA public key to verify the signature, using the given encoding
+// (der or raw), of the hash of the payload
+func (k *ECDSAKeyPair) Verify(payload, sig []byte, format string) error {
+	x, y := elliptic.Unmarshal(k.Curve, k.PublicKey)
+	if x == nil {
+		return ErrInvalidPublicKey
+	}
+
+	var r, s *big.Int
+
+	switch format {
+	case ECDSASignatureFormatDER:
+		var signature ecdsaSignature
+		rest, err := asn1.Unmarshal(sig, &signature)
+		if err != nil || len(rest) > 0 || signature.R == nil || signature.S == nil {
+			return ErrCannotUnmarshallSignature
+		}
+		r, s = signature.R, signature.S
+
+	case ECDSASignatureFormatRaw:
+		size := scalarSize(k.Curve)
+		if len(sig) != 2*size {
+			return ErrCannotUnmarshallSignature
+		}
+		r = new(big.Int).SetBytes(sig[:size])
+		s = new(big.Int).SetBytes(sig[size:])
+
+	default:
+		return ErrInvalidEncoding
+	}
+
+	publicKey := &ecdsa.PublicKey{
+		Curve: k.Curve,
+		X:     x,
+		Y:     y,
+	}
+
+	if !ecdsa.Verify(publicKey, digest(ECDSAHash(k.Curve), payload), r, s) {
+		return ErrCannotVerifyPayload
+	}
+
+	return nil
+}
+
+// PublicKeyPEM returns the PEM-encoded PKIX public key
+func (k *ECDSAKeyPair) PublicKeyPEM() ([]byte, error) {
+	x, y := elliptic.Unmarshal(k.Curve, k.PublicKey)
+	if x == nil {
+		return nil, ErrInvalidPublicKey
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&ecdsa.PublicKey{
+		Curve: k.Curve,
+		X:     x,
+		Y:     y,
+	})
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: der,
+	}), nil
+}
+
+// ECDSAPublicKeyFromPEM decodes the given PEM-encoded PKIX public key on the given
+// curve and returns the uncompressed point
+func ECDSAPublicKeyFromPEM(curve elliptic.Curve, data []byte) ([]byte, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPublicKey
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+
+	publicKey, ok := key.(*ecdsa.PublicKey)
+	if !ok || publicKey.Curve.Params().Name != curve.Params().Name {
+		return nil, ErrIncompatibleKey
+	}
+
+	return elliptic.Marshal(curve, publicKey.X, publicKey.Y), nil
+}
+
+// digest returns the digest of the payload using the given hash function
+func digest(hash crypto.Hash, payload []byte) []byte {
+	h := hash.New()
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// scalarSize returns the size in bytes of scalars on the given curve
+func scalarSize(curve elliptic.Curve) int {
+	return (curve.Params().BitSize + 7) / 8
+}
+
+// padScalar returns the big-endian scalar left-padded to the scalar size of the curve
+func padScalar(n *big.Int, curve elliptic.Curve) []byte {
+	buf := make([]byte, scalarSize(curve))
+	b := n.Bytes()
+	copy(buf[len(buf)-len(b):], b)
+	return buf
+}
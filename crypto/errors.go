@@ -13,9 +13,15 @@ var (
 	// ErrUnsupportedRSASigningAlgorithm is returned if the signing algorithm is not supported
 	ErrUnsupportedRSASigningAlgorithm = errors.New("unsupported RSA algorithm")
 
+	// ErrUnsupportedAAD is returned if additional authenticated data is provided to an algorithm which does not support it
+	ErrUnsupportedAAD = errors.New("additional authenticated data not supported")
+
 	// ErrNonceTooLong is the error returned if the nonce provided is longer than permitted
 	ErrNonceTooLong = errors.New("nonce too long")
 
+	// ErrNonceTooShort is the error returned if the nonce provided is shorter than required
+	ErrNonceTooShort = errors.New("nonce too short")
+
 	// ErrCannotGenerateSeed is the error returned if seed generation fails.
 	ErrCannotGenerateSeed = errors.New("cannot generate seed")
 
@@ -34,6 +40,9 @@ var (
 	// ErrCannotSignPayload is the error returned if the payload cannot be signed
 	ErrCannotSignPayload = errors.New("cannot sign payload")
 
+	// ErrInvalidMAC is the error returned if the mac does not authenticate the payload
+	ErrInvalidMAC = errors.New("mac verification failed")
+
 	// ErrCannotVerifyPayload is the error returned if the payload cannot be verified
 	ErrCannotVerifyPayload = errors.New("cannot verify payload")
 
@@ -84,4 +93,7 @@ var (
 
 	// ErrInvalidChecksum is the error returned if the checksum is invalid
 	ErrInvalidChecksum = errors.New("nkeys: invalid checksum")
+
+	// ErrUnsupportedKeyWrapAlgorithm is the error returned if the key wrapping algorithm is not supported
+	ErrUnsupportedKeyWrapAlgorithm = errors.New("unsupported key wrapping algorithm")
 )
@@ -263,3 +263,13 @@ func GetEntropyFromMnemonic(mnemonic string) ([]byte, error) {
 
 	return entropy, nil
 }
+
+// GetMnemonicFromEntropy is used to recover the mnemonic-based unsealer key from its entropy
+func GetMnemonicFromEntropy(entropy []byte) (string, error) {
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return "", err
+	}
+
+	return mnemonic, nil
+}
@@ -0,0 +1,70 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"io"
+)
+
+// HMACSHA256KeySize is the size of a HMAC-SHA256 key in bytes, i.e., the SHA-256 digest size
+const HMACSHA256KeySize = sha256.Size
+
+// HMACSHA384KeySize is the size of a HMAC-SHA384 key in bytes, i.e., the SHA-384 digest size
+const HMACSHA384KeySize = sha512.Size384
+
+// HMACSHA512KeySize is the size of a HMAC-SHA512 key in bytes, i.e., the SHA-512 digest size
+const HMACSHA512KeySize = sha512.Size
+
+// HMAC is the internal struct for a HMAC key using the given hash function
+type HMAC struct {
+	PrivateKey []byte
+	Hash       func() hash.Hash
+}
+
+// CreateHMACKey creates a random key of the given size for a new HMAC key
+func CreateHMACKey(size int) ([]byte, error) {
+	key := make([]byte, size)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, ErrCannotGenerateKey
+	}
+
+	return key, nil
+}
+
+// MAC computes the HMAC of the given payload
+func (k *HMAC) MAC(payload []byte) ([]byte, error) {
+	if len(k.PrivateKey) == 0 {
+		return nil, ErrNilPrivateKey
+	}
+
+	if k.Hash == nil {
+		return nil, ErrInvalidKey
+	}
+
+	mac := hmac.New(k.Hash, k.PrivateKey)
+	mac.Write(payload)
+	return mac.Sum(nil), nil
+}
+
+// Verify verifies the given HMAC of the payload; the macs are compared in constant time
+func (k *HMAC) Verify(payload, mac []byte) error {
+	expected, err := k.MAC(payload)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(expected, mac) {
+		return ErrInvalidMAC
+	}
+
+	return nil
+}
+
+// Wipe will zero the contents of the private key
+func (k *HMAC) Wipe() {
+	WipeBytes(k.PrivateKey)
+	k.PrivateKey = nil
+}
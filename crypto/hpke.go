@@ -0,0 +1,228 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// HPKEKEMX25519HKDFSHA256 is the RFC 9180 identifier of the DHKEM(X25519, HKDF-SHA256) kem
+const HPKEKEMX25519HKDFSHA256 = uint16(0x0020)
+
+// HPKEKDFHKDFSHA256 is the RFC 9180 identifier of the HKDF-SHA256 kdf
+const HPKEKDFHKDFSHA256 = uint16(0x0001)
+
+// HPKEAEADAES128GCM is the RFC 9180 identifier of the AES-128-GCM aead
+const HPKEAEADAES128GCM = uint16(0x0001)
+
+// HPKEAEADAES256GCM is the RFC 9180 identifier of the AES-256-GCM aead
+const HPKEAEADAES256GCM = uint16(0x0002)
+
+// HPKEAEADChaCha20Poly1305 is the RFC 9180 identifier of the ChaCha20Poly1305 aead
+const HPKEAEADChaCha20Poly1305 = uint16(0x0003)
+
+// HPKEEncapsulatedKeySize is the size of the encapsulated key (i.e., the ephemeral
+// X25519 public key) which is prepended to HPKE ciphertexts
+const HPKEEncapsulatedKeySize = curve25519.PointSize
+
+// hpkeVersionLabel is the label prepended to all labeled HPKE kdf inputs
+const hpkeVersionLabel = "HPKE-v1"
+
+// hpkeModeBase is the RFC 9180 identifier of the base mode (i.e., without psk or sender authentication)
+const hpkeModeBase = byte(0x00)
+
+// HPKE is the internal struct for single-shot RFC 9180 hybrid public key encryption in base
+// mode using DHKEM(X25519, HKDF-SHA256) and HKDF-SHA256; the private key is only required to
+// open ciphertexts, such that any party holding the public key may seal to the recipient
+type HPKE struct {
+	PrivateKey []byte
+	PublicKey  []byte
+	AEAD       uint16
+}
+
+// Seal encrypts the plaintext to the public key, binding the optional application info and
+// additional authenticated data to the ciphertext; returns the encapsulated key followed by
+// the ciphertext
+func (k *HPKE) Seal(plaintext, info, aad []byte) ([]byte, error) {
+	ephemeralKey := make([]byte, curve25519.ScalarSize)
+	if _, err := io.ReadFull(rand.Reader, ephemeralKey); err != nil {
+		return nil, ErrCannotGenerateKey
+	}
+	defer WipeBytes(ephemeralKey)
+
+	return k.seal(ephemeralKey, plaintext, info, aad)
+}
+
+// Open decrypts the given encapsulated key and ciphertext using the private key; decryption
+// fails unless the application info and additional authenticated data match those given to Seal
+func (k *HPKE) Open(ciphertext, info, aad []byte) ([]byte, error) {
+	if k.PrivateKey == nil {
+		return nil, ErrNilPrivateKey
+	}
+
+	if len(ciphertext) < HPKEEncapsulatedKeySize {
+		return nil, ErrCannotDecrypt
+	}
+
+	publicKey := k.PublicKey
+	if publicKey == nil {
+		var err error
+		publicKey, err = curve25519.X25519(k.PrivateKey, curve25519.Basepoint)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+	}
+
+	enc := ciphertext[:HPKEEncapsulatedKeySize]
+	dh, err := curve25519.X25519(k.PrivateKey, enc)
+	if err != nil {
+		return nil, ErrCannotDecrypt
+	}
+	defer WipeBytes(dh)
+
+	aead, nonce, err := k.keySchedule(hpkeSharedSecret(dh, enc, publicKey), info)
+	if err != nil {
+		return nil, ErrCannotDecrypt
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext[HPKEEncapsulatedKeySize:], aad)
+	if err != nil {
+		return nil, ErrCannotDecrypt
+	}
+
+	return plaintext, nil
+}
+
+// Wipe will zero the contents of the private key
+func (k *HPKE) Wipe() {
+	WipeBytes(k.PrivateKey)
+	k.PrivateKey = nil
+}
+
+// seal encrypts the plaintext to the public key using the given ephemeral private key
+func (k *HPKE) seal(ephemeralKey, plaintext, info, aad []byte) ([]byte, error) {
+	if len(k.PublicKey) != curve25519.PointSize {
+		return nil, ErrInvalidPublicKey
+	}
+
+	enc, err := curve25519.X25519(ephemeralKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, ErrCannotEncrypt
+	}
+
+	dh, err := curve25519.X25519(ephemeralKey, k.PublicKey)
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+	defer WipeBytes(dh)
+
+	aead, nonce, err := k.keySchedule(hpkeSharedSecret(dh, enc, k.PublicKey), info)
+	if err != nil {
+		return nil, ErrCannotEncrypt
+	}
+
+	return aead.Seal(enc, nonce, plaintext, aad), nil
+}
+
+// keySchedule derives the aead and base nonce from the shared secret, per RFC 9180 section 5.1;
+// as only a single message is sealed per encapsulated key, the base nonce is used as-is
+func (k *HPKE) keySchedule(sharedSecret, info []byte) (cipher.AEAD, []byte, error) {
+	defer WipeBytes(sharedSecret)
+
+	var keySize int
+	switch k.AEAD {
+	case HPKEAEADAES128GCM:
+		keySize = 16
+	case HPKEAEADAES256GCM:
+		keySize = 32
+	case HPKEAEADChaCha20Poly1305:
+		keySize = chacha20poly1305.KeySize
+	default:
+		return nil, nil, ErrInvalidKey
+	}
+
+	suiteID := make([]byte, 0, 10)
+	suiteID = append(suiteID, []byte("HPKE")...)
+	suiteID = appendUint16(suiteID, HPKEKEMX25519HKDFSHA256)
+	suiteID = appendUint16(suiteID, HPKEKDFHKDFSHA256)
+	suiteID = appendUint16(suiteID, k.AEAD)
+
+	pskIDHash := hpkeLabeledExtract(suiteID, nil, "psk_id_hash", nil)
+	infoHash := hpkeLabeledExtract(suiteID, nil, "info_hash", info)
+	context := append(append([]byte{hpkeModeBase}, pskIDHash...), infoHash...)
+
+	secret := hpkeLabeledExtract(suiteID, sharedSecret, "secret", nil)
+	defer WipeBytes(secret)
+
+	key := hpkeLabeledExpand(suiteID, secret, "key", context, keySize)
+	defer WipeBytes(key)
+	nonce := hpkeLabeledExpand(suiteID, secret, "base_nonce", context, 12)
+
+	var aead cipher.AEAD
+	var err error
+	if k.AEAD == HPKEAEADChaCha20Poly1305 {
+		aead, err = chacha20poly1305.New(key)
+	} else {
+		var block cipher.Block
+		block, err = aes.NewCipher(key)
+		if err == nil {
+			aead, err = cipher.NewGCM(block)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return aead, nonce, nil
+}
+
+// hpkeSharedSecret derives the kem shared secret from the X25519 shared secret, the
+// encapsulated key and the recipient public key, per RFC 9180 section 4.1
+func hpkeSharedSecret(dh, enc, publicKey []byte) []byte {
+	suiteID := appendUint16([]byte("KEM"), HPKEKEMX25519HKDFSHA256)
+
+	kemContext := append(append([]byte{}, enc...), publicKey...)
+	prk := hpkeLabeledExtract(suiteID, nil, "eae_prk", dh)
+	defer WipeBytes(prk)
+
+	return hpkeLabeledExpand(suiteID, prk, "shared_secret", kemContext, sha256.Size)
+}
+
+// hpkeLabeledExtract is the RFC 9180 LabeledExtract function using HKDF-SHA256
+func hpkeLabeledExtract(suiteID, salt []byte, label string, ikm []byte) []byte {
+	labeledIKM := make([]byte, 0, len(hpkeVersionLabel)+len(suiteID)+len(label)+len(ikm))
+	labeledIKM = append(labeledIKM, []byte(hpkeVersionLabel)...)
+	labeledIKM = append(labeledIKM, suiteID...)
+	labeledIKM = append(labeledIKM, []byte(label)...)
+	labeledIKM = append(labeledIKM, ikm...)
+	defer WipeBytes(labeledIKM)
+
+	return hkdf.Extract(sha256.New, labeledIKM, salt)
+}
+
+// hpkeLabeledExpand is the RFC 9180 LabeledExpand function using HKDF-SHA256
+func hpkeLabeledExpand(suiteID, prk []byte, label string, info []byte, length int) []byte {
+	labeledInfo := appendUint16(make([]byte, 0, 2+len(hpkeVersionLabel)+len(suiteID)+len(label)+len(info)), uint16(length))
+	labeledInfo = append(labeledInfo, []byte(hpkeVersionLabel)...)
+	labeledInfo = append(labeledInfo, suiteID...)
+	labeledInfo = append(labeledInfo, []byte(label)...)
+	labeledInfo = append(labeledInfo, info...)
+
+	out := make([]byte, length)
+	io.ReadFull(hkdf.Expand(sha256.New, prk, labeledInfo), out)
+	return out
+}
+
+// appendUint16 appends the big-endian representation of the given value
+func appendUint16(b []byte, v uint16) []byte {
+	var buf [2]byte
+	binary.BigEndian.PutUint16(buf[:], v)
+	return append(b, buf[:]...)
+}
@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"io"
+)
+
+// KeyWrapAlgorithmRSAOAEPSHA256 wraps key material directly using RSAES-OAEP with SHA-256;
+// the key material must fit within a single RSA-OAEP block
+const KeyWrapAlgorithmRSAOAEPSHA256 = "RSAES_OAEP_SHA_256"
+
+// KeyWrapAlgorithmRSAAESKeyWrapSHA256 wraps a random AES-256 key using RSAES-OAEP with SHA-256
+// and the key material using the AES key with AES key wrap with padding (RFC 5649); the
+// wrapped key material is appended to the wrapped AES key
+const KeyWrapAlgorithmRSAAESKeyWrapSHA256 = "RSA_AES_KEY_WRAP_SHA_256"
+
+// keyWrapAESKeySize is the size of the random AES key used by KeyWrapAlgorithmRSAAESKeyWrapSHA256
+const keyWrapAESKeySize = 32
+
+// ValidateKeyWrapAlgorithm returns an error if the given key wrapping algorithm is not supported
+func ValidateKeyWrapAlgorithm(algorithm string) error {
+	switch algorithm {
+	case KeyWrapAlgorithmRSAOAEPSHA256, KeyWrapAlgorithmRSAAESKeyWrapSHA256:
+		return nil
+	}
+	return ErrUnsupportedKeyWrapAlgorithm
+}
+
+// Wrap wraps the key material using the RSA public key and the given key wrapping algorithm
+func (k *RSAKeyPair) Wrap(plaintext []byte, algorithm string) ([]byte, error) {
+	switch algorithm {
+	case KeyWrapAlgorithmRSAOAEPSHA256:
+		return k.Encrypt(plaintext)
+
+	case KeyWrapAlgorithmRSAAESKeyWrapSHA256:
+		aesKey := make([]byte, keyWrapAESKeySize)
+		if _, err := io.ReadFull(rand.Reader, aesKey); err != nil {
+			return nil, ErrCannotGenerateKey
+		}
+		defer WipeBytes(aesKey)
+
+		wrappedAESKey, err := k.Encrypt(aesKey)
+		if err != nil {
+			return nil, err
+		}
+
+		wrapped, err := AESKeyWrapPad(aesKey, plaintext)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(wrappedAESKey, wrapped...), nil
+	}
+
+	return nil, ErrUnsupportedKeyWrapAlgorithm
+}
+
+// Unwrap unwraps the key material using the DER-encoded PKCS#8 RSA private key and the given
+// key wrapping algorithm
+func (k *RSAKeyPair) Unwrap(wrapped []byte, algorithm string) ([]byte, error) {
+	if k.PrivateKey == nil {
+		return nil, ErrNilPrivateKey
+	}
+
+	// wrapping keys are ephemeral, so the parsed private key is not cached
+	key, err := x509.ParsePKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return nil, ErrCannotDecodeKey
+	}
+
+	rsaPrivateKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	switch algorithm {
+	case KeyWrapAlgorithmRSAOAEPSHA256:
+		plaintext, err := rsa.DecryptOAEP(sha256.New(), nil, rsaPrivateKey, wrapped, nil)
+		if err != nil {
+			return nil, ErrCannotDecrypt
+		}
+		return plaintext, nil
+
+	case KeyWrapAlgorithmRSAAESKeyWrapSHA256:
+		if len(wrapped) <= rsaPrivateKey.Size() {
+			return nil, ErrCannotDecrypt
+		}
+
+		aesKey, err := rsa.DecryptOAEP(sha256.New(), nil, rsaPrivateKey, wrapped[:rsaPrivateKey.Size()], nil)
+		if err != nil {
+			return nil, ErrCannotDecrypt
+		}
+		defer WipeBytes(aesKey)
+
+		if len(aesKey) != keyWrapAESKeySize {
+			return nil, ErrCannotDecrypt
+		}
+
+		return AESKeyUnwrapPad(aesKey, wrapped[rsaPrivateKey.Size():])
+	}
+
+	return nil, ErrUnsupportedKeyWrapAlgorithm
+}
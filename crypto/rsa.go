@@ -5,12 +5,15 @@ import (
 	"crypto/rand"
 	rsa "crypto/rsa"
 	"crypto/sha256"
+	"crypto/x509"
 	"encoding/json"
+	"sync"
 
 	"github.com/kthomas/go-pgputil"
 )
 
-// RSAKeyPair is the internal struct for an asymmetric keypair
+// RSAKeyPair is the internal struct for an asymmetric keypair; the private key is stored
+// as a DER-encoded PKCS#8 private key and the public key as a DER-encoded PKIX public key
 type RSAKeyPair struct {
 	PrivateKey []byte
 	PublicKey  []byte
@@ -19,6 +22,9 @@ type RSAKeyPair struct {
 // NonceSizeRSA is the size of the optional RSA nonce for encryption/decryption (in bytes)
 const NonceSizeRSA = 32
 
+// rsaKeyCacheSize is the maximum number of parsed RSA keys held by the parsed key cache
+const rsaKeyCacheSize = 256
+
 // PSSSignature is used for handling signatures using RSASSA-PSS
 const PSSSignature = "PSS"
 
@@ -38,6 +44,11 @@ type SigningMethodRSA struct {
 	Options *rsa.PSSOptions
 }
 
+// rsaKeyCache caches parsed RSA private and public keys by the SHA-256 digest of their
+// encoding, such that key material is not parsed (and precomputed) for every operation
+var rsaKeyCache = map[[sha256.Size]byte]interface{}{}
+var rsaKeyCacheMutex sync.Mutex
+
 var (
 	signatureAlgoPS256 *SigningMethodRSA
 	signatureAlgoPS384 *SigningMethodRSA
@@ -139,13 +150,13 @@ func CreateRSAKeyPair(bitsize int) (*RSAKeyPair, error) {
 	RSAKeyPair := RSAKeyPair{}
 
 	// next we'll convert the private key to bytes for storing in the db etc
-	privkey, err := json.Marshal(*privateKey)
+	privkey, err := x509.MarshalPKCS8PrivateKey(privateKey)
 	if err != nil {
 		return nil, ErrCannotGenerateKey
 	}
 	RSAKeyPair.PrivateKey = privkey
 
-	pubkey, err := json.Marshal(*&privateKey.PublicKey)
+	pubkey, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
 	if err != nil {
 		return nil, ErrCannotGenerateKey
 	}
@@ -154,6 +165,200 @@ func CreateRSAKeyPair(bitsize int) (*RSAKeyPair, error) {
 	return &RSAKeyPair, nil
 }
 
+// RSAKeyPairFromPrivateKey returns the keypair of the given DER-encoded PKCS#8 or PKCS#1 RSA
+// private key, i.e., for imported key material; the private key is validated
+func RSAKeyPairFromPrivateKey(privateKey []byte) (*RSAKeyPair, int, error) {
+	var rsaPrivateKey *rsa.PrivateKey
+	if key, err := x509.ParsePKCS8PrivateKey(privateKey); err == nil {
+		var ok bool
+		rsaPrivateKey, ok = key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, 0, ErrInvalidKey
+		}
+	} else if key, err := x509.ParsePKCS1PrivateKey(privateKey); err == nil {
+		rsaPrivateKey = key
+	} else {
+		return nil, 0, ErrCannotDecodeKey
+	}
+
+	if rsaPrivateKey.Validate() != nil {
+		return nil, 0, ErrInvalidKey
+	}
+
+	privkey, err := x509.MarshalPKCS8PrivateKey(rsaPrivateKey)
+	if err != nil {
+		return nil, 0, ErrInvalidKey
+	}
+
+	pubkey, err := x509.MarshalPKIXPublicKey(&rsaPrivateKey.PublicKey)
+	if err != nil {
+		return nil, 0, ErrInvalidKey
+	}
+
+	return &RSAKeyPair{
+		PrivateKey: privkey,
+		PublicKey:  pubkey,
+	}, rsaPrivateKey.N.BitLen(), nil
+}
+
+// ParseRSAPrivateKey parses the given DER-encoded PKCS#8 or PKCS#1 RSA private key; private
+// keys stored in the legacy JSON encoding, prior to their migration, are also supported
+func ParseRSAPrivateKey(privateKey []byte) (*rsa.PrivateKey, error) {
+	if cached, ok := cachedRSAKey(privateKey).(*rsa.PrivateKey); ok {
+		return cached, nil
+	}
+
+	var rsaPrivateKey *rsa.PrivateKey
+	if key, err := x509.ParsePKCS8PrivateKey(privateKey); err == nil {
+		var ok bool
+		rsaPrivateKey, ok = key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, ErrInvalidKey
+		}
+	} else if key, err := x509.ParsePKCS1PrivateKey(privateKey); err == nil {
+		rsaPrivateKey = key
+	} else if key, err := parseLegacyRSAPrivateKey(privateKey); err == nil {
+		rsaPrivateKey = key
+	} else {
+		return nil, ErrCannotDecodeKey
+	}
+
+	cacheRSAKey(privateKey, rsaPrivateKey)
+	return rsaPrivateKey, nil
+}
+
+// ParseRSAPublicKey parses the given DER-encoded PKIX or PKCS#1 RSA public key or PEM-encoded
+// RSA public key; public keys stored in the legacy JSON encoding are also supported
+func ParseRSAPublicKey(publicKey []byte) (*rsa.PublicKey, error) {
+	if cached, ok := cachedRSAKey(publicKey).(*rsa.PublicKey); ok {
+		return cached, nil
+	}
+
+	var rsaPublicKey *rsa.PublicKey
+	if key, err := x509.ParsePKIXPublicKey(publicKey); err == nil {
+		var ok bool
+		rsaPublicKey, ok = key.(*rsa.PublicKey)
+		if !ok {
+			return nil, ErrInvalidPublicKey
+		}
+	} else if key, err := x509.ParsePKCS1PublicKey(publicKey); err == nil {
+		rsaPublicKey = key
+	} else if key, err := parseLegacyRSAPublicKey(publicKey); err == nil {
+		rsaPublicKey = key
+	} else if key, err := pgputil.DecodeRSAPublicKeyFromPEM(publicKey); err == nil {
+		rsaPublicKey = key
+	} else {
+		return nil, ErrInvalidPublicKey
+	}
+
+	cacheRSAKey(publicKey, rsaPublicKey)
+	return rsaPublicKey, nil
+}
+
+// Migrate re-encodes the private and public key of the keypair as DER-encoded
+// PKCS#8 and PKIX keys, respectively; returns true if either key was re-encoded, i.e.,
+// if it was stored in the legacy JSON encoding
+func (k *RSAKeyPair) Migrate() (bool, error) {
+	migrated := false
+
+	if k.PrivateKey != nil {
+		if _, err := x509.ParsePKCS8PrivateKey(k.PrivateKey); err != nil {
+			rsaPrivateKey, err := ParseRSAPrivateKey(k.PrivateKey)
+			if err != nil {
+				return false, err
+			}
+
+			privateKey, err := x509.MarshalPKCS8PrivateKey(rsaPrivateKey)
+			if err != nil {
+				return false, ErrInvalidKey
+			}
+
+			WipeBytes(k.PrivateKey)
+			k.PrivateKey = privateKey
+			migrated = true
+		}
+	}
+
+	if k.PublicKey != nil {
+		if _, err := x509.ParsePKIXPublicKey(k.PublicKey); err != nil {
+			rsaPublicKey, err := ParseRSAPublicKey(k.PublicKey)
+			if err != nil {
+				return false, err
+			}
+
+			publicKey, err := x509.MarshalPKIXPublicKey(rsaPublicKey)
+			if err != nil {
+				return false, ErrInvalidPublicKey
+			}
+
+			k.PublicKey = publicKey
+			migrated = true
+		}
+	}
+
+	return migrated, nil
+}
+
+// ClearRSAKeyCache clears the parsed RSA key cache, i.e., when the vault is sealed
+func ClearRSAKeyCache() {
+	rsaKeyCacheMutex.Lock()
+	defer rsaKeyCacheMutex.Unlock()
+
+	rsaKeyCache = map[[sha256.Size]byte]interface{}{}
+}
+
+// parseLegacyRSAPrivateKey parses the given JSON-encoded rsa.PrivateKey
+func parseLegacyRSAPrivateKey(privateKey []byte) (*rsa.PrivateKey, error) {
+	var rsaPrivateKey rsa.PrivateKey
+	err := json.Unmarshal(privateKey, &rsaPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = rsaPrivateKey.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	rsaPrivateKey.Precompute()
+	return &rsaPrivateKey, nil
+}
+
+// parseLegacyRSAPublicKey parses the given JSON-encoded rsa.PublicKey
+func parseLegacyRSAPublicKey(publicKey []byte) (*rsa.PublicKey, error) {
+	var rsaPublicKey rsa.PublicKey
+	err := json.Unmarshal(publicKey, &rsaPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsaPublicKey.N == nil || rsaPublicKey.N.Sign() <= 0 || rsaPublicKey.E < 2 {
+		return nil, ErrInvalidPublicKey
+	}
+
+	return &rsaPublicKey, nil
+}
+
+// cachedRSAKey returns the parsed RSA key for the given encoding, if cached
+func cachedRSAKey(encoded []byte) interface{} {
+	rsaKeyCacheMutex.Lock()
+	defer rsaKeyCacheMutex.Unlock()
+
+	return rsaKeyCache[sha256.Sum256(encoded)]
+}
+
+// cacheRSAKey caches the parsed RSA key for the given encoding; the cache is reset
+// when it is full
+func cacheRSAKey(encoded []byte, key interface{}) {
+	rsaKeyCacheMutex.Lock()
+	defer rsaKeyCacheMutex.Unlock()
+
+	if len(rsaKeyCache) >= rsaKeyCacheSize {
+		rsaKeyCache = map[[sha256.Size]byte]interface{}{}
+	}
+	rsaKeyCache[sha256.Sum256(encoded)] = key
+}
+
 func selectSignatureMethod(algo string) (*SigningMethodRSA, error) {
 
 	switch algo {
@@ -183,8 +388,10 @@ func (k *RSAKeyPair) Sign(payload []byte, algo string) ([]byte, error) {
 	}
 
 	// get the private key struct from the privatekey bytes
-	var rsaPrivateKey rsa.PrivateKey
-	json.Unmarshal(k.PrivateKey, &rsaPrivateKey)
+	rsaPrivateKey, err := ParseRSAPrivateKey(k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
 
 	// get the signature algorithm
 	signingMethod, err := selectSignatureMethod(algo)
@@ -193,7 +400,7 @@ func (k *RSAKeyPair) Sign(payload []byte, algo string) ([]byte, error) {
 	}
 
 	// sign using the signature algorithm and private key
-	signature, err := signingMethod.Sign(&rsaPrivateKey, payload)
+	signature, err := signingMethod.Sign(rsaPrivateKey, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -240,19 +447,13 @@ func (k *RSAKeyPair) Verify(payload, sig []byte, algo string) error {
 	}
 
 	// get the rsa public key struct from the publickey bytes
-	var rsaKey rsa.PrivateKey
-	err = json.Unmarshal(k.PublicKey, &rsaKey.PublicKey)
-
+	rsaPublicKey, err := ParseRSAPublicKey(k.PublicKey)
 	if err != nil {
-		publicKey, err := pgputil.DecodeRSAPublicKeyFromPEM([]byte(k.PublicKey))
-		if err != nil {
-			return err
-		}
-		rsaKey.PublicKey = *publicKey
+		return err
 	}
 
 	// verify the signature using the signature algorithm
-	err = signingMethod.Verify(payload, sig, &rsaKey.PublicKey)
+	err = signingMethod.Verify(payload, sig, rsaPublicKey)
 	if err != nil {
 		return err
 	}
@@ -295,19 +496,21 @@ func (k *RSAKeyPair) Encrypt(plaintext []byte) ([]byte, error) {
 	}
 
 	// get the rsa public key struct from the publickey bytes
-	var rsaKey rsa.PrivateKey
-	json.Unmarshal(k.PublicKey, &rsaKey.PublicKey)
+	rsaPublicKey, err := ParseRSAPublicKey(k.PublicKey)
+	if err != nil {
+		return nil, err
+	}
 
 	// check if we're trying to encrypt too large a payload
 	// formula (for OAEP encryption) is keylen(bytes) -2 -2*hashsize(bytes)
 	// we are using SHA256, so formula is keylen(bytes) - 66
-	maxlen := rsaKey.PublicKey.Size() - 66
+	maxlen := rsaPublicKey.Size() - 66
 	if len(plaintext) > maxlen {
 		return nil, ErrEncryptionPayloadTooLong
 	}
 
 	// encrypt using OAEP
-	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, &rsaKey.PublicKey, plaintext, nil)
+	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, rsaPublicKey, plaintext, nil)
 	if err != nil {
 		return nil, ErrCannotEncrypt
 	}
@@ -321,8 +524,10 @@ func (k *RSAKeyPair) Decrypt(ciphertext []byte) ([]byte, error) {
 	}
 
 	//get the private key struct from the privatekey bytes
-	var rsaPrivateKey rsa.PrivateKey
-	json.Unmarshal(k.PrivateKey, &rsaPrivateKey)
+	rsaPrivateKey, err := ParseRSAPrivateKey(k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
 
 	// decrypt using OAEP
 	plaintext, err := rsaPrivateKey.Decrypt(nil, ciphertext, &rsa.OAEPOptions{Hash: crypto.SHA256})
@@ -2,10 +2,13 @@ package crypto
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"crypto/elliptic"
+	"crypto/rand"
 
 	"github.com/ethereum/go-ethereum/common/math"
 	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
 	"github.com/ethereum/go-ethereum/crypto/secp256k1"
 	providecrypto "github.com/provideplatform/provide-go/crypto"
 )
@@ -37,6 +40,23 @@ func CreateSecp256k1KeyPair() (*Secp256k1, error) {
 	return &secp256k1, nil
 }
 
+// Secp256k1KeyPairFromPrivateKey returns the secp256k1 keypair, including eth address, of the
+// given 32-byte private key, i.e., for imported key material
+func Secp256k1KeyPairFromPrivateKey(privateKey []byte) (*Secp256k1, error) {
+	privkey, err := ethcrypto.ToECDSA(privateKey)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	address := ethcrypto.PubkeyToAddress(privkey.PublicKey).Hex()
+
+	return &Secp256k1{
+		PrivateKey: math.PaddedBigBytes(privkey.D, privkey.Params().BitSize/8),
+		PublicKey:  elliptic.Marshal(secp256k1.S256(), privkey.PublicKey.X, privkey.PublicKey.Y),
+		Address:    &address,
+	}, nil
+}
+
 // Sign uses SECP256k1 private key to sign the payload
 // note that this mechanism is designed for Ethereum signing
 func (k *Secp256k1) Sign(payload []byte) ([]byte, error) {
@@ -77,3 +97,40 @@ func (k *Secp256k1) Verify(payload, sig []byte) error {
 
 	return nil
 }
+
+// Encrypt encrypts the payload to the Secp256k1 public key using ECIES (SEC 1, 5.1) with
+// AES-128-CTR and HMAC-SHA-256; the optional additional authenticated data is bound to the
+// ciphertext by the message tag and must be given to decrypt the ciphertext
+func (k *Secp256k1) Encrypt(plaintext, aad []byte) ([]byte, error) {
+	x, y := elliptic.Unmarshal(secp256k1.S256(), k.PublicKey)
+	if x == nil {
+		return nil, ErrInvalidPublicKey
+	}
+
+	publicKey := ecies.ImportECDSAPublic(&ecdsa.PublicKey{Curve: secp256k1.S256(), X: x, Y: y})
+	ciphertext, err := ecies.Encrypt(rand.Reader, publicKey, plaintext, nil, aad)
+	if err != nil {
+		return nil, ErrCannotEncrypt
+	}
+
+	return ciphertext, nil
+}
+
+// Decrypt decrypts the ECIES ciphertext using the Secp256k1 private key
+func (k *Secp256k1) Decrypt(ciphertext, aad []byte) ([]byte, error) {
+	if k.PrivateKey == nil {
+		return nil, ErrNilPrivateKey
+	}
+
+	secp256k1Key, err := ethcrypto.ToECDSA(k.PrivateKey)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	plaintext, err := ecies.ImportECDSA(secp256k1Key).Decrypt(ciphertext, nil, aad)
+	if err != nil {
+		return nil, ErrCannotDecrypt
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,87 @@
+package crypto
+
+import (
+	"fmt"
+
+	"github.com/awnumar/memguard"
+)
+
+// SecureBuffer holds sensitive key material in guarded memory; the backing pages are
+// locked so they are never swapped to disk, are surrounded by guard pages and are zeroed
+// when the buffer is destroyed; core dumps are disabled for the process upon import
+type SecureBuffer struct {
+	buf *memguard.LockedBuffer
+}
+
+// NewSecureBuffer moves the given key material into a new SecureBuffer; the given slice is zeroed
+func NewSecureBuffer(src []byte) *SecureBuffer {
+	return &SecureBuffer{
+		buf: memguard.NewBufferFromBytes(src),
+	}
+}
+
+// Bytes returns the guarded key material; the returned slice references guarded
+// memory and must not be used after the buffer is destroyed
+func (b *SecureBuffer) Bytes() []byte {
+	if b == nil || b.buf == nil {
+		return nil
+	}
+	return b.buf.Bytes()
+}
+
+// Size returns the length of the guarded key material in bytes
+func (b *SecureBuffer) Size() int {
+	if b == nil || b.buf == nil {
+		return 0
+	}
+	return b.buf.Size()
+}
+
+// Destroy zeroes and releases the guarded memory; it is safe to call more than once
+func (b *SecureBuffer) Destroy() {
+	if b == nil || b.buf == nil {
+		return
+	}
+	b.buf.Destroy()
+}
+
+// SecureEnclave holds sensitive key material encrypted in memory under a cloaking key
+// which is itself held in guarded memory; the key material is only available in the
+// clear by way of Open, for as long as the returned buffer is needed
+type SecureEnclave struct {
+	enclave *memguard.Enclave
+}
+
+// NewSecureEnclave seals the given key material in a new SecureEnclave; the given slice is zeroed
+func NewSecureEnclave(src []byte) *SecureEnclave {
+	return &SecureEnclave{
+		enclave: memguard.NewEnclave(src),
+	}
+}
+
+// Open decrypts the enclave into a new SecureBuffer, which the caller must destroy
+func (e *SecureEnclave) Open() (*SecureBuffer, error) {
+	if e == nil || e.enclave == nil {
+		return nil, fmt.Errorf("failed to open secure enclave; no key material present")
+	}
+
+	buf, err := e.enclave.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secure enclave; %s", err.Error())
+	}
+
+	return &SecureBuffer{
+		buf: buf,
+	}, nil
+}
+
+// WipeBytes overwrites the given slice with zeros
+func WipeBytes(buf []byte) {
+	memguard.WipeBytes(buf)
+}
+
+// PurgeSecureMemory destroys all guarded memory and the cloaking key of every SecureEnclave;
+// this should only be called when the process is exiting
+func PurgeSecureMemory() {
+	memguard.Purge()
+}
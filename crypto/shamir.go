@@ -0,0 +1,169 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// ShamirMaxShares is the maximum number of shares which may be issued for a single secret
+const ShamirMaxShares = 255
+
+// ShamirMinThreshold is the minimum number of shares required to reconstruct a secret
+const ShamirMinThreshold = 2
+
+// shamirShareOverhead is the number of bytes prepended to each share; the threshold
+// byte followed by the x-coordinate of the share
+const shamirShareOverhead = 2
+
+var (
+	// ErrInvalidShamirParams is returned if the requested shares and threshold cannot be used to split a secret
+	ErrInvalidShamirParams = errors.New("invalid shamir shares or threshold")
+
+	// ErrInvalidShamirShare is returned if a share is malformed
+	ErrInvalidShamirShare = errors.New("invalid shamir share")
+
+	// ErrInsufficientShamirShares is returned if fewer than threshold shares are provided to combine
+	ErrInsufficientShamirShares = errors.New("insufficient shamir shares")
+)
+
+// ShamirSplit splits the given secret into n shares, any threshold of which can be
+// used to reconstruct it; each share is encoded as threshold || x || y
+func ShamirSplit(secret []byte, n, threshold int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, ErrInvalidShamirParams
+	}
+
+	if threshold < ShamirMinThreshold || n < threshold || n > ShamirMaxShares {
+		return nil, ErrInvalidShamirParams
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, shamirShareOverhead+len(secret))
+		shares[i][0] = byte(threshold)
+		shares[i][1] = byte(i + 1)
+	}
+
+	// one random polynomial of degree threshold-1 per secret byte,
+	// with the secret byte as the constant term
+	coefficients := make([]byte, threshold)
+	defer WipeBytes(coefficients)
+
+	for idx, val := range secret {
+		coefficients[0] = val
+		if _, err := io.ReadFull(rand.Reader, coefficients[1:]); err != nil {
+			return nil, ErrCannotGenerateSeed
+		}
+
+		for i := range shares {
+			shares[i][shamirShareOverhead+idx] = gf256EvalPolynomial(coefficients, shares[i][1])
+		}
+	}
+
+	return shares, nil
+}
+
+// ShamirCombine reconstructs the secret from the given shares using lagrange
+// interpolation at x=0; at least threshold distinct shares must be provided
+func ShamirCombine(shares [][]byte) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, ErrInsufficientShamirShares
+	}
+
+	threshold, err := ShamirShareThreshold(shares[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(shares) < threshold {
+		return nil, ErrInsufficientShamirShares
+	}
+
+	secretLen := len(shares[0]) - shamirShareOverhead
+	xs := make([]byte, len(shares))
+	seen := map[byte]bool{}
+
+	for i, share := range shares {
+		if len(share) != secretLen+shamirShareOverhead || int(share[0]) != threshold || share[1] == 0 {
+			return nil, ErrInvalidShamirShare
+		}
+
+		if seen[share[1]] {
+			return nil, ErrInvalidShamirShare
+		}
+
+		seen[share[1]] = true
+		xs[i] = share[1]
+	}
+
+	secret := make([]byte, secretLen)
+	for idx := range secret {
+		var val byte
+		for i, share := range shares {
+			val ^= gf256Mul(share[shamirShareOverhead+idx], gf256LagrangeBasis(xs, i))
+		}
+		secret[idx] = val
+	}
+
+	return secret, nil
+}
+
+// ShamirShareThreshold returns the threshold encoded in the given share
+func ShamirShareThreshold(share []byte) (int, error) {
+	if len(share) <= shamirShareOverhead {
+		return 0, ErrInvalidShamirShare
+	}
+
+	threshold := int(share[0])
+	if threshold < ShamirMinThreshold {
+		return 0, ErrInvalidShamirShare
+	}
+
+	return threshold, nil
+}
+
+// gf256LagrangeBasis returns the lagrange basis polynomial for xs[i], evaluated at x=0
+func gf256LagrangeBasis(xs []byte, i int) byte {
+	basis := byte(1)
+	for j := range xs {
+		if i == j {
+			continue
+		}
+		basis = gf256Mul(basis, gf256Div(xs[j], xs[i]^xs[j]))
+	}
+	return basis
+}
+
+// gf256EvalPolynomial evaluates the polynomial with the given coefficients at x using horner's method
+func gf256EvalPolynomial(coefficients []byte, x byte) byte {
+	var val byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		val = gf256Mul(val, x) ^ coefficients[i]
+	}
+	return val
+}
+
+// gf256Mul multiplies a and b in GF(2^8) using the AES reducing polynomial; the
+// loop always runs eight times so the running time does not depend on its inputs
+func gf256Mul(a, b byte) byte {
+	var product byte
+	for i := 0; i < 8; i++ {
+		product ^= -(b & 1) & a
+		mask := -(a >> 7)
+		a = (a << 1) ^ (0x1b & mask)
+		b >>= 1
+	}
+	return product
+}
+
+// gf256Div divides a by b in GF(2^8); b must be non-zero
+func gf256Div(a, b byte) byte {
+	// b^254 is the multiplicative inverse of b
+	inv := b
+	for i := 0; i < 6; i++ {
+		inv = gf256Mul(gf256Mul(inv, inv), b)
+	}
+	inv = gf256Mul(inv, inv)
+	return gf256Mul(a, inv)
+}
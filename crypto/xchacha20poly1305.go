@@ -0,0 +1,87 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// NonceSizeXChaCha20Poly1305 is the size of the xchacha20-poly1305 nonce for encryption/decryption (in bytes)
+const NonceSizeXChaCha20Poly1305 = chacha20poly1305.NonceSizeX
+
+// XChaCha20Poly1305SeedSize is the size of the seed in bytes
+const XChaCha20Poly1305SeedSize = chacha20poly1305.KeySize
+
+// XChaCha20Poly1305 is the internal struct for an authenticated xchacha20-poly1305 key using seed
+type XChaCha20Poly1305 struct {
+	Seed []byte
+}
+
+// CreateXChaCha20Poly1305Seed returns a suitable xchacha20-poly1305 seed
+func CreateXChaCha20Poly1305Seed() ([]byte, error) {
+	seed := make([]byte, XChaCha20Poly1305SeedSize)
+	if _, err := io.ReadFull(rand.Reader, seed); err != nil {
+		return nil, ErrCannotGenerateSeed
+	}
+
+	return seed, nil
+}
+
+// Encrypt encrypts byte array using xchacha20-poly1305 key, authenticating the optional
+// additional data; nonce is optional and a random nonce is generated if nil, otherwise it
+// must be exactly NonceSizeXChaCha20Poly1305 bytes in length; the extended nonce is large
+// enough that random nonces may be used safely with a given key
+func (k *XChaCha20Poly1305) Encrypt(plaintext, nonce, aad []byte) ([]byte, error) {
+	if nonce == nil {
+		nonce = make([]byte, NonceSizeXChaCha20Poly1305)
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, ErrCannotGenerateNonce
+		}
+	}
+
+	if len(nonce) > NonceSizeXChaCha20Poly1305 {
+		return nil, ErrNonceTooLong
+	}
+
+	if len(nonce) < NonceSizeXChaCha20Poly1305 {
+		return nil, ErrNonceTooShort
+	}
+
+	aead, err := chacha20poly1305.NewX(k.Seed)
+	if err != nil {
+		return nil, ErrCannotEncrypt
+	}
+
+	ciphertext := aead.Seal(nil, nonce, plaintext, aad)
+	//prepend the nonce to the ciphertext
+	ciphertext = append(nonce[:], ciphertext[:]...)
+
+	return ciphertext, nil
+}
+
+// Decrypt decrypts byte array using xchacha20-poly1305 key and input nonce; decryption
+// fails if the ciphertext has been modified or the additional data does not match
+func (k *XChaCha20Poly1305) Decrypt(ciphertext, nonce, aad []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(k.Seed)
+	if err != nil {
+		return nil, ErrCannotDecrypt
+	}
+
+	if len(nonce) != NonceSizeXChaCha20Poly1305 {
+		return nil, ErrCannotDecrypt
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrCannotDecrypt
+	}
+
+	return plaintext, nil
+}
+
+// Wipe will zero the contents of the seed key
+func (k *XChaCha20Poly1305) Wipe() {
+	WipeBytes(k.Seed)
+	k.Seed = nil
+}
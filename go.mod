@@ -5,8 +5,11 @@ go 1.13
 require (
 	github.com/Azure/azure-sdk-for-go v55.0.0+incompatible
 	github.com/Azure/go-autorest/autorest v0.11.18 // indirect
+	github.com/awnumar/memguard v0.22.2
+	github.com/aws/aws-sdk-go v1.38.70
 	github.com/ethereum/go-ethereum v1.9.22
 	github.com/gin-gonic/gin v1.7.0
+	github.com/go-redis/redis v6.15.6+incompatible
 	github.com/golang-migrate/migrate v3.5.4+incompatible
 	github.com/herumi/bls-eth-go-binary v0.0.0-20210102080045-a126987eca2b
 	github.com/jinzhu/gorm v1.9.16
@@ -17,6 +20,7 @@ require (
 	github.com/kthomas/go-pgputil v0.0.0-20200602073402-784e96083943
 	github.com/kthomas/go-redisutil v0.0.0-20200602073431-aa49de17e9ff
 	github.com/kthomas/go.uuid v1.2.1-0.20190324131420-28d1fa77e9a4
+	github.com/miekg/pkcs11 v1.0.3
 	github.com/miguelmota/go-ethereum-hdwallet v0.0.0-20200123000308-a60dcd172b4c
 	github.com/onsi/ginkgo v1.14.0
 	github.com/onsi/gomega v1.10.1
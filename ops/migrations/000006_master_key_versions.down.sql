@@ -0,0 +1,7 @@
+DROP INDEX idx_secrets_master_key_id;
+ALTER TABLE secrets DROP COLUMN master_key_id;
+
+DROP INDEX idx_keys_master_key_id;
+ALTER TABLE keys DROP COLUMN master_key_id;
+
+DROP TABLE master_key_versions;
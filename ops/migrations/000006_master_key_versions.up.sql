@@ -0,0 +1,37 @@
+CREATE TABLE public.master_key_versions (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    vault_id uuid NOT NULL,
+    key_id uuid NOT NULL,
+    version integer NOT NULL,
+    retired_at timestamp with time zone
+);
+
+ALTER TABLE public.master_key_versions OWNER TO current_user;
+
+ALTER TABLE ONLY public.master_key_versions
+    ADD CONSTRAINT master_key_versions_pkey PRIMARY KEY (id);
+
+CREATE UNIQUE INDEX idx_master_key_versions_vault_id_version ON public.master_key_versions USING btree (vault_id, version);
+CREATE UNIQUE INDEX idx_master_key_versions_key_id ON public.master_key_versions USING btree (key_id);
+
+ALTER TABLE ONLY public.master_key_versions
+    ADD CONSTRAINT master_key_versions_vault_id_vaults_id_foreign FOREIGN KEY (vault_id) REFERENCES public.vaults(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.master_key_versions
+    ADD CONSTRAINT master_key_versions_key_id_keys_id_foreign FOREIGN KEY (key_id) REFERENCES public.keys(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+ALTER TABLE keys ADD COLUMN master_key_id uuid;
+CREATE INDEX idx_keys_master_key_id ON keys USING btree (master_key_id);
+
+ALTER TABLE secrets ADD COLUMN master_key_id uuid;
+CREATE INDEX idx_secrets_master_key_id ON secrets USING btree (master_key_id);
+
+INSERT INTO master_key_versions (created_at, vault_id, key_id, version)
+    SELECT now(), vaults.id, vaults.master_key_id, 0 FROM vaults INNER JOIN keys ON keys.id = vaults.master_key_id;
+
+UPDATE keys SET master_key_id = vaults.master_key_id FROM vaults
+    WHERE keys.vault_id = vaults.id AND keys.id <> vaults.master_key_id;
+
+UPDATE secrets SET master_key_id = vaults.master_key_id FROM vaults
+    WHERE secrets.vault_id = vaults.id;
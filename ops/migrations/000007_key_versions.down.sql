@@ -0,0 +1,3 @@
+DROP TABLE key_versions;
+
+ALTER TABLE keys DROP COLUMN version;
@@ -0,0 +1,24 @@
+ALTER TABLE keys ADD COLUMN version integer DEFAULT 0 NOT NULL;
+
+CREATE TABLE public.key_versions (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    key_id uuid NOT NULL,
+    version integer NOT NULL,
+    seed bytea,
+    public_key bytea,
+    private_key bytea,
+    master_key_id uuid,
+    destroyed_at timestamp with time zone
+);
+
+ALTER TABLE public.key_versions OWNER TO current_user;
+
+ALTER TABLE ONLY public.key_versions
+    ADD CONSTRAINT key_versions_pkey PRIMARY KEY (id);
+
+CREATE UNIQUE INDEX idx_key_versions_key_id_version ON public.key_versions USING btree (key_id, version);
+CREATE INDEX idx_key_versions_master_key_id ON public.key_versions USING btree (master_key_id);
+
+ALTER TABLE ONLY public.key_versions
+    ADD CONSTRAINT key_versions_key_id_keys_id_foreign FOREIGN KEY (key_id) REFERENCES public.keys(id) ON UPDATE CASCADE ON DELETE CASCADE;
@@ -0,0 +1 @@
+ALTER TABLE keys ADD COLUMN deterministic boolean DEFAULT false NOT NULL;
@@ -0,0 +1,26 @@
+CREATE TABLE public.key_imports (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    vault_id uuid NOT NULL,
+    spec character varying(32) NOT NULL,
+    wrapping_algorithm character varying(64) NOT NULL,
+    public_key bytea,
+    private_key bytea,
+    master_key_id uuid,
+    expires_at timestamp with time zone NOT NULL,
+    key_id uuid,
+    imported_at timestamp with time zone
+);
+
+ALTER TABLE public.key_imports OWNER TO current_user;
+
+ALTER TABLE ONLY public.key_imports
+    ADD CONSTRAINT key_imports_pkey PRIMARY KEY (id);
+
+CREATE INDEX idx_key_imports_vault_id ON public.key_imports USING btree (vault_id);
+
+ALTER TABLE ONLY public.key_imports
+    ADD CONSTRAINT key_imports_vault_id_vaults_id_foreign FOREIGN KEY (vault_id) REFERENCES public.vaults(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.key_imports
+    ADD CONSTRAINT key_imports_key_id_keys_id_foreign FOREIGN KEY (key_id) REFERENCES public.keys(id) ON UPDATE CASCADE ON DELETE SET NULL;
@@ -0,0 +1,87 @@
+// +build unit
+
+package test
+
+import (
+	"os"
+	"testing"
+
+	identcommon "github.com/provideplatform/ident/common"
+	"github.com/provideplatform/ident/token"
+	"github.com/provideplatform/vault/common"
+	"github.com/provideplatform/vault/vault"
+)
+
+func TestIsOperatorSudo(t *testing.T) {
+	bearer := &token.Token{
+		Subject:     common.StringOrNil("user:operator"),
+		Permissions: identcommon.DefaultSudoerPermission,
+	}
+
+	if !vault.IsOperator(bearer) {
+		t.Error("bearer with sudo permission not authorized as operator")
+		return
+	}
+}
+
+func TestIsOperatorDeniedWithoutSudo(t *testing.T) {
+	// the operator subject allowlist of the caller's environment must not authorize the bearer
+	subjects := os.Getenv("VAULT_OPERATOR_SUBJECTS")
+	defer os.Setenv("VAULT_OPERATOR_SUBJECTS", subjects)
+	os.Unsetenv("VAULT_OPERATOR_SUBJECTS")
+
+	bearer := &token.Token{
+		Subject:     common.StringOrNil("user:tenant"),
+		Permissions: identcommon.DefaultUserPermission,
+	}
+
+	if vault.IsOperator(bearer) {
+		t.Error("bearer without sudo permission authorized as operator")
+		return
+	}
+}
+
+func TestIsOperatorDeniedWithoutBearer(t *testing.T) {
+	if vault.IsOperator(nil) {
+		t.Error("nil bearer authorized as operator")
+		return
+	}
+}
+
+func TestIsOperatorSubjectAllowlist(t *testing.T) {
+	subjects := os.Getenv("VAULT_OPERATOR_SUBJECTS")
+	defer os.Setenv("VAULT_OPERATOR_SUBJECTS", subjects)
+	os.Setenv("VAULT_OPERATOR_SUBJECTS", "user:operator, application:operator")
+
+	bearer := &token.Token{
+		Subject:     common.StringOrNil("user:operator"),
+		Permissions: identcommon.DefaultUserPermission,
+	}
+
+	if !vault.IsOperator(bearer) {
+		t.Error("bearer with allowlisted subject not authorized as operator")
+		return
+	}
+
+	bearer.Subject = common.StringOrNil("user:tenant")
+	if vault.IsOperator(bearer) {
+		t.Error("bearer without allowlisted subject authorized as operator")
+		return
+	}
+}
+
+func TestIsOperatorDeniedWildcardSubject(t *testing.T) {
+	subjects := os.Getenv("VAULT_OPERATOR_SUBJECTS")
+	defer os.Setenv("VAULT_OPERATOR_SUBJECTS", subjects)
+	os.Setenv("VAULT_OPERATOR_SUBJECTS", "*")
+
+	bearer := &token.Token{
+		Subject:     common.StringOrNil("user:tenant"),
+		Permissions: identcommon.DefaultUserPermission,
+	}
+
+	if vault.IsOperator(bearer) {
+		t.Error("bearer authorized as operator by wildcard subject")
+		return
+	}
+}
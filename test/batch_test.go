@@ -0,0 +1,353 @@
+// +build unit
+
+package test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	uuid "github.com/kthomas/go.uuid"
+	hdwallet "github.com/miguelmota/go-ethereum-hdwallet"
+	"github.com/provideplatform/vault/common"
+	"github.com/provideplatform/vault/crypto"
+	"github.com/provideplatform/vault/vault"
+)
+
+func TestBatchResultsRenderInRequestOrder(t *testing.T) {
+	version := 0
+	resp := &vault.KeySignVerifyBatchRequestResponse{
+		Results: []*vault.KeySignVerifyBatchResult{
+			{
+				KeySignVerifyRequestResponse: &vault.KeySignVerifyRequestResponse{
+					Signature: common.StringOrNil("deadbeef"),
+					Version:   &version,
+				},
+			},
+			{
+				Error: common.StringOrNil("failed to decode message"),
+			},
+		},
+	}
+
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		t.Errorf("failed to marshal batch response; %s", err.Error())
+		return
+	}
+
+	expected := `{"results":[{"signature":"deadbeef","version":0},{"error":"failed to decode message"}]}`
+	if string(raw) != expected {
+		t.Errorf("batch response rendered as %s; expected %s", string(raw), expected)
+		return
+	}
+}
+
+func TestBatchRequestParsesItems(t *testing.T) {
+	params := &vault.KeyEncryptDecryptBatchRequestResponse{}
+	err := json.Unmarshal([]byte(`{"items":[{"data":"one"},{"data":"AAE=","encoding":"base64"}]}`), &params)
+	if err != nil {
+		t.Errorf("failed to unmarshal batch request; %s", err.Error())
+		return
+	}
+
+	if len(params.Items) != 2 || *params.Items[0].Data != "one" || *params.Items[1].Encoding != vault.DataEncodingBase64 {
+		t.Error("batch request items not parsed in order")
+		return
+	}
+}
+
+func TestValidateBatchSize(t *testing.T) {
+	if vault.ValidateBatchSize(0) == nil {
+		t.Error("empty batch should be rejected")
+		return
+	}
+
+	if vault.ValidateBatchSize(1) != nil {
+		t.Error("batch of a single item should be accepted")
+		return
+	}
+
+	if os.Getenv("BATCH_MAX_SIZE") == "" {
+		if vault.ValidateBatchSize(vault.DefaultBatchMaxSize) != nil {
+			t.Errorf("batch of %d items should be accepted", vault.DefaultBatchMaxSize)
+			return
+		}
+
+		if vault.ValidateBatchSize(vault.DefaultBatchMaxSize+1) == nil {
+			t.Errorf("batch of %d items should be rejected", vault.DefaultBatchMaxSize+1)
+			return
+		}
+	}
+}
+
+func TestEncryptDecryptBatch(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for batch unit test!")
+		return
+	}
+
+	key, err := vault.AES256GCMFactory(keyDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create AES-256-GCM key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	sealedLen := len(*key.PrivateKey)
+
+	plaintexts := []string{common.RandomString(32), common.RandomString(64), common.RandomString(128)}
+	items := []*vault.KeyEncryptDecryptRequestResponse{
+		{Data: common.StringOrNil(plaintexts[0])},
+		{},
+		{Data: common.StringOrNil(plaintexts[1])},
+		{Data: common.StringOrNil(plaintexts[2]), Encoding: common.StringOrNil("utf16")},
+	}
+
+	results, err := key.EncryptBatch(items)
+	if err != nil {
+		t.Errorf("failed to encrypt batch using key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if len(results) != len(items) {
+		t.Errorf("encrypt batch returned %d results for %d items", len(results), len(items))
+		return
+	}
+
+	if results[0].Error != nil || results[2].Error != nil {
+		t.Error("valid items of encrypt batch should not fail")
+		return
+	}
+
+	if results[1].Error == nil || results[1].KeyEncryptDecryptRequestResponse != nil {
+		t.Error("item without data should fail without failing the batch")
+		return
+	}
+
+	if results[3].Error == nil {
+		t.Error("item with an unsupported encoding should fail without failing the batch")
+		return
+	}
+
+	if len(*key.PrivateKey) != sealedLen {
+		t.Errorf("key material of key: %s was not re-encrypted after the encrypt batch", key.ID)
+		return
+	}
+
+	tampered := []byte(*results[2].Data)
+	tampered[len(tampered)-2] ^= 0x01
+
+	decryptItems := []*vault.KeyEncryptDecryptRequestResponse{
+		{Data: results[2].Data},
+		{Data: common.StringOrNil(string(tampered))},
+		{Data: results[0].Data},
+	}
+
+	decrypted, err := key.DecryptBatch(keyDB, decryptItems)
+	if err != nil {
+		t.Errorf("failed to decrypt batch using key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if len(decrypted) != len(decryptItems) {
+		t.Errorf("decrypt batch returned %d results for %d items", len(decrypted), len(decryptItems))
+		return
+	}
+
+	if decrypted[0].Error != nil || *decrypted[0].Data != plaintexts[1] {
+		t.Error("first item of decrypt batch not decrypted in request order")
+		return
+	}
+
+	if decrypted[1].Error == nil {
+		t.Error("tampered item should fail without failing the batch")
+		return
+	}
+
+	if decrypted[2].Error != nil || *decrypted[2].Data != plaintexts[0] {
+		t.Error("last item of decrypt batch not decrypted in request order")
+		return
+	}
+
+	if len(*key.PrivateKey) != sealedLen {
+		t.Errorf("key material of key: %s was not re-encrypted after the decrypt batch", key.ID)
+		return
+	}
+
+	// the key is no longer held decrypted, so it is unsealed for each subsequent operation
+	ciphertext, err := key.Encrypt([]byte(plaintexts[0]), nil)
+	if err != nil {
+		t.Errorf("failed to encrypt using key: %s after batch; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	plaintext, err := key.Decrypt(ciphertext)
+	if err != nil || string(plaintext) != plaintexts[0] {
+		t.Errorf("failed to decrypt using key: %s after batch", key.ID)
+		return
+	}
+
+	if len(*key.PrivateKey) != sealedLen {
+		t.Errorf("key material of key: %s was not re-encrypted after encrypting and decrypting", key.ID)
+		return
+	}
+}
+
+func TestSignVerifyBatch(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for batch unit test!")
+		return
+	}
+
+	key, err := vault.Secp256k1Factory(keyDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create secp256k1 key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	sealedLen := len(*key.PrivateKey)
+
+	messages := []string{
+		hex.EncodeToString(ethcrypto.Keccak256([]byte(common.RandomString(32)))),
+		hex.EncodeToString(ethcrypto.Keccak256([]byte(common.RandomString(32)))),
+	}
+
+	items := []*vault.KeySignVerifyRequestResponse{
+		{Message: common.StringOrNil(messages[0])},
+		{Message: common.StringOrNil("not hex")},
+		{Message: common.StringOrNil(messages[1])},
+		{Message: common.StringOrNil(messages[1]), Signature: common.StringOrNil("deadbeef")},
+	}
+
+	results, err := key.SignBatch(items)
+	if err != nil {
+		t.Errorf("failed to sign batch using key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if len(results) != len(items) {
+		t.Errorf("sign batch returned %d results for %d items", len(results), len(items))
+		return
+	}
+
+	if results[0].Error != nil || results[2].Error != nil {
+		t.Error("valid items of sign batch should not fail")
+		return
+	}
+
+	if results[1].Error == nil || results[3].Error == nil {
+		t.Error("invalid items should fail without failing the batch")
+		return
+	}
+
+	if len(*key.PrivateKey) != sealedLen {
+		t.Errorf("key material of key: %s was not re-encrypted after the sign batch", key.ID)
+		return
+	}
+
+	// the signatures are verified in the reverse order; the first is verified against the wrong message
+	verifyItems := []*vault.KeySignVerifyRequestResponse{
+		{Message: common.StringOrNil(messages[1]), Signature: results[2].Signature},
+		{Message: common.StringOrNil(messages[1]), Signature: results[0].Signature},
+		{Message: common.StringOrNil(messages[0])},
+		{Message: common.StringOrNil(messages[0]), Signature: results[0].Signature},
+	}
+
+	verified, err := key.VerifyBatch(keyDB, verifyItems)
+	if err != nil {
+		t.Errorf("failed to verify batch using key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if len(verified) != len(verifyItems) {
+		t.Errorf("verify batch returned %d results for %d items", len(verified), len(verifyItems))
+		return
+	}
+
+	if verified[0].Error != nil || !*verified[0].Verified {
+		t.Error("first signature of verify batch not verified in request order")
+		return
+	}
+
+	if verified[1].Error == nil && *verified[1].Verified {
+		t.Error("signature verified against the wrong message")
+		return
+	}
+
+	if verified[2].Error == nil {
+		t.Error("item without a signature should fail without failing the batch")
+		return
+	}
+
+	if verified[3].Error != nil || !*verified[3].Verified {
+		t.Error("last signature of verify batch not verified in request order")
+		return
+	}
+
+	if len(*key.PrivateKey) != sealedLen {
+		t.Errorf("key material of key: %s was not re-encrypted after the verify batch", key.ID)
+		return
+	}
+}
+
+func TestSignBatchBIP39(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for batch unit test!")
+		return
+	}
+
+	walletKey, err := vault.EthHDWalletFactory(keyDB, &vlt.ID, "test wallet", "test hd wallet")
+	if err != nil {
+		t.Errorf("failed to create eth HD wallet for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	messages := make([]string, 3)
+	items := make([]*vault.KeySignVerifyRequestResponse, len(messages))
+	for i := range messages {
+		messages[i] = hex.EncodeToString([]byte(common.RandomString(32)))
+		items[i] = &vault.KeySignVerifyRequestResponse{Message: common.StringOrNil(messages[i])}
+	}
+
+	results, err := walletKey.SignBatch(items)
+	if err != nil {
+		t.Errorf("failed to sign batch using hd wallet: %s; Error: %s", walletKey.ID, err.Error())
+		return
+	}
+
+	// each message is signed using the next key of the iterative derivation path
+	paths := map[string]bool{}
+	for i, result := range results {
+		if result.Error != nil {
+			t.Errorf("failed to sign item %d of batch using hd wallet: %s; Error: %s", i, walletKey.ID, *result.Error)
+			return
+		}
+
+		if result.DerivationPath == nil || paths[*result.DerivationPath] {
+			t.Errorf("item %d of batch not signed using the next derived key of hd wallet: %s", i, walletKey.ID)
+			return
+		}
+		paths[*result.DerivationPath] = true
+
+		path, _ := hdwallet.ParseDerivationPath(*result.DerivationPath)
+		iteration := path[4]
+
+		msg, _ := hex.DecodeString(messages[i])
+		sig, _ := hex.DecodeString(*result.Signature)
+		err = walletKey.Verify(msg, sig, &vault.SigningOptions{
+			HDWallet: &crypto.HDWallet{
+				CoinAbbr: common.StringOrNil("ETH"),
+				Index:    &iteration,
+			},
+		})
+		if err != nil {
+			t.Errorf("failed to verify item %d of batch signed using hd wallet: %s; Error: %s", i, walletKey.ID, err.Error())
+			return
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// +build unit
+
+package test
+
+import (
+	"bytes"
+	"testing"
+
+	uuid "github.com/kthomas/go.uuid"
+	"github.com/provideplatform/vault/vault"
+)
+
+func TestGenerateDataKeyAES256GCM(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for data key unit test!")
+		return
+	}
+
+	key, err := vault.AES256GCMFactory(vaultDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create AES-256-GCM key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	dataKey, wrappedDataKey, err := key.GenerateDataKey()
+	if err != nil {
+		t.Errorf("failed to generate data key using AES-256-GCM key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if len(dataKey) != vault.DataKeySize {
+		t.Errorf("expected %d-byte data key; got %d bytes", vault.DataKeySize, len(dataKey))
+		return
+	}
+
+	unwrapped, err := key.Decrypt(wrappedDataKey)
+	if err != nil {
+		t.Errorf("failed to unwrap data key using AES-256-GCM key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if !bytes.Equal(unwrapped, dataKey) {
+		t.Errorf("unwrapped data key does not match generated data key for key: %s", key.ID)
+		return
+	}
+}
+
+func TestGenerateDataKeyWithoutPlaintextChaCha20(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for data key unit test!")
+		return
+	}
+
+	key, err := vault.Chacha20Factory(vaultDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create ChaCha20 key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	wrappedDataKey, err := key.GenerateDataKeyWithoutPlaintext()
+	if err != nil {
+		t.Errorf("failed to generate data key using ChaCha20 key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	unwrapped, err := key.Decrypt(wrappedDataKey)
+	if err != nil {
+		t.Errorf("failed to unwrap data key using ChaCha20 key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if len(unwrapped) != vault.DataKeySize {
+		t.Errorf("expected %d-byte data key; got %d bytes", vault.DataKeySize, len(unwrapped))
+		return
+	}
+}
+
+func TestGenerateDataKeyInvalidSpec(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for data key unit test!")
+		return
+	}
+
+	key, err := vault.Ed25519Factory(vaultDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create Ed25519 keypair for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	_, _, err = key.GenerateDataKey()
+	if err == nil {
+		t.Errorf("generated data key using Ed25519 keypair: %s", key.ID)
+		return
+	}
+}
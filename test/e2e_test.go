@@ -40,7 +40,7 @@ func keyFactoryWithSeed(token, vaultID, keyType, keyUsage, keySpec, keyName, key
 }
 
 func init() {
-	token, err := userTokenFactory()
+	token, err := operatorTokenFactory()
 	if err != nil {
 		log.Printf("failed to create token; %s", err.Error())
 		return
@@ -86,13 +86,19 @@ func TestSealUnsealer(t *testing.T) {
 	//get the vault unsealed to make sure other tests can continue
 	defer unsealVault()
 
-	token, err := userTokenFactory()
+	operatorToken, err := operatorTokenFactory()
 	if err != nil {
-		log.Printf("failed to create token; %s", err.Error())
+		t.Errorf("failed to create operator token; %s", err.Error())
 		return
 	}
 
-	_, err = provide.Unseal(token, map[string]interface{}{
+	userToken, err := userTokenFactory()
+	if err != nil {
+		t.Errorf("failed to create token; %s", err.Error())
+		return
+	}
+
+	_, err = provide.Unseal(operatorToken, map[string]interface{}{
 		"key": "traffic charge swing glimpse will citizen push mutual embrace volcano siege identify gossip battle casual exit enrich unlock muscle vast female initial please day",
 	})
 	if err != nil {
@@ -100,13 +106,36 @@ func TestSealUnsealer(t *testing.T) {
 		return
 	}
 
-	_, err = vaultFactory(*token, "vaulty vault", "just a boring vaulty vault")
+	// a bearer which is not an operator must not be able to generate unsealer keys, seal or unseal the vault
+	_, err = provide.GenerateSeal(*userToken, map[string]interface{}{})
+	if err == nil {
+		t.Errorf("generated unsealer key without operator authorization")
+		return
+	}
+
+	_, err = provide.Seal(*userToken, map[string]interface{}{
+		"key": "traffic charge swing glimpse will citizen push mutual embrace volcano siege identify gossip battle casual exit enrich unlock muscle vast female initial please day",
+	})
+	if err == nil {
+		t.Errorf("**vault sealed without operator authorization**")
+		return
+	}
+
+	_, err = provide.Unseal(userToken, map[string]interface{}{
+		"key": "traffic charge swing glimpse will citizen push mutual embrace volcano siege identify gossip battle casual exit enrich unlock muscle vast female initial please day",
+	})
+	if err == nil {
+		t.Errorf("**vault unsealed without operator authorization**")
+		return
+	}
+
+	_, err = vaultFactory(*userToken, "vaulty vault", "just a boring vaulty vault")
 	if err != nil {
 		t.Errorf("failed to create vault; %s", err.Error())
 		return
 	}
 
-	_, err = provide.Seal(*token, map[string]interface{}{
+	_, err = provide.Seal(*operatorToken, map[string]interface{}{
 		"key": "traffic charge swing glimpse will citizen push mutual embrace volcano siege identify gossip battle casual exit enrich unlock muscle vast female initial please day",
 	})
 	if err != nil {
@@ -114,13 +143,13 @@ func TestSealUnsealer(t *testing.T) {
 		return
 	}
 
-	_, err = vaultFactory(*token, "vaulty vault", "just a boring vaulty vault")
+	_, err = vaultFactory(*userToken, "vaulty vault", "just a boring vaulty vault")
 	if err == nil {
 		t.Errorf("performed operation while sealed!")
 		return
 	}
 
-	_, err = provide.Unseal(token, map[string]interface{}{
+	_, err = provide.Unseal(operatorToken, map[string]interface{}{
 		"key": "traffic charge swing glimpse will citizen push mutual embrace volcano siege identify gossip battle casual exit enrich unlock muscle vast female initial please day",
 	})
 	if err != nil {
@@ -128,14 +157,14 @@ func TestSealUnsealer(t *testing.T) {
 		return
 	}
 
-	_, err = vaultFactory(*token, "vaulty vault", "just a boring vaulty vault")
+	_, err = vaultFactory(*userToken, "vaulty vault", "just a boring vaulty vault")
 	if err != nil {
 		t.Errorf("failed to create vault; %s", err.Error())
 		return
 	}
 
 	// now we'll try to seal it badly and expect it to continue working
-	_, err = provide.Seal(*token, map[string]interface{}{
+	_, err = provide.Seal(*operatorToken, map[string]interface{}{
 		"key": "raffic charge swing glimpse will citizen push mutual embrace volcano siege identify gossip battle casual exit enrich unlock muscle vast female initial please day",
 	})
 	if err == nil {
@@ -143,14 +172,14 @@ func TestSealUnsealer(t *testing.T) {
 		return
 	}
 
-	_, err = vaultFactory(*token, "vaulty vault", "just a boring vaulty vault")
+	_, err = vaultFactory(*userToken, "vaulty vault", "just a boring vaulty vault")
 	if err != nil {
 		t.Errorf("failed to create vault; %s", err.Error())
 		return
 	}
 
 	// now we'll seal it and unseal it badly and expect it to fail
-	_, err = provide.Seal(*token, map[string]interface{}{
+	_, err = provide.Seal(*operatorToken, map[string]interface{}{
 		"key": "traffic charge swing glimpse will citizen push mutual embrace volcano siege identify gossip battle casual exit enrich unlock muscle vast female initial please day",
 	})
 	if err != nil {
@@ -158,13 +187,13 @@ func TestSealUnsealer(t *testing.T) {
 		return
 	}
 
-	_, err = vaultFactory(*token, "vaulty vault", "just a boring vaulty vault")
+	_, err = vaultFactory(*userToken, "vaulty vault", "just a boring vaulty vault")
 	if err == nil {
 		t.Errorf("performed operation while sealed!")
 		return
 	}
 
-	_, err = provide.Unseal(token, map[string]interface{}{
+	_, err = provide.Unseal(operatorToken, map[string]interface{}{
 		"key": "raffic charge swing glimpse will citizen push mutual embrace volcano siege identify gossip battle casual exit enrich unlock muscle vast female initial please day",
 	})
 	if err == nil {
@@ -172,14 +201,14 @@ func TestSealUnsealer(t *testing.T) {
 		return
 	}
 
-	_, err = vaultFactory(*token, "vaulty vault", "just a boring vaulty vault")
+	_, err = vaultFactory(*userToken, "vaulty vault", "just a boring vaulty vault")
 	if err == nil {
 		t.Errorf("created vault while sealed!")
 		return
 	}
 
 	//finish up with a valid unseal, before the additional deferred unseal
-	_, err = provide.Unseal(token, map[string]interface{}{
+	_, err = provide.Unseal(operatorToken, map[string]interface{}{
 		"key": "traffic charge swing glimpse will citizen push mutual embrace volcano siege identify gossip battle casual exit enrich unlock muscle vast female initial please day",
 	})
 	if err != nil {
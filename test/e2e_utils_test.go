@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os"
 
 	uuid "github.com/kthomas/go.uuid"
 	"github.com/provideplatform/ident/common"
@@ -111,8 +112,27 @@ func userTokenFactory() (*string, error) {
 	return resp.Token.AccessToken, nil
 }
 
+// operatorTokenFactory vends an access token for the application authorized by VAULT_REFRESH_TOKEN,
+// which is configured as an operator subject of the vault under test; the token is authorized to
+// generate unsealer keys, seal and unseal the vault
+func operatorTokenFactory() (*string, error) {
+	refreshToken := os.Getenv("VAULT_REFRESH_TOKEN")
+	if refreshToken == "" {
+		return nil, errors.New("operator token requires VAULT_REFRESH_TOKEN")
+	}
+
+	resp, err := ident.CreateToken(refreshToken, map[string]interface{}{
+		"grant_type": "refresh_token",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to vend operator token; %s", err.Error())
+	}
+
+	return resp.AccessToken, nil
+}
+
 func init() {
-	token, err := userTokenFactory()
+	token, err := operatorTokenFactory()
 	if err != nil {
 		log.Printf("failed to create token; %s", err.Error())
 		return
@@ -147,7 +167,7 @@ func init() {
 }
 
 func unsealVault() error {
-	token, err := userTokenFactory()
+	token, err := operatorTokenFactory()
 	if err != nil {
 		return fmt.Errorf("failed to create token; %s", err.Error())
 
@@ -0,0 +1,87 @@
+// +build unit
+
+package test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/provideplatform/vault/common"
+	"github.com/provideplatform/vault/vault"
+)
+
+func TestDataEncodingRoundTrip(t *testing.T) {
+	data := []byte{0x00, 0xff, 0xfe, 0x80, 0x7f, 0x3e, 0x3f}
+
+	for _, encoding := range []string{vault.DataEncodingHex, vault.DataEncodingBase64, vault.DataEncodingBase64URL} {
+		encoded, err := vault.EncodeData(data, encoding)
+		if err != nil {
+			t.Errorf("failed to encode binary data using %s encoding; %s", encoding, err.Error())
+			return
+		}
+
+		decoded, err := vault.DecodeData(encoded, encoding)
+		if err != nil {
+			t.Errorf("failed to decode binary data using %s encoding; %s", encoding, err.Error())
+			return
+		}
+
+		if !bytes.Equal(decoded, data) {
+			t.Errorf("decoded data does not match using %s encoding", encoding)
+			return
+		}
+	}
+}
+
+func TestDataEncodingUTF8RejectsBinary(t *testing.T) {
+	_, err := vault.EncodeData([]byte{0xff, 0xfe}, vault.DataEncodingUTF8)
+	if err == nil {
+		t.Error("encoded invalid utf8 data using utf8 encoding")
+		return
+	}
+
+	encoded, err := vault.EncodeData([]byte("héllo"), vault.DataEncodingUTF8)
+	if err != nil || encoded != "héllo" {
+		t.Error("failed to encode valid utf8 data using utf8 encoding")
+		return
+	}
+}
+
+func TestDataEncodingBase64URLPadding(t *testing.T) {
+	padded, err := vault.DecodeData("_-8=", vault.DataEncodingBase64URL)
+	if err != nil {
+		t.Errorf("failed to decode padded base64url data; %s", err.Error())
+		return
+	}
+
+	unpadded, err := vault.DecodeData("_-8", vault.DataEncodingBase64URL)
+	if err != nil {
+		t.Errorf("failed to decode unpadded base64url data; %s", err.Error())
+		return
+	}
+
+	if !bytes.Equal(padded, []byte{0xff, 0xef}) || !bytes.Equal(padded, unpadded) {
+		t.Error("decoded base64url data does not match")
+		return
+	}
+}
+
+func TestValidateDataEncoding(t *testing.T) {
+	encoding, err := vault.ValidateDataEncoding(nil, vault.DataEncodingHex)
+	if err != nil || encoding != vault.DataEncodingHex {
+		t.Error("failed to resolve default data encoding")
+		return
+	}
+
+	encoding, err = vault.ValidateDataEncoding(common.StringOrNil("Base64URL"), vault.DataEncodingHex)
+	if err != nil || encoding != vault.DataEncodingBase64URL {
+		t.Error("failed to validate data encoding case-insensitively")
+		return
+	}
+
+	_, err = vault.ValidateDataEncoding(common.StringOrNil("base32"), vault.DataEncodingHex)
+	if err == nil {
+		t.Error("validated invalid data encoding")
+		return
+	}
+}
@@ -0,0 +1,67 @@
+// +build unit
+
+package test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/provideplatform/vault/common"
+	"github.com/provideplatform/vault/vault"
+)
+
+func TestCiphertextEnvelopeRoundTrip(t *testing.T) {
+	ciphertext, _ := common.RandomBytes(44)
+	envelope := &vault.CiphertextEnvelope{
+		KeyVersion: 3,
+		Algorithm:  vault.KeySpecAES256GCM,
+		Ciphertext: ciphertext,
+	}
+
+	serialized := envelope.String()
+	if !strings.HasPrefix(serialized, "vault:v1:3:aes-256-gcm:") {
+		t.Errorf("unexpected ciphertext envelope serialization: %s", serialized)
+		return
+	}
+
+	if !vault.IsCiphertextEnvelope(serialized) {
+		t.Errorf("serialized ciphertext envelope not recognized: %s", serialized)
+		return
+	}
+
+	parsed, err := vault.ParseCiphertextEnvelope(serialized)
+	if err != nil {
+		t.Errorf("failed to parse ciphertext envelope; %s", err.Error())
+		return
+	}
+
+	if parsed.KeyVersion != 3 || !strings.EqualFold(parsed.Algorithm, vault.KeySpecAES256GCM) || !bytes.Equal(parsed.Ciphertext, ciphertext) {
+		t.Errorf("parsed ciphertext envelope does not match; %+v", parsed)
+		return
+	}
+}
+
+func TestCiphertextEnvelopeRawHexNotEnvelope(t *testing.T) {
+	if vault.IsCiphertextEnvelope("deadbeef") {
+		t.Error("raw hex ciphertext recognized as ciphertext envelope")
+		return
+	}
+}
+
+func TestParseCiphertextEnvelopeMalformed(t *testing.T) {
+	for _, data := range []string{
+		"vault:v1:0:aes-256-gcm",
+		"vault:v2:0:aes-256-gcm:AAAA",
+		"vault:v1:x:aes-256-gcm:AAAA",
+		"vault:v1:-1:aes-256-gcm:AAAA",
+		"vault:v1:0::AAAA",
+		"vault:v1:0:aes-256-gcm:not base64!",
+	} {
+		_, err := vault.ParseCiphertextEnvelope(data)
+		if err == nil {
+			t.Errorf("parsed malformed ciphertext envelope: %s", data)
+			return
+		}
+	}
+}
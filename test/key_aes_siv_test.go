@@ -0,0 +1,170 @@
+// +build unit
+
+package test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	uuid "github.com/kthomas/go.uuid"
+	"github.com/provideplatform/vault/common"
+	vaultcrypto "github.com/provideplatform/vault/crypto"
+	"github.com/provideplatform/vault/vault"
+)
+
+func TestAESSIVDeterministicRFC5297(t *testing.T) {
+	// RFC 5297, appendix A.1
+	privateKey, _ := hex.DecodeString("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	aad, _ := hex.DecodeString("101112131415161718191a1b1c1d1e1f2021222324252627")
+	plaintext, _ := hex.DecodeString("112233445566778899aabbccddee")
+	expected, _ := hex.DecodeString("85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c")
+
+	key := vaultcrypto.AES256SIV{
+		PrivateKey: privateKey,
+	}
+
+	ciphertext, err := key.EncryptDeterministic(plaintext, aad)
+	if err != nil {
+		t.Errorf("failed to encrypt using AES-SIV key; %s", err.Error())
+		return
+	}
+
+	if !bytes.Equal(ciphertext, expected) {
+		t.Errorf("AES-SIV ciphertext does not match RFC 5297 test vector; got %x", ciphertext)
+		return
+	}
+
+	decrypted, err := key.DecryptDeterministic(ciphertext, aad)
+	if err != nil {
+		t.Errorf("failed to decrypt using AES-SIV key; %s", err.Error())
+		return
+	}
+
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Error("decrypted plaintext does not match using AES-SIV key")
+		return
+	}
+
+	_, err = key.DecryptDeterministic(ciphertext, []byte("not the aad"))
+	if err == nil {
+		t.Error("decrypted ciphertext with mismatched aad using AES-SIV key")
+		return
+	}
+}
+
+func TestAES256SIVEncryptDecrypt(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for AES-256-SIV key unit test!")
+		return
+	}
+
+	key, err := vault.AES256SIVFactory(vaultDB, &vlt.ID, "test key", "just some key :D", false)
+	if err != nil {
+		t.Errorf("failed to create AES-256-SIV key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	plaintext := []byte(common.RandomString(128))
+	aad := []byte("record:1")
+
+	ciphertext, err := key.EncryptWithAAD(plaintext, nil, aad)
+	if err != nil {
+		t.Errorf("failed to encrypt using AES-256-SIV key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	again, _ := key.EncryptWithAAD(plaintext, nil, aad)
+	if bytes.Equal(ciphertext, again) {
+		t.Errorf("non-deterministic encryption using AES-256-SIV key: %s produced identical ciphertext", key.ID)
+		return
+	}
+
+	decrypted, err := key.DecryptWithAAD(ciphertext, aad)
+	if err != nil {
+		t.Errorf("failed to decrypt using AES-256-SIV key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("decrypted plaintext does not match using AES-256-SIV key: %s", key.ID)
+		return
+	}
+
+	_, err = key.EncryptDeterministic(plaintext, aad)
+	if err == nil {
+		t.Errorf("deterministically encrypted using AES-256-SIV key: %s without deterministic enabled", key.ID)
+		return
+	}
+}
+
+func TestAES256SIVEncryptDeterministic(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for AES-256-SIV key unit test!")
+		return
+	}
+
+	key, err := vault.AES256SIVFactory(vaultDB, &vlt.ID, "test key", "just some key :D", true)
+	if err != nil {
+		t.Errorf("failed to create deterministic AES-256-SIV key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	plaintext := []byte("someone@example.com")
+	aad := []byte("users.email")
+
+	ciphertext, err := key.EncryptDeterministic(plaintext, aad)
+	if err != nil {
+		t.Errorf("failed to deterministically encrypt using AES-256-SIV key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	again, err := key.EncryptDeterministic(plaintext, aad)
+	if err != nil || !bytes.Equal(ciphertext, again) {
+		t.Errorf("deterministic encryption using AES-256-SIV key: %s produced different ciphertext", key.ID)
+		return
+	}
+
+	other, _ := key.EncryptDeterministic(plaintext, []byte("users.backup_email"))
+	if bytes.Equal(ciphertext, other) {
+		t.Errorf("deterministic encryption using AES-256-SIV key: %s produced identical ciphertext for different context", key.ID)
+		return
+	}
+
+	decrypted, _, err := key.DecryptDeterministicVersioned(vaultDB, ciphertext, aad, nil)
+	if err != nil {
+		t.Errorf("failed to deterministically decrypt using AES-256-SIV key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("decrypted plaintext does not match using AES-256-SIV key: %s", key.ID)
+		return
+	}
+}
+
+func TestDeterministicRequiresAES256SIV(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for deterministic key unit test!")
+		return
+	}
+
+	deterministic := true
+	key := &vault.Key{
+		VaultID:       &vlt.ID,
+		Name:          common.StringOrNil("test key"),
+		Description:   common.StringOrNil("just some key :D"),
+		Spec:          common.StringOrNil(vault.KeySpecAES256GCM),
+		Type:          common.StringOrNil(vault.KeyTypeSymmetric),
+		Usage:         common.StringOrNil(vault.KeyUsageEncryptDecrypt),
+		Deterministic: &deterministic,
+	}
+
+	if key.Validate() {
+		t.Errorf("validated deterministic %s key", vault.KeySpecAES256GCM)
+		return
+	}
+}
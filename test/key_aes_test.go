@@ -276,3 +276,49 @@ func TestEncryptAndDecryptSymmetricAESNoErrorsOptionalNonce(t *testing.T) {
 	}
 	common.Log.Debug("decrypted ciphertext is identical to original plaintext")
 }
+
+func TestEncryptAndDecryptSymmetricAESWithAAD(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for AES-256-GCM key encrypt decrypt unit test!")
+		return
+	}
+
+	key, err := vault.AES256GCMFactory(aesKeyDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create AES-256-GCM key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	plaintext := []byte(common.RandomString(128))
+	aad := []byte("record:1")
+
+	ciphertext, err := key.EncryptWithAAD(plaintext, nil, aad)
+	if err != nil {
+		t.Errorf("failed! symmetric encryption with aad failed using AES-256-GCM key %s; %s", key.ID, err.Error())
+		return
+	}
+
+	decrypted, err := key.DecryptWithAAD(ciphertext, aad)
+	if err != nil {
+		t.Errorf("failed! symmetric decryption with aad failed using AES-256-GCM key %s; %s", key.ID, err.Error())
+		return
+	}
+
+	if string(decrypted) != string(plaintext) {
+		t.Errorf("failed! decrypted plaintext does not match using AES-256-GCM key %s", key.ID)
+		return
+	}
+
+	_, err = key.DecryptWithAAD(ciphertext, []byte("record:2"))
+	if err == nil {
+		t.Errorf("failed! decrypted ciphertext with mismatched aad using AES-256-GCM key %s", key.ID)
+		return
+	}
+
+	_, err = key.Decrypt(ciphertext)
+	if err == nil {
+		t.Errorf("failed! decrypted ciphertext without aad using AES-256-GCM key %s", key.ID)
+		return
+	}
+}
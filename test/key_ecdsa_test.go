@@ -0,0 +1,153 @@
+// +build unit
+
+package test
+
+import (
+	"crypto/elliptic"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	uuid "github.com/kthomas/go.uuid"
+	"github.com/provideplatform/vault/common"
+	vaultcrypto "github.com/provideplatform/vault/crypto"
+	"github.com/provideplatform/vault/vault"
+)
+
+func TestECDSASignatureFormats(t *testing.T) {
+	curves := map[elliptic.Curve]int{
+		elliptic.P256(): 64,
+		elliptic.P384(): 96,
+		elliptic.P521(): 132,
+	}
+
+	for curve, rawSize := range curves {
+		keypair, err := vaultcrypto.CreateECDSAKeyPair(curve)
+		if err != nil {
+			t.Errorf("failed to create %s keypair; %s", curve.Params().Name, err.Error())
+			return
+		}
+
+		msg := []byte(common.RandomString(64))
+
+		raw, err := keypair.Sign(msg, vaultcrypto.ECDSASignatureFormatRaw)
+		if err != nil {
+			t.Errorf("failed to sign using %s keypair; %s", curve.Params().Name, err.Error())
+			return
+		}
+
+		if len(raw) != rawSize {
+			t.Errorf("expected %d-byte raw signature using %s keypair; got %d bytes", rawSize, curve.Params().Name, len(raw))
+			return
+		}
+
+		der, err := keypair.Sign(msg, vaultcrypto.ECDSASignatureFormatDER)
+		if err != nil || der[0] != 0x30 {
+			t.Errorf("failed to sign using %s keypair with der signature format", curve.Params().Name)
+			return
+		}
+
+		verifier := vaultcrypto.ECDSAKeyPair{
+			Curve:     curve,
+			PublicKey: keypair.PublicKey,
+		}
+
+		if verifier.Verify(msg, raw, vaultcrypto.ECDSASignatureFormatRaw) != nil {
+			t.Errorf("failed to verify raw signature using %s public key", curve.Params().Name)
+			return
+		}
+
+		if verifier.Verify(msg, der, vaultcrypto.ECDSASignatureFormatDER) != nil {
+			t.Errorf("failed to verify der signature using %s public key", curve.Params().Name)
+			return
+		}
+
+		if verifier.Verify(msg, raw, vaultcrypto.ECDSASignatureFormatDER) == nil {
+			t.Errorf("verified raw signature as der using %s public key", curve.Params().Name)
+			return
+		}
+
+		if verifier.Verify([]byte("some other message"), der, vaultcrypto.ECDSASignatureFormatDER) == nil {
+			t.Errorf("verified signature of other message using %s public key", curve.Params().Name)
+			return
+		}
+
+		pemPublicKey, err := verifier.PublicKeyPEM()
+		if err != nil {
+			t.Errorf("failed to encode %s public key as pem; %s", curve.Params().Name, err.Error())
+			return
+		}
+
+		decoded, err := vaultcrypto.ECDSAPublicKeyFromPEM(curve, pemPublicKey)
+		if err != nil || hex.EncodeToString(decoded) != hex.EncodeToString(keypair.PublicKey) {
+			t.Errorf("failed to decode pem-encoded %s public key", curve.Params().Name)
+			return
+		}
+	}
+}
+
+func TestECDSAKeySignVerify(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for ECDSA key unit test!")
+		return
+	}
+
+	for _, spec := range []string{vault.KeySpecECCNISTP256, vault.KeySpecECCNISTP384, vault.KeySpecECCNISTP521} {
+		key, err := vault.ECDSAFactory(vaultDB, &vlt.ID, spec, "test key", "just some key :D")
+		if err != nil {
+			t.Errorf("failed to create %s keypair for vault: %s; Error: %s", spec, vlt.ID, err.Error())
+			return
+		}
+
+		if key.PublicKeyHex == nil || !strings.HasPrefix(*key.PublicKeyHex, "-----BEGIN PUBLIC KEY-----") {
+			t.Errorf("expected pem-encoded public key for %s key: %s", spec, key.ID)
+			return
+		}
+
+		msg := []byte(common.RandomString(32))
+		opts := &vault.SigningOptions{
+			SignatureFormat: common.StringOrNil(vaultcrypto.ECDSASignatureFormatRaw),
+		}
+
+		sig, err := key.Sign(msg, opts)
+		if err != nil {
+			t.Errorf("failed to sign using %s key: %s; Error: %s", spec, key.ID, err.Error())
+			return
+		}
+
+		err = key.Verify(msg, sig, opts)
+		if err != nil {
+			t.Errorf("failed to verify using %s key: %s; Error: %s", spec, key.ID, err.Error())
+			return
+		}
+
+		err = key.Verify(msg, sig, nil)
+		if err == nil {
+			t.Errorf("verified raw signature as der using %s key: %s", spec, key.ID)
+			return
+		}
+	}
+}
+
+func TestECDSAKeyAlgorithmMismatch(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for ECDSA key unit test!")
+		return
+	}
+
+	key, err := vault.ECDSAFactory(vaultDB, &vlt.ID, vault.KeySpecECCNISTP256, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create ECC-NIST-P256 keypair for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	_, err = key.Sign([]byte("hello"), &vault.SigningOptions{
+		Algorithm: common.StringOrNil("ES512"),
+	})
+	if err == nil {
+		t.Errorf("signed using ES512 algorithm with ECC-NIST-P256 key: %s", key.ID)
+		return
+	}
+}
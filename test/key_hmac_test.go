@@ -0,0 +1,127 @@
+// +build unit
+
+package test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	uuid "github.com/kthomas/go.uuid"
+	"github.com/provideplatform/vault/common"
+	vaultcrypto "github.com/provideplatform/vault/crypto"
+	"github.com/provideplatform/vault/vault"
+)
+
+func TestHMACSHA256RFC4231(t *testing.T) {
+	// RFC 4231, test case 2
+	key := vaultcrypto.HMAC{
+		PrivateKey: []byte("Jefe"),
+		Hash:       sha256.New,
+	}
+
+	mac, err := key.MAC([]byte("what do ya want for nothing?"))
+	if err != nil {
+		t.Errorf("failed to compute HMAC-SHA256; %s", err.Error())
+		return
+	}
+
+	if hex.EncodeToString(mac) != "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843" {
+		t.Errorf("HMAC-SHA256 does not match RFC 4231 test vector; got %x", mac)
+		return
+	}
+
+	err = key.Verify([]byte("what do ya want for nothing?"), mac)
+	if err != nil {
+		t.Errorf("failed to verify HMAC-SHA256; %s", err.Error())
+		return
+	}
+
+	err = key.Verify([]byte("what do ya want for everything?"), mac)
+	if err == nil {
+		t.Error("verified HMAC-SHA256 of modified message")
+		return
+	}
+}
+
+func TestHMACMACAndVerify(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for HMAC key unit test!")
+		return
+	}
+
+	sizes := map[string]int{
+		vault.KeySpecHMACSHA256: 32,
+		vault.KeySpecHMACSHA384: 48,
+		vault.KeySpecHMACSHA512: 64,
+	}
+
+	for spec, size := range sizes {
+		key, err := vault.HMACFactory(vaultDB, &vlt.ID, spec, "test key", "just some key :D")
+		if err != nil {
+			t.Errorf("failed to create %s key for vault: %s; Error: %s", spec, vlt.ID, err.Error())
+			return
+		}
+
+		msg := []byte(common.RandomString(128))
+		mac, err := key.MAC(msg)
+		if err != nil {
+			t.Errorf("failed to compute mac using %s key: %s; Error: %s", spec, key.ID, err.Error())
+			return
+		}
+
+		if len(mac) != size {
+			t.Errorf("expected %d-byte mac using %s key: %s; got %d bytes", size, spec, key.ID, len(mac))
+			return
+		}
+
+		err = key.VerifyMAC(msg, mac)
+		if err != nil {
+			t.Errorf("failed to verify mac using %s key: %s; Error: %s", spec, key.ID, err.Error())
+			return
+		}
+
+		mac[0] ^= 0x01
+		err = key.VerifyMAC(msg, mac)
+		if err == nil {
+			t.Errorf("verified modified mac using %s key: %s", spec, key.ID)
+			return
+		}
+
+		_, err = key.Encrypt(msg, nil)
+		if err == nil {
+			t.Errorf("encrypted using %s key: %s", spec, key.ID)
+			return
+		}
+	}
+}
+
+func TestHMACVerifyRotated(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for HMAC key unit test!")
+		return
+	}
+
+	key, err := vault.HMACFactory(vaultDB, &vlt.ID, vault.KeySpecHMACSHA256, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create HMAC-SHA256 key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	msg := []byte(common.RandomString(32))
+	mac, _ := key.MAC(msg)
+
+	err = key.Rotate(vaultDB)
+	if err != nil {
+		t.Errorf("failed to rotate HMAC-SHA256 key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	version, err := key.VerifyMACVersioned(vaultDB, msg, mac, nil)
+	if err != nil || version != 0 {
+		t.Errorf("failed to verify mac using previous version of key: %s", key.ID)
+		return
+	}
+}
@@ -0,0 +1,162 @@
+// +build unit
+
+package test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	uuid "github.com/kthomas/go.uuid"
+	"github.com/provideplatform/vault/common"
+	vaultcrypto "github.com/provideplatform/vault/crypto"
+	"github.com/provideplatform/vault/vault"
+)
+
+func TestHPKEOpenRFC9180(t *testing.T) {
+	// RFC 9180, appendix A.1.1: DHKEM(X25519, HKDF-SHA256), HKDF-SHA256, AES-128-GCM
+	skR, _ := hex.DecodeString("4612c550263fc8ad58375df3f557aac531d26850903e55a9f23f21d8534e8ac8")
+	enc, _ := hex.DecodeString("37fda3567bdbd628e88668c3c8d7e97d1d1253b6d4ea6d44c150f741f1bf4431")
+	ct, _ := hex.DecodeString("f938558b5d72f1a23810b4be2ab4f84331acc02fc97babc53a52ae8218a355a96d8770ac83d07bea87e13c512a")
+	info, _ := hex.DecodeString("4f6465206f6e2061204772656369616e2055726e")
+	aad, _ := hex.DecodeString("436f756e742d30")
+
+	key := vaultcrypto.HPKE{
+		PrivateKey: skR,
+		AEAD:       vaultcrypto.HPKEAEADAES128GCM,
+	}
+
+	plaintext, err := key.Open(append(enc, ct...), info, aad)
+	if err != nil {
+		t.Errorf("failed to open RFC 9180 test vector; %s", err.Error())
+		return
+	}
+
+	if string(plaintext) != "Beauty is truth, truth beauty" {
+		t.Errorf("HPKE plaintext does not match RFC 9180 test vector; got %s", string(plaintext))
+		return
+	}
+
+	_, err = key.Open(append(enc, ct...), info, []byte("Count-1"))
+	if err == nil {
+		t.Error("opened RFC 9180 test vector using modified aad")
+		return
+	}
+}
+
+func TestEncryptDecryptC25519(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for C25519 encryption unit test!")
+		return
+	}
+
+	key, err := vault.C25519Factory(vaultDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create C25519 keypair for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	// encrypt to the public key only, as an external party would
+	external := vaultcrypto.C25519{PublicKey: *key.PublicKey}
+	plaintext := []byte(common.RandomString(128))
+	aad := []byte("context")
+	ciphertext, err := external.Encrypt(plaintext, nil, aad)
+	if err != nil {
+		t.Errorf("failed to encrypt to C25519 public key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	decrypted, err := key.DecryptWithAAD(ciphertext, aad)
+	if err != nil {
+		t.Errorf("failed to decrypt using C25519 keypair: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if string(decrypted) != string(plaintext) {
+		t.Errorf("decrypted plaintext does not match using C25519 keypair: %s", key.ID)
+		return
+	}
+
+	_, err = key.Decrypt(ciphertext)
+	if err == nil {
+		t.Errorf("decrypted ciphertext without aad using C25519 keypair: %s", key.ID)
+		return
+	}
+
+	ciphertext, err = key.Encrypt(plaintext, nil)
+	if err != nil {
+		t.Errorf("failed to encrypt using C25519 keypair: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	decrypted, err = key.Decrypt(ciphertext)
+	if err != nil || string(decrypted) != string(plaintext) {
+		t.Errorf("failed to decrypt using C25519 keypair: %s", key.ID)
+		return
+	}
+}
+
+func TestEncryptDecryptSecp256k1(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for secp256k1 encryption unit test!")
+		return
+	}
+
+	key, err := vault.Secp256k1Factory(vaultDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create secp256k1 keypair for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	plaintext := []byte(common.RandomString(128))
+	aad := []byte("context")
+	ciphertext, err := key.EncryptWithAAD(plaintext, nil, aad)
+	if err != nil {
+		t.Errorf("failed to encrypt using secp256k1 keypair: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	decrypted, err := key.DecryptWithAAD(ciphertext, aad)
+	if err != nil {
+		t.Errorf("failed to decrypt using secp256k1 keypair: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if string(decrypted) != string(plaintext) {
+		t.Errorf("decrypted plaintext does not match using secp256k1 keypair: %s", key.ID)
+		return
+	}
+
+	_, err = key.DecryptWithAAD(ciphertext, []byte("other context"))
+	if err == nil {
+		t.Errorf("decrypted ciphertext with modified aad using secp256k1 keypair: %s", key.ID)
+		return
+	}
+}
+
+func TestEncryptUnsupportedAsymmetricSpec(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for unsupported encryption unit test!")
+		return
+	}
+
+	key, err := vault.Ed25519Factory(vaultDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create Ed25519 keypair for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	ciphertext, err := key.Encrypt([]byte(common.RandomString(128)), nil)
+	if err == nil {
+		t.Errorf("encrypted using Ed25519 keypair: %s; got %d-byte ciphertext", key.ID, len(ciphertext))
+		return
+	}
+
+	_, err = key.Decrypt([]byte(common.RandomString(128)))
+	if err == nil {
+		t.Errorf("decrypted using Ed25519 keypair: %s", key.ID)
+		return
+	}
+}
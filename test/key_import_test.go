@@ -0,0 +1,301 @@
+// +build unit
+
+package test
+
+import (
+	"encoding/hex"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	uuid "github.com/kthomas/go.uuid"
+	"github.com/provideplatform/vault/common"
+	vaultcrypto "github.com/provideplatform/vault/crypto"
+	"github.com/provideplatform/vault/vault"
+)
+
+func TestAESKeyWrapPadRFC5649(t *testing.T) {
+	// RFC 5649, section 6: 192-bit KEK with 20- and 7-octet key material
+	kek, _ := hex.DecodeString("5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8")
+	vectors := map[string]string{
+		"c37b7e6492584340bed12207808941155068f738": "138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a",
+		"466f7250617369": "afbeb0f07dfbf5419200f2ccb50bb24f",
+	}
+
+	for key, expected := range vectors {
+		plaintext, _ := hex.DecodeString(key)
+		wrapped, err := vaultcrypto.AESKeyWrapPad(kek, plaintext)
+		if err != nil {
+			t.Errorf("failed to wrap RFC 5649 test vector; %s", err.Error())
+			return
+		}
+
+		if hex.EncodeToString(wrapped) != expected {
+			t.Errorf("wrapped key does not match RFC 5649 test vector; got %s", hex.EncodeToString(wrapped))
+			return
+		}
+
+		unwrapped, err := vaultcrypto.AESKeyUnwrapPad(kek, wrapped)
+		if err != nil || hex.EncodeToString(unwrapped) != key {
+			t.Error("failed to unwrap RFC 5649 test vector")
+			return
+		}
+
+		wrapped[len(wrapped)-1] ^= 0x01
+		_, err = vaultcrypto.AESKeyUnwrapPad(kek, wrapped)
+		if err == nil {
+			t.Error("unwrapped modified RFC 5649 test vector")
+			return
+		}
+	}
+}
+
+// wrapKeyMaterial wraps the key material using the PEM-encoded wrapping key, as a client would
+func wrapKeyMaterial(t *testing.T, params *vault.KeyImportParamsRequestResponse, material []byte) []byte {
+	block, _ := pem.Decode([]byte(*params.WrappingKey))
+	if block == nil {
+		t.Error("failed to decode PEM-encoded wrapping key")
+		return nil
+	}
+
+	wrappingKey := vaultcrypto.RSAKeyPair{PublicKey: block.Bytes}
+	wrapped, err := wrappingKey.Wrap(material, *params.WrappingAlgorithm)
+	if err != nil {
+		t.Errorf("failed to wrap key material; %s", err.Error())
+		return nil
+	}
+
+	return wrapped
+}
+
+func TestImportAES256GCMKey(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for key import unit test!")
+		return
+	}
+
+	keyImport, err := vault.CreateKeyImport(vaultDB, vlt, vault.KeySpecAES256GCM, vaultcrypto.KeyWrapAlgorithmRSAOAEPSHA256)
+	if err != nil {
+		t.Errorf("failed to create key import for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	params, err := keyImport.ParamsResponse()
+	if err != nil {
+		t.Errorf("failed to resolve key import params; %s", err.Error())
+		return
+	}
+
+	material := []byte(common.RandomString(32))
+	wrapped := wrapKeyMaterial(t, params, material)
+	if wrapped == nil {
+		return
+	}
+
+	key := &vault.Key{
+		Name:        common.StringOrNil("imported key"),
+		Description: common.StringOrNil("imported AES-256-GCM key"),
+	}
+
+	importToken := vault.GetKeyImport(vaultDB, *params.ImportToken, vlt.ID)
+	err = importToken.Import(vaultDB, key, wrapped)
+	if err != nil {
+		t.Errorf("failed to import AES-256-GCM key material; %s", err.Error())
+		return
+	}
+
+	if key.ID == uuid.Nil || *key.Type != vault.KeyTypeSymmetric || *key.Usage != vault.KeyUsageEncryptDecrypt {
+		t.Errorf("imported AES-256-GCM key is invalid: %s", key.ID)
+		return
+	}
+
+	plaintext := []byte(common.RandomString(128))
+	ciphertext, err := key.Encrypt(plaintext, nil)
+	if err != nil {
+		t.Errorf("failed to encrypt using imported key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	// the imported key material is unchanged, so the ciphertext is decrypted using the original key
+	external := vaultcrypto.AES256GCM{PrivateKey: material}
+	decrypted, err := external.Decrypt(ciphertext[vaultcrypto.NonceSizeAES256GCM:], ciphertext[:vaultcrypto.NonceSizeAES256GCM])
+	if err != nil || string(decrypted) != string(plaintext) {
+		t.Errorf("failed to decrypt using original key material of imported key: %s", key.ID)
+		return
+	}
+
+	// the import token cannot be used again
+	importToken = vault.GetKeyImport(vaultDB, *params.ImportToken, vlt.ID)
+	err = importToken.Import(vaultDB, &vault.Key{Name: common.StringOrNil("imported key")}, wrapped)
+	if err == nil {
+		t.Errorf("imported key material using used import token: %s", importToken.ID)
+		return
+	}
+}
+
+func TestImportSecp256k1Key(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for key import unit test!")
+		return
+	}
+
+	keyImport, err := vault.CreateKeyImport(vaultDB, vlt, vault.KeySpecECCSecp256k1, vaultcrypto.KeyWrapAlgorithmRSAAESKeyWrapSHA256)
+	if err != nil {
+		t.Errorf("failed to create key import for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	params, _ := keyImport.ParamsResponse()
+
+	privateKey, _ := ethcrypto.GenerateKey()
+	wrapped := wrapKeyMaterial(t, params, ethcrypto.FromECDSA(privateKey))
+	if wrapped == nil {
+		return
+	}
+
+	key := &vault.Key{
+		Name: common.StringOrNil("imported key"),
+	}
+
+	err = keyImport.Import(vaultDB, key, wrapped)
+	if err != nil {
+		t.Errorf("failed to import secp256k1 key material; %s", err.Error())
+		return
+	}
+
+	if string(*key.PublicKey) != string(ethcrypto.FromECDSAPub(&privateKey.PublicKey)) {
+		t.Errorf("public key of imported secp256k1 key does not match: %s", key.ID)
+		return
+	}
+
+	payload := ethcrypto.Keccak256([]byte(common.RandomString(128)))
+	sig, err := key.Sign(payload, nil)
+	if err != nil {
+		t.Errorf("failed to sign using imported key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if !ethcrypto.VerifySignature(ethcrypto.FromECDSAPub(&privateKey.PublicKey), payload, sig[:64]) {
+		t.Errorf("signature of imported key does not verify using original key material: %s", key.ID)
+		return
+	}
+}
+
+func TestImportRSAKeyRequiresAESKeyWrap(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for key import unit test!")
+		return
+	}
+
+	_, err := vault.CreateKeyImport(vaultDB, vlt, vault.KeySpecRSA2048, vaultcrypto.KeyWrapAlgorithmRSAOAEPSHA256)
+	if err == nil {
+		t.Error("created RSA key import using RSAES-OAEP wrapping algorithm")
+		return
+	}
+
+	_, err = vault.CreateKeyImport(vaultDB, vlt, vault.KeySpecAES256GCM, "RSAES_OAEP_SHA_1")
+	if err == nil {
+		t.Error("created key import using unsupported wrapping algorithm")
+		return
+	}
+
+	keyImport, err := vault.CreateKeyImport(vaultDB, vlt, vault.KeySpecRSA2048, vaultcrypto.KeyWrapAlgorithmRSAAESKeyWrapSHA256)
+	if err != nil {
+		t.Errorf("failed to create RSA key import for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	params, _ := keyImport.ParamsResponse()
+
+	// a 3072-bit key cannot be imported as RSA-2048
+	rsaKeyPair, _ := vaultcrypto.CreateRSAKeyPair(vault.KeyBits3072)
+
+	wrapped := wrapKeyMaterial(t, params, rsaKeyPair.PrivateKey)
+	if wrapped == nil {
+		return
+	}
+
+	err = keyImport.Import(vaultDB, &vault.Key{Name: common.StringOrNil("imported key")}, wrapped)
+	if err == nil {
+		t.Error("imported 3072-bit key material as RSA-2048 key")
+		return
+	}
+}
+
+func TestImportKeyAfterMasterKeyRotation(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for key import unit test!")
+		return
+	}
+
+	keyImport, err := vault.CreateKeyImport(vaultDB, vlt, vault.KeySpecAES256GCM, vaultcrypto.KeyWrapAlgorithmRSAOAEPSHA256)
+	if err != nil {
+		t.Errorf("failed to create key import for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	params, _ := keyImport.ParamsResponse()
+
+	_, err = vlt.RotateMasterKey(vaultDB)
+	if err != nil {
+		t.Errorf("failed to rotate master key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	// the initial master key version is only retired once the pending key import has been re-wrapped
+	retired := false
+	for i := 0; i < 20 && !retired; i++ {
+		initialVersion := &vault.MasterKeyVersion{}
+		vaultDB.Where("vault_id = ? AND version = 0", vlt.ID).Find(&initialVersion)
+		retired = initialVersion.RetiredAt != nil
+		if !retired {
+			time.Sleep(time.Millisecond * 250)
+		}
+	}
+
+	if !retired {
+		t.Errorf("initial master key version not retired for vault: %s", vlt.ID)
+		return
+	}
+
+	importToken := vault.GetKeyImport(vaultDB, *params.ImportToken, vlt.ID)
+	if importToken.MasterKeyID == nil || *importToken.MasterKeyID != *vlt.MasterKeyID {
+		t.Errorf("wrapping key of pending key import not re-wrapped using active master key: %s", importToken.ID)
+		return
+	}
+
+	material := []byte(common.RandomString(32))
+	wrapped := wrapKeyMaterial(t, params, material)
+	if wrapped == nil {
+		return
+	}
+
+	key := &vault.Key{
+		Name: common.StringOrNil("imported key"),
+	}
+
+	err = importToken.Import(vaultDB, key, wrapped)
+	if err != nil {
+		t.Errorf("failed to import key material after master key rotation; %s", err.Error())
+		return
+	}
+
+	plaintext := []byte(common.RandomString(128))
+	ciphertext, err := key.Encrypt(plaintext, nil)
+	if err != nil {
+		t.Errorf("failed to encrypt using imported key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	external := vaultcrypto.AES256GCM{PrivateKey: material}
+	decrypted, err := external.Decrypt(ciphertext[vaultcrypto.NonceSizeAES256GCM:], ciphertext[:vaultcrypto.NonceSizeAES256GCM])
+	if err != nil || string(decrypted) != string(plaintext) {
+		t.Errorf("failed to decrypt using original key material of imported key: %s", key.ID)
+		return
+	}
+}
@@ -4,13 +4,17 @@ package test
 
 import (
 	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/hex"
+	"encoding/json"
 	"io"
 	"testing"
 
 	dbconf "github.com/kthomas/go-db-config"
 	uuid "github.com/kthomas/go.uuid"
 	"github.com/provideplatform/vault/common"
+	vaultcrypto "github.com/provideplatform/vault/crypto"
 	"github.com/provideplatform/vault/vault"
 )
 
@@ -715,3 +719,84 @@ func TestRSA2048VerifyNilOptions(t *testing.T) {
 
 	common.Log.Debugf("correctly failed to verify message with nil algorithm using rsa keypair for vault: %s with err %s", vlt.ID, err.Error())
 }
+
+func TestRSAKeyPairMigrateLegacyEncoding(t *testing.T) {
+	rsaPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Errorf("failed to generate rsa key; %s", err.Error())
+		return
+	}
+
+	privateKey, _ := json.Marshal(*rsaPrivateKey)
+	publicKey, _ := json.Marshal(rsaPrivateKey.PublicKey)
+	keypair := vaultcrypto.RSAKeyPair{
+		PrivateKey: privateKey,
+		PublicKey:  publicKey,
+	}
+
+	msg := []byte(common.RandomString(10))
+	sig, err := keypair.Sign(msg, "PS256")
+	if err != nil {
+		t.Errorf("failed to sign message using legacy rsa keypair; %s", err.Error())
+		return
+	}
+
+	migrated, err := keypair.Migrate()
+	if err != nil || !migrated {
+		t.Errorf("failed to migrate legacy rsa keypair; migrated: %v; err: %v", migrated, err)
+		return
+	}
+
+	if _, err := x509.ParsePKCS8PrivateKey(keypair.PrivateKey); err != nil {
+		t.Errorf("migrated rsa private key is not PKCS#8 encoded; %s", err.Error())
+		return
+	}
+
+	if _, err := x509.ParsePKIXPublicKey(keypair.PublicKey); err != nil {
+		t.Errorf("migrated rsa public key is not PKIX encoded; %s", err.Error())
+		return
+	}
+
+	err = keypair.Verify(msg, sig, "PS256")
+	if err != nil {
+		t.Errorf("failed to verify signature of legacy rsa keypair using migrated keypair; %s", err.Error())
+		return
+	}
+
+	migrated, err = keypair.Migrate()
+	if err != nil || migrated {
+		t.Errorf("re-migrated migrated rsa keypair; migrated: %v; err: %v", migrated, err)
+		return
+	}
+}
+
+func TestRSAKeyPairMalformedKeyMaterial(t *testing.T) {
+	keypair := vaultcrypto.RSAKeyPair{
+		PrivateKey: []byte(common.RandomString(64)),
+		PublicKey:  []byte(common.RandomString(64)),
+	}
+
+	_, err := keypair.Sign([]byte(common.RandomString(10)), "PS256")
+	if err == nil {
+		t.Error("signed message using malformed rsa private key")
+		return
+	}
+
+	_, err = keypair.Encrypt([]byte(common.RandomString(10)))
+	if err == nil {
+		t.Error("encrypted message using malformed rsa public key")
+		return
+	}
+
+	_, err = keypair.Decrypt([]byte(common.RandomString(256)))
+	if err != vaultcrypto.ErrCannotDecodeKey {
+		t.Errorf("expected malformed rsa private key error on decrypt; got %v", err)
+		return
+	}
+
+	_, err = keypair.Migrate()
+	if err == nil {
+		t.Error("migrated malformed rsa keypair")
+		return
+	}
+}
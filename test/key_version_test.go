@@ -0,0 +1,201 @@
+// +build unit
+
+package test
+
+import (
+	"testing"
+
+	uuid "github.com/kthomas/go.uuid"
+	"github.com/provideplatform/vault/common"
+	"github.com/provideplatform/vault/vault"
+)
+
+func TestRotateKeyAES256GCM(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for key rotation unit test!")
+		return
+	}
+
+	key, err := vault.AES256GCMFactory(vaultDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create AES-256-GCM key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	plaintext := []byte(common.RandomString(128))
+	ciphertext, err := key.Encrypt(plaintext, nil)
+	if err != nil {
+		t.Errorf("failed to encrypt using AES-256-GCM key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	err = key.Rotate(vaultDB)
+	if err != nil {
+		t.Errorf("failed to rotate AES-256-GCM key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if key.Version != 1 {
+		t.Errorf("expected key version 1 after rotation; got %d", key.Version)
+		return
+	}
+
+	rotatedCiphertext, err := key.Encrypt(plaintext, nil)
+	if err != nil {
+		t.Errorf("failed to encrypt using rotated AES-256-GCM key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	_, version, err := key.DecryptVersioned(vaultDB, rotatedCiphertext, nil, nil)
+	if err != nil || version != 1 {
+		t.Errorf("failed to decrypt using latest version of key: %s", key.ID)
+		return
+	}
+
+	decrypted, version, err := key.DecryptVersioned(vaultDB, ciphertext, nil, nil)
+	if err != nil {
+		t.Errorf("failed to decrypt using previous version of key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if version != 0 || string(decrypted) != string(plaintext) {
+		t.Errorf("decrypted plaintext does not match for previous version of key: %s", key.ID)
+		return
+	}
+
+	latest := 1
+	_, _, err = key.DecryptVersioned(vaultDB, ciphertext, nil, &latest)
+	if err == nil {
+		t.Errorf("decrypted ciphertext of previous version using latest version of key: %s", key.ID)
+		return
+	}
+
+	err = key.DestroyVersion(vaultDB, 1)
+	if err == nil {
+		t.Errorf("destroyed latest version of key: %s", key.ID)
+		return
+	}
+
+	err = key.DestroyVersion(vaultDB, 0)
+	if err != nil {
+		t.Errorf("failed to destroy previous version of key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	_, _, err = key.DecryptVersioned(vaultDB, ciphertext, nil, nil)
+	if err == nil {
+		t.Errorf("decrypted ciphertext using destroyed version of key: %s", key.ID)
+		return
+	}
+}
+
+func TestRotateKeyChaCha20RequiresVersion(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for key rotation unit test!")
+		return
+	}
+
+	key, err := vault.Chacha20Factory(vaultDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create ChaCha20 key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	plaintext := []byte(common.RandomString(128))
+	ciphertext, err := key.Encrypt(plaintext, nil)
+	if err != nil {
+		t.Errorf("failed to encrypt using ChaCha20 key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	previous := key.Version
+	err = key.Rotate(vaultDB)
+	if err != nil {
+		t.Errorf("failed to rotate ChaCha20 key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	// unauthenticated decryption using the latest version would not fail, so no version is tried
+	_, _, err = key.DecryptVersioned(vaultDB, ciphertext, nil, nil)
+	if err == nil {
+		t.Errorf("decrypted ChaCha20 ciphertext without version using rotated key: %s", key.ID)
+		return
+	}
+
+	decrypted, version, err := key.DecryptVersioned(vaultDB, ciphertext, nil, &previous)
+	if err != nil {
+		t.Errorf("failed to decrypt using previous version of ChaCha20 key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if version != previous || string(decrypted) != string(plaintext) {
+		t.Errorf("decrypted plaintext does not match using version %d of ChaCha20 key: %s", previous, key.ID)
+		return
+	}
+}
+
+func TestRotateKeyEd25519(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for key rotation unit test!")
+		return
+	}
+
+	key, err := vault.Ed25519Factory(vaultDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create Ed25519 keypair for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	msg := []byte(common.RandomString(10))
+	sig, err := key.Sign(msg, nil)
+	if err != nil {
+		t.Errorf("failed to sign message using Ed25519 keypair: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	err = key.Rotate(vaultDB)
+	if err != nil {
+		t.Errorf("failed to rotate Ed25519 keypair: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	err = key.Verify(msg, sig, nil)
+	if err == nil {
+		t.Errorf("verified signature of previous version using latest version of key: %s", key.ID)
+		return
+	}
+
+	version, err := key.VerifyVersioned(vaultDB, msg, sig, nil, nil)
+	if err != nil {
+		t.Errorf("failed to verify signature using previous version of key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if version != 0 {
+		t.Errorf("expected signature to be verified by version 0 of key: %s; got %d", key.ID, version)
+		return
+	}
+}
+
+func TestRotateKeyBIP39Unsupported(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for key rotation unit test!")
+		return
+	}
+
+	key, err := vault.EthHDWalletFactory(vaultDB, &vlt.ID, "hd wallet", "just some hd wallet :D")
+	if err != nil {
+		t.Errorf("failed to create hd wallet for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	err = key.Rotate(vaultDB)
+	if err == nil {
+		t.Errorf("rotated hd wallet: %s", key.ID)
+		return
+	}
+}
@@ -0,0 +1,182 @@
+// +build unit
+
+package test
+
+import (
+	"bytes"
+	"testing"
+
+	uuid "github.com/kthomas/go.uuid"
+	"github.com/provideplatform/vault/common"
+	vaultcrypto "github.com/provideplatform/vault/crypto"
+	"github.com/provideplatform/vault/vault"
+)
+
+func TestXChaCha20Poly1305EncryptDecrypt(t *testing.T) {
+	seed, err := vaultcrypto.CreateXChaCha20Poly1305Seed()
+	if err != nil {
+		t.Errorf("failed to create xchacha20-poly1305 seed; %s", err.Error())
+		return
+	}
+
+	key := vaultcrypto.XChaCha20Poly1305{
+		Seed: seed,
+	}
+
+	plaintext := []byte(common.RandomString(128))
+	aad := []byte("record:1")
+
+	ciphertext, err := key.Encrypt(plaintext, nil, aad)
+	if err != nil {
+		t.Errorf("failed to encrypt using xchacha20-poly1305 key; %s", err.Error())
+		return
+	}
+
+	nonce := ciphertext[0:vaultcrypto.NonceSizeXChaCha20Poly1305]
+	sealed := ciphertext[vaultcrypto.NonceSizeXChaCha20Poly1305:]
+
+	decrypted, err := key.Decrypt(sealed, nonce, aad)
+	if err != nil {
+		t.Errorf("failed to decrypt using xchacha20-poly1305 key; %s", err.Error())
+		return
+	}
+
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Error("decrypted plaintext does not match using xchacha20-poly1305 key")
+		return
+	}
+
+	_, err = key.Decrypt(sealed, nonce, []byte("record:2"))
+	if err == nil {
+		t.Error("decrypted ciphertext with mismatched aad using xchacha20-poly1305 key")
+		return
+	}
+
+	tampered := make([]byte, len(sealed))
+	copy(tampered, sealed)
+	tampered[0] ^= 0x01
+
+	_, err = key.Decrypt(tampered, nonce, aad)
+	if err == nil {
+		t.Error("decrypted tampered ciphertext using xchacha20-poly1305 key")
+		return
+	}
+}
+
+func TestXChaCha20Poly1305EncryptShortNonce(t *testing.T) {
+	seed, _ := vaultcrypto.CreateXChaCha20Poly1305Seed()
+	key := vaultcrypto.XChaCha20Poly1305{
+		Seed: seed,
+	}
+
+	_, err := key.Encrypt([]byte(common.RandomString(10)), []byte("short"), nil)
+	if err != vaultcrypto.ErrNonceTooShort {
+		t.Errorf("expected short nonce to be rejected by xchacha20-poly1305 key; got %v", err)
+		return
+	}
+}
+
+func TestCreateKeyXChaCha20Poly1305EncryptDecrypt(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for XChaCha20-Poly1305 key unit test!")
+		return
+	}
+
+	key, err := vault.XChaCha20Poly1305Factory(vaultDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create XChaCha20-Poly1305 key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	plaintext := []byte(common.RandomString(128))
+	ciphertext, err := key.Encrypt(plaintext, nil)
+	if err != nil {
+		t.Errorf("failed to encrypt using XChaCha20-Poly1305 key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if len(ciphertext) <= vaultcrypto.NonceSizeXChaCha20Poly1305+len(plaintext) {
+		t.Errorf("%d-byte ciphertext does not contain a nonce and authentication tag", len(ciphertext))
+		return
+	}
+
+	decrypted, err := key.Decrypt(ciphertext)
+	if err != nil {
+		t.Errorf("failed to decrypt using XChaCha20-Poly1305 key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("decrypted plaintext does not match using XChaCha20-Poly1305 key: %s", key.ID)
+		return
+	}
+}
+
+func TestDeriveSymmetricXChaCha20Poly1305(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for XChaCha20-Poly1305 key derivation unit test!")
+		return
+	}
+
+	key, err := vault.XChaCha20Poly1305Factory(vaultDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create XChaCha20-Poly1305 key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	nonce := make([]byte, 16)
+	derivedKey, err := key.DeriveSymmetric(nonce, []byte("context"), "derived key", "derived xchacha20-poly1305 key")
+	if err != nil {
+		t.Errorf("failed to derive symmetric key from XChaCha20-Poly1305 key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if derivedKey.Spec == nil || *derivedKey.Spec != vault.KeySpecXChaCha20Poly1305 {
+		t.Errorf("derived key spec does not match XChaCha20-Poly1305 for key: %s", key.ID)
+		return
+	}
+}
+
+func TestMigrateChaCha20ToXChaCha20Poly1305(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for ChaCha20 migration unit test!")
+		return
+	}
+
+	legacyKey, err := vault.Chacha20Factory(vaultDB, &vlt.ID, "legacy key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create ChaCha20 key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	key, err := vault.XChaCha20Poly1305Factory(vaultDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create XChaCha20-Poly1305 key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	plaintext := []byte(common.RandomString(128))
+	ciphertext, _ := legacyKey.Encrypt(plaintext, nil)
+
+	// the version of unauthenticated ChaCha20 ciphertext must be given explicitly
+	_, err = legacyKey.Reencrypt(vaultDB, ciphertext, nil, nil, key, nil)
+	if err == nil {
+		t.Errorf("re-encrypted ChaCha20 ciphertext without version using key: %s", legacyKey.ID)
+		return
+	}
+
+	migrated, err := legacyKey.Reencrypt(vaultDB, ciphertext, nil, &legacyKey.Version, key, nil)
+	if err != nil {
+		t.Errorf("failed to migrate ChaCha20 ciphertext to XChaCha20-Poly1305 key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	decrypted, err := key.Decrypt(migrated)
+	if err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("failed to decrypt migrated ciphertext using XChaCha20-Poly1305 key: %s", key.ID)
+		return
+	}
+}
@@ -0,0 +1,98 @@
+// +build unit
+
+package test
+
+import (
+	"testing"
+	"time"
+
+	uuid "github.com/kthomas/go.uuid"
+	"github.com/provideplatform/vault/common"
+	"github.com/provideplatform/vault/vault"
+)
+
+func TestRotateMasterKey(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for master key rotation unit test!")
+		return
+	}
+
+	initialMasterKeyID := *vlt.MasterKeyID
+
+	key, err := vault.AES256GCMFactory(vaultDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create AES-256-GCM key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	plaintext := []byte(common.RandomString(128))
+	ciphertext, err := key.Encrypt(plaintext, nil)
+	if err != nil {
+		t.Errorf("failed to encrypt using AES-256-GCM key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	secretText := common.RandomString(32)
+	secret, err := vault.SecretFactory(vaultDB, &vlt.ID, []byte(secretText), "secret name", "secret type", "secret description")
+	if err != nil {
+		t.Errorf("failed to create secret for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	version, err := vlt.RotateMasterKey(vaultDB)
+	if err != nil {
+		t.Errorf("failed to rotate master key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	if version.Version != 1 {
+		t.Errorf("expected master key version 1 after rotation; got %d", version.Version)
+		return
+	}
+
+	if vlt.MasterKeyID == nil || *vlt.MasterKeyID == initialMasterKeyID {
+		t.Errorf("active master key not updated for vault: %s", vlt.ID)
+		return
+	}
+
+	// the initial master key version is retired once its key material has been re-wrapped
+	retired := false
+	for i := 0; i < 20 && !retired; i++ {
+		initialVersion := &vault.MasterKeyVersion{}
+		vaultDB.Where("vault_id = ? AND version = 0", vlt.ID).Find(&initialVersion)
+		retired = initialVersion.RetiredAt != nil
+		if !retired {
+			time.Sleep(time.Millisecond * 250)
+		}
+	}
+
+	if !retired {
+		t.Errorf("initial master key version not retired for vault: %s", vlt.ID)
+		return
+	}
+
+	storedKey := vault.GetVaultKey(key.ID.String(), vlt.ID.String(), vlt.ApplicationID, vlt.OrganizationID, vlt.UserID)
+	decrypted, err := storedKey.Decrypt(ciphertext)
+	if err != nil {
+		t.Errorf("failed to decrypt using re-wrapped AES-256-GCM key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	if string(decrypted) != string(plaintext) {
+		t.Errorf("decrypted plaintext does not match after master key rotation for key: %s", key.ID)
+		return
+	}
+
+	storedSecret := vault.GetVaultSecret(secret.ID.String(), vlt.ID.String(), vlt.ApplicationID, vlt.OrganizationID, vlt.UserID)
+	decryptedSecret, err := storedSecret.AsResponse()
+	if err != nil {
+		t.Errorf("failed to decrypt re-wrapped secret: %s; Error: %s", secret.ID, err.Error())
+		return
+	}
+
+	if *decryptedSecret.Value != secretText {
+		t.Errorf("got incorrect secret back after master key rotation, expected %s, got %s", secretText, *decryptedSecret.Value)
+		return
+	}
+}
@@ -0,0 +1,151 @@
+// +build integration kms
+
+package test
+
+import (
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/provideplatform/vault/vault/providers"
+)
+
+// kmsEndpoint returns the endpoint of the local KMS-compatible emulator (i.e., local-kms)
+func kmsEndpoint() string {
+	endpoint := os.Getenv("SEAL_UNSEAL_AWS_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "http://localhost:4599"
+	}
+	return endpoint
+}
+
+func kmsKeyFactory(t *testing.T) string {
+	sess, err := session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithEndpoint(kmsEndpoint()).
+		WithCredentials(credentials.NewStaticCredentials("test", "test", "")))
+	if err != nil {
+		t.Errorf("failed to resolve AWS session; %s", err.Error())
+		return ""
+	}
+
+	key, err := kms.New(sess).CreateKey(&kms.CreateKeyInput{
+		Description: aws.String("vault seal/unseal key under test"),
+	})
+	if err != nil {
+		t.Errorf("failed to create AWS KMS key; %s", err.Error())
+		return ""
+	}
+
+	return *key.KeyMetadata.KeyId
+}
+
+func awsKMSProviderFactory(t *testing.T) providers.SealUnsealKeyProvider {
+	keyID := kmsKeyFactory(t)
+	if keyID == "" {
+		return nil
+	}
+
+	provider, err := providers.InitSealUnsealProvider(providers.SealUnsealKeyProviderAWS, map[string]interface{}{
+		"region":     "us-east-1",
+		"endpoint":   kmsEndpoint(),
+		"kms_key_id": keyID,
+		"credentials": map[string]interface{}{
+			"aws_access_key_id":     "test",
+			"aws_secret_access_key": "test",
+		},
+	})
+	if err != nil {
+		t.Errorf("failed to initialize AWS KMS seal/unseal provider; %s", err.Error())
+		return nil
+	}
+
+	return provider
+}
+
+func TestAWSKMSProviderSeedAndRekey(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "vault")
+	defer os.RemoveAll(dir)
+
+	ciphertext := os.Getenv("SEAL_UNSEAL_KEY_CIPHERTEXT")
+	defer os.Setenv("SEAL_UNSEAL_KEY_CIPHERTEXT", ciphertext)
+	os.Unsetenv("SEAL_UNSEAL_KEY_CIPHERTEXT")
+
+	ciphertextPath := filepath.Join(dir, "unsealerkey.enc")
+	os.Setenv("SEAL_UNSEAL_KEY_CIPHERTEXT_PATH", ciphertextPath)
+	defer os.Unsetenv("SEAL_UNSEAL_KEY_CIPHERTEXT_PATH")
+
+	provider := awsKMSProviderFactory(t)
+	if provider == nil {
+		return
+	}
+
+	// the first call generates the seed and persists its ciphertext
+	seed, err := provider.Seed()
+	if err != nil {
+		t.Errorf("failed to resolve seed from AWS KMS seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	if _, err := os.Stat(ciphertextPath); err != nil {
+		t.Errorf("seed ciphertext not persisted to %s; %s", ciphertextPath, err.Error())
+		return
+	}
+
+	resolvedSeed, err := provider.Seed()
+	if err != nil {
+		t.Errorf("failed to resolve seed from AWS KMS seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	if *resolvedSeed != *seed {
+		t.Error("AWS KMS seal/unseal provider resolved a different seed than it created")
+		return
+	}
+
+	newSeed := "traffic charge swing glimpse will citizen push mutual embrace volcano siege identify gossip battle casual exit enrich unlock muscle vast female initial please day"
+	hash := crypto.SHA256.New()
+	hash.Write([]byte(newSeed))
+	validationHash := fmt.Sprintf("0x%s", hex.EncodeToString(hash.Sum(nil)))
+
+	err = provider.Rekey(newSeed, validationHash)
+	if err != nil {
+		t.Errorf("failed to rekey AWS KMS seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	resolvedHash, err := provider.ValidationHash()
+	if err != nil {
+		t.Errorf("failed to resolve validation hash from AWS KMS seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	if *resolvedHash != validationHash {
+		t.Errorf("expected validation hash %s after rekey; got %s", validationHash, *resolvedHash)
+		return
+	}
+}
+
+func TestAWSKMSProviderRequiresCiphertextOrKey(t *testing.T) {
+	ciphertext := os.Getenv("SEAL_UNSEAL_KEY_CIPHERTEXT")
+	defer os.Setenv("SEAL_UNSEAL_KEY_CIPHERTEXT", ciphertext)
+	os.Unsetenv("SEAL_UNSEAL_KEY_CIPHERTEXT")
+
+	_, err := providers.InitSealUnsealProvider(providers.SealUnsealKeyProviderAWS, map[string]interface{}{
+		"region":   "us-east-1",
+		"endpoint": kmsEndpoint(),
+	})
+	if err == nil {
+		t.Error("initialized AWS KMS seal/unseal provider without ciphertext or KMS key")
+		return
+	}
+	t.Logf("error received: %s", err.Error())
+}
@@ -0,0 +1,183 @@
+// +build unit
+
+package test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/provideplatform/vault/vault/providers"
+)
+
+func keyfileProviderFactory(t *testing.T, path, kdf, passphrase string) providers.SealUnsealKeyProvider {
+	provider, err := providers.InitSealUnsealProvider(providers.SealUnsealKeyProviderKeyfile, map[string]interface{}{
+		"path":       path,
+		"kdf":        kdf,
+		"passphrase": passphrase,
+	})
+	if err != nil {
+		t.Errorf("failed to initialize keyfile seal/unseal provider; %s", err.Error())
+		return nil
+	}
+
+	return provider
+}
+
+func TestKeyfileProviderSeedAndRekey(t *testing.T) {
+	for _, kdf := range []string{providers.KeyfileKDFArgon2id, providers.KeyfileKDFScrypt} {
+		dir, _ := ioutil.TempDir("", "vault")
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "unsealerkey.json")
+
+		provider := keyfileProviderFactory(t, path, kdf, "correct horse battery staple")
+		if provider == nil {
+			return
+		}
+
+		// the first call generates the seed and writes the keyfile
+		seed, err := provider.Seed()
+		if err != nil {
+			t.Errorf("failed to resolve seed from %s keyfile seal/unseal provider; %s", kdf, err.Error())
+			return
+		}
+
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Errorf("keyfile not written to %s; %s", path, err.Error())
+			return
+		}
+
+		if strings.Contains(string(raw), *seed) {
+			t.Errorf("%s keyfile seal/unseal provider persisted the seed in the clear", kdf)
+			return
+		}
+
+		resolvedSeed, err := keyfileProviderFactory(t, path, kdf, "correct horse battery staple").Seed()
+		if err != nil {
+			t.Errorf("failed to resolve seed from %s keyfile seal/unseal provider; %s", kdf, err.Error())
+			return
+		}
+
+		if *resolvedSeed != *seed {
+			t.Errorf("%s keyfile seal/unseal provider resolved a different seed than it created", kdf)
+			return
+		}
+
+		err = provider.Rekey(unsealerKey, "")
+		if err != nil {
+			t.Errorf("failed to rekey %s keyfile seal/unseal provider; %s", kdf, err.Error())
+			return
+		}
+
+		resolvedSeed, err = provider.Seed()
+		if err != nil {
+			t.Errorf("failed to resolve seed from %s keyfile seal/unseal provider after rekey; %s", kdf, err.Error())
+			return
+		}
+
+		if *resolvedSeed != unsealerKey {
+			t.Errorf("%s keyfile seal/unseal provider did not resolve the rekeyed seed", kdf)
+			return
+		}
+	}
+}
+
+func TestKeyfileProviderIncorrectPassphrase(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "vault")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "unsealerkey.json")
+
+	_, err := keyfileProviderFactory(t, path, providers.KeyfileKDFArgon2id, "correct horse battery staple").Seed()
+	if err != nil {
+		t.Errorf("failed to resolve seed from keyfile seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	_, err = keyfileProviderFactory(t, path, providers.KeyfileKDFArgon2id, "incorrect horse battery staple").Seed()
+	if err == nil {
+		t.Error("decrypted keyfile using incorrect passphrase")
+		return
+	}
+	t.Logf("error received: %s", err.Error())
+}
+
+func TestKeyfileProviderPassphraseFD(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "vault")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "unsealerkey.json")
+
+	seed, err := keyfileProviderFactory(t, path, providers.KeyfileKDFScrypt, "correct horse battery staple").Seed()
+	if err != nil {
+		t.Errorf("failed to resolve seed from keyfile seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Errorf("failed to create passphrase pipe; %s", err.Error())
+		return
+	}
+	w.Write([]byte("correct horse battery staple\n"))
+	w.Close()
+
+	os.Setenv("SEAL_UNSEAL_KEYFILE_PASSPHRASE_FD", fmt.Sprintf("%d", r.Fd()))
+	defer os.Unsetenv("SEAL_UNSEAL_KEYFILE_PASSPHRASE_FD")
+
+	provider, err := providers.InitSealUnsealProvider(providers.SealUnsealKeyProviderKeyfile, map[string]interface{}{
+		"path": path,
+	})
+	if err != nil {
+		t.Errorf("failed to initialize keyfile seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	resolvedSeed, err := provider.Seed()
+	if err != nil {
+		t.Errorf("failed to resolve seed from keyfile seal/unseal provider using passphrase file descriptor; %s", err.Error())
+		return
+	}
+
+	if *resolvedSeed != *seed {
+		t.Error("keyfile seal/unseal provider resolved a different seed using passphrase file descriptor")
+		return
+	}
+}
+
+func TestKeyfileProviderSystemdCredential(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "vault")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "unsealerkey.json")
+
+	ioutil.WriteFile(filepath.Join(dir, "vault-keyfile-passphrase"), []byte("correct horse battery staple\n"), 0600)
+	os.Setenv("CREDENTIALS_DIRECTORY", dir)
+	defer os.Unsetenv("CREDENTIALS_DIRECTORY")
+
+	seed, err := keyfileProviderFactory(t, path, providers.KeyfileKDFArgon2id, "correct horse battery staple").Seed()
+	if err != nil {
+		t.Errorf("failed to resolve seed from keyfile seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	provider, err := providers.InitSealUnsealProvider(providers.SealUnsealKeyProviderKeyfile, map[string]interface{}{
+		"path": path,
+	})
+	if err != nil {
+		t.Errorf("failed to initialize keyfile seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	resolvedSeed, err := provider.Seed()
+	if err != nil {
+		t.Errorf("failed to resolve seed from keyfile seal/unseal provider using systemd credential; %s", err.Error())
+		return
+	}
+
+	if *resolvedSeed != *seed {
+		t.Error("keyfile seal/unseal provider resolved a different seed using systemd credential")
+		return
+	}
+}
@@ -0,0 +1,134 @@
+// +build integration pkcs11
+
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/provideplatform/vault/vault/providers"
+)
+
+// pkcs11Module returns the path of the PKCS#11 module under test; SoftHSMv2 by default,
+// with a token initialized using i.e. `softhsm2-util --init-token --free --label vault --pin 1234 --so-pin 1234`
+func pkcs11Module() string {
+	module := os.Getenv("SEAL_UNSEAL_PKCS11_MODULE")
+	if module == "" {
+		module = "/usr/lib/softhsm/libsofthsm2.so"
+	}
+	return module
+}
+
+func pkcs11ProviderFactory(t *testing.T) providers.SealUnsealKeyProvider {
+	pin := os.Getenv("SEAL_UNSEAL_PKCS11_PIN")
+	if pin == "" {
+		pin = "1234"
+	}
+
+	tokenLabel := os.Getenv("SEAL_UNSEAL_PKCS11_TOKEN_LABEL")
+	if tokenLabel == "" {
+		tokenLabel = "vault"
+	}
+
+	provider, err := providers.InitSealUnsealProvider(providers.SealUnsealKeyProviderPKCS11, map[string]interface{}{
+		"module":      pkcs11Module(),
+		"pin":         pin,
+		"token_label": tokenLabel,
+	})
+	if err != nil {
+		t.Errorf("failed to initialize PKCS#11 seal/unseal provider; %s", err.Error())
+		return nil
+	}
+
+	return provider
+}
+
+func TestPKCS11ProviderSeedAndRekey(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "vault")
+	defer os.RemoveAll(dir)
+
+	ciphertext := os.Getenv("SEAL_UNSEAL_KEY_CIPHERTEXT")
+	defer os.Setenv("SEAL_UNSEAL_KEY_CIPHERTEXT", ciphertext)
+	os.Unsetenv("SEAL_UNSEAL_KEY_CIPHERTEXT")
+
+	ciphertextPath := filepath.Join(dir, "unsealerkey.enc")
+	os.Setenv("SEAL_UNSEAL_KEY_CIPHERTEXT_PATH", ciphertextPath)
+	defer os.Unsetenv("SEAL_UNSEAL_KEY_CIPHERTEXT_PATH")
+
+	provider := pkcs11ProviderFactory(t)
+	if provider == nil {
+		return
+	}
+
+	// the first call generates the seed and persists it wrapped by the token
+	seed, err := provider.Seed()
+	if err != nil {
+		t.Errorf("failed to resolve seed from PKCS#11 seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	wrapped, err := ioutil.ReadFile(ciphertextPath)
+	if err != nil {
+		t.Errorf("wrapped seed not persisted to %s; %s", ciphertextPath, err.Error())
+		return
+	}
+
+	if string(wrapped) == *seed {
+		t.Error("PKCS#11 seal/unseal provider persisted the seed in the clear")
+		return
+	}
+
+	// a provider initialized from the persisted ciphertext unwraps the same seed
+	os.Unsetenv("SEAL_UNSEAL_KEY_CIPHERTEXT")
+	resolvedSeed, err := pkcs11ProviderFactory(t).Seed()
+	if err != nil {
+		t.Errorf("failed to resolve seed from PKCS#11 seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	if *resolvedSeed != *seed {
+		t.Error("PKCS#11 seal/unseal provider resolved a different seed than it created")
+		return
+	}
+
+	newSeed := "traffic charge swing glimpse will citizen push mutual embrace volcano siege identify gossip battle casual exit enrich unlock muscle vast female initial please day"
+	err = provider.Rekey(newSeed, "")
+	if err != nil {
+		t.Errorf("failed to rekey PKCS#11 seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	resolvedSeed, err = provider.Seed()
+	if err != nil {
+		t.Errorf("failed to resolve seed from PKCS#11 seal/unseal provider after rekey; %s", err.Error())
+		return
+	}
+
+	if *resolvedSeed != newSeed {
+		t.Error("PKCS#11 seal/unseal provider did not resolve the rekeyed seed")
+		return
+	}
+}
+
+func TestPKCS11ProviderInvalidModule(t *testing.T) {
+	os.Setenv("SEAL_UNSEAL_KEY_CIPHERTEXT", "AAAAAAAAAAAAAAAAAAAAAAAA")
+	defer os.Unsetenv("SEAL_UNSEAL_KEY_CIPHERTEXT")
+
+	provider, err := providers.InitSealUnsealProvider(providers.SealUnsealKeyProviderPKCS11, map[string]interface{}{
+		"module": "/nonexistent/libpkcs11.so",
+		"pin":    "1234",
+	})
+	if err != nil {
+		t.Errorf("failed to initialize PKCS#11 seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	_, err = provider.Seed()
+	if err == nil {
+		t.Error("resolved seed using nonexistent PKCS#11 module")
+		return
+	}
+	t.Logf("error received: %s", err.Error())
+}
@@ -0,0 +1,160 @@
+// +build integration transit
+
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/provideplatform/vault/vault/providers"
+)
+
+// transitAddr returns the address of the transit server under test; a dev-mode
+// HashiCorp Vault server (i.e., `vault server -dev -dev-root-token-id=root`) by default
+func transitAddr() string {
+	addr := os.Getenv("SEAL_UNSEAL_TRANSIT_ADDR")
+	if addr == "" {
+		addr = "http://localhost:8200"
+	}
+	return addr
+}
+
+func transitToken() string {
+	token := os.Getenv("SEAL_UNSEAL_TRANSIT_TOKEN")
+	if token == "" {
+		token = "root"
+	}
+	return token
+}
+
+// transitKeyFactory enables the transit secrets engine, if necessary, and creates a new named key
+func transitKeyFactory(t *testing.T) string {
+	keyName := fmt.Sprintf("vault-unsealer-%d", time.Now().UnixNano())
+
+	for _, req := range []struct {
+		path string
+		body string
+	}{
+		{"/v1/sys/mounts/transit", `{"type":"transit"}`},
+		{fmt.Sprintf("/v1/transit/keys/%s", keyName), `{}`},
+	} {
+		httpReq, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s%s", transitAddr(), req.path), bytes.NewReader([]byte(req.body)))
+		httpReq.Header.Set("X-Vault-Token", transitToken())
+		resp, err := http.DefaultClient.Do(httpReq)
+		if err != nil {
+			t.Errorf("transit setup request failed; %s", err.Error())
+			return ""
+		}
+		resp.Body.Close()
+	}
+
+	return keyName
+}
+
+func transitProviderFactory(t *testing.T, keyName string) providers.SealUnsealKeyProvider {
+	provider, err := providers.InitSealUnsealProvider(providers.SealUnsealKeyProviderTransit, map[string]interface{}{
+		"address":  transitAddr(),
+		"token":    transitToken(),
+		"key_name": keyName,
+	})
+	if err != nil {
+		t.Errorf("failed to initialize transit seal/unseal provider; %s", err.Error())
+		return nil
+	}
+
+	return provider
+}
+
+func TestTransitProviderSeedAndRekey(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "vault")
+	defer os.RemoveAll(dir)
+
+	ciphertext := os.Getenv("SEAL_UNSEAL_KEY_CIPHERTEXT")
+	defer os.Setenv("SEAL_UNSEAL_KEY_CIPHERTEXT", ciphertext)
+	os.Unsetenv("SEAL_UNSEAL_KEY_CIPHERTEXT")
+
+	ciphertextPath := filepath.Join(dir, "unsealerkey.enc")
+	os.Setenv("SEAL_UNSEAL_KEY_CIPHERTEXT_PATH", ciphertextPath)
+	defer os.Unsetenv("SEAL_UNSEAL_KEY_CIPHERTEXT_PATH")
+
+	keyName := transitKeyFactory(t)
+	if keyName == "" {
+		return
+	}
+
+	provider := transitProviderFactory(t, keyName)
+	if provider == nil {
+		return
+	}
+
+	// the first call generates the seed and persists its ciphertext
+	seed, err := provider.Seed()
+	if err != nil {
+		t.Errorf("failed to resolve seed from transit seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	// a provider initialized from the persisted ciphertext decrypts the same seed
+	os.Unsetenv("SEAL_UNSEAL_KEY_CIPHERTEXT")
+	resolvedSeed, err := transitProviderFactory(t, keyName).Seed()
+	if err != nil {
+		t.Errorf("failed to resolve seed from transit seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	if *resolvedSeed != *seed {
+		t.Error("transit seal/unseal provider resolved a different seed than it created")
+		return
+	}
+
+	newSeed := "traffic charge swing glimpse will citizen push mutual embrace volcano siege identify gossip battle casual exit enrich unlock muscle vast female initial please day"
+	err = provider.Rekey(newSeed, "")
+	if err != nil {
+		t.Errorf("failed to rekey transit seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	resolvedSeed, err = provider.Seed()
+	if err != nil {
+		t.Errorf("failed to resolve seed from transit seal/unseal provider after rekey; %s", err.Error())
+		return
+	}
+
+	if *resolvedSeed != newSeed {
+		t.Error("transit seal/unseal provider did not resolve the rekeyed seed")
+		return
+	}
+}
+
+func TestTransitProviderInvalidToken(t *testing.T) {
+	keyName := transitKeyFactory(t)
+	if keyName == "" {
+		return
+	}
+
+	os.Setenv("SEAL_UNSEAL_KEY_CIPHERTEXT", "dmF1bHQ6djE6aW52YWxpZA==")
+	defer os.Unsetenv("SEAL_UNSEAL_KEY_CIPHERTEXT")
+
+	provider, err := providers.InitSealUnsealProvider(providers.SealUnsealKeyProviderTransit, map[string]interface{}{
+		"address":  transitAddr(),
+		"token":    "invalid",
+		"key_name": keyName,
+	})
+	if err != nil {
+		t.Errorf("failed to initialize transit seal/unseal provider; %s", err.Error())
+		return
+	}
+
+	_, err = provider.Seed()
+	if err == nil {
+		t.Error("resolved seed from transit seal/unseal provider using invalid token")
+		return
+	}
+	t.Logf("error received: %s", err.Error())
+}
@@ -0,0 +1,82 @@
+// +build unit
+
+package test
+
+import (
+	"testing"
+
+	uuid "github.com/kthomas/go.uuid"
+	"github.com/provideplatform/vault/common"
+	"github.com/provideplatform/vault/vault"
+)
+
+func TestReencryptAcrossVaults(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for re-encrypt unit test!")
+		return
+	}
+
+	destinationVault := vaultFactory()
+	if destinationVault.ID == uuid.Nil {
+		t.Error("failed! no destination vault created for re-encrypt unit test!")
+		return
+	}
+
+	key, err := vault.AES256GCMFactory(vaultDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create AES-256-GCM key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	destination, err := vault.Chacha20Factory(vaultDB, &destinationVault.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create ChaCha20 key for vault: %s; Error: %s", destinationVault.ID, err.Error())
+		return
+	}
+
+	plaintext := []byte(common.RandomString(128))
+	ciphertext, err := key.Encrypt(plaintext, nil)
+	if err != nil {
+		t.Errorf("failed to encrypt using AES-256-GCM key: %s; Error: %s", key.ID, err.Error())
+		return
+	}
+
+	reencrypted, err := key.Reencrypt(vaultDB, ciphertext, nil, nil, destination, nil)
+	if err != nil {
+		t.Errorf("failed to re-encrypt using ChaCha20 key: %s; Error: %s", destination.ID, err.Error())
+		return
+	}
+
+	decrypted, err := destination.Decrypt(reencrypted)
+	if err != nil {
+		t.Errorf("failed to decrypt re-encrypted ciphertext using ChaCha20 key: %s; Error: %s", destination.ID, err.Error())
+		return
+	}
+
+	if string(decrypted) != string(plaintext) {
+		t.Errorf("decrypted plaintext does not match after re-encryption using key: %s", destination.ID)
+		return
+	}
+}
+
+func TestReencryptNilDestination(t *testing.T) {
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for re-encrypt unit test!")
+		return
+	}
+
+	key, err := vault.AES256GCMFactory(vaultDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create AES-256-GCM key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	ciphertext, _ := key.Encrypt([]byte(common.RandomString(32)), nil)
+	_, err = key.Reencrypt(vaultDB, ciphertext, nil, nil, nil, nil)
+	if err == nil {
+		t.Errorf("re-encrypted ciphertext without destination key")
+		return
+	}
+}
@@ -13,6 +13,7 @@ import (
 	uuid "github.com/kthomas/go.uuid"
 	"github.com/provideplatform/vault/common"
 	"github.com/provideplatform/vault/vault"
+	"github.com/provideplatform/vault/vault/providers"
 	"github.com/tyler-smith/go-bip39"
 )
 
@@ -245,3 +246,334 @@ func TestCreateUnsealerAndSignVerify(t *testing.T) {
 
 	common.Log.Debugf("verified message using secp256k1 keypair for vault: %s; sig: %s", vlt.ID, hex.EncodeToString(sig))
 }
+
+func TestCreateUnsealerKeySharesAndUnseal(t *testing.T) {
+	//correct everything after test
+	defer unsealVault()
+	err := vault.ClearUnsealerKey(unsealerKey)
+	if err != nil {
+		t.Errorf("error sealing vault: %s", err.Error())
+		return
+	}
+
+	hash := os.Getenv("SEAL_UNSEAL_VALIDATION_HASH")
+	defer setValidationHash(hash)
+
+	// first we will create a new unsealer key split into 5 shares with a threshold of 3
+	response, err := vault.CreateUnsealerKeyShares(5, 3)
+	if err != nil {
+		t.Errorf("error creating unsealer key shares; %s", err.Error())
+		return
+	}
+
+	if response.UnsealerKey != nil {
+		t.Error("unsealer key should not be returned when split into shares")
+		return
+	}
+
+	if len(response.KeyShares) != 5 {
+		t.Errorf("expected 5 unsealer key shares; got %d", len(response.KeyShares))
+		return
+	}
+
+	setValidationHash(strings.Replace(*response.ValidationHash, "0x", "", -1))
+
+	for i, share := range []*string{response.KeyShares[4], response.KeyShares[0], response.KeyShares[2]} {
+		resp, err := vault.SubmitUnsealerKeyShare(*share)
+		if err != nil {
+			t.Errorf("error submitting unsealer key share %d; %s", i+1, err.Error())
+			return
+		}
+
+		if i < 2 {
+			if !vault.IsSealed() || resp.Progress == nil || *resp.Progress != i+1 {
+				t.Errorf("expected vault to remain sealed with progress %d", i+1)
+				return
+			}
+		}
+	}
+
+	if vault.IsSealed() {
+		t.Error("vault should be unsealed after threshold unsealer key shares submitted")
+		return
+	}
+}
+
+func TestUnsealDuplicateKeyShare(t *testing.T) {
+	//correct everything after test
+	defer unsealVault()
+	err := vault.ClearUnsealerKey(unsealerKey)
+	if err != nil {
+		t.Errorf("error sealing vault: %s", err.Error())
+		return
+	}
+
+	response, err := vault.CreateUnsealerKeyShares(3, 2)
+	if err != nil {
+		t.Errorf("error creating unsealer key shares; %s", err.Error())
+		return
+	}
+
+	_, err = vault.SubmitUnsealerKeyShare(*response.KeyShares[0])
+	if err != nil {
+		t.Errorf("error submitting unsealer key share; %s", err.Error())
+		return
+	}
+
+	_, err = vault.SubmitUnsealerKeyShare(*response.KeyShares[0])
+	if err == nil {
+		t.Error("duplicate unsealer key share should not be accepted")
+		return
+	}
+	t.Logf("error received: %s", err.Error())
+}
+
+func TestUnsealKeySharesIncorrectKey(t *testing.T) {
+	//correct everything after test
+	defer unsealVault()
+	err := vault.ClearUnsealerKey(unsealerKey)
+	if err != nil {
+		t.Errorf("error sealing vault: %s", err.Error())
+		return
+	}
+
+	// these shares reconstruct a key which does not match the configured validation hash
+	response, err := vault.CreateUnsealerKeyShares(2, 2)
+	if err != nil {
+		t.Errorf("error creating unsealer key shares; %s", err.Error())
+		return
+	}
+
+	for _, share := range response.KeyShares {
+		_, err = vault.SubmitUnsealerKeyShare(*share)
+	}
+
+	if err == nil || !vault.IsSealed() {
+		t.Error("unsealed vault with incorrect unsealer key shares")
+		return
+	}
+	t.Logf("error received: %s", err.Error())
+}
+
+func TestCreateUnsealerKeySharesInvalidThreshold(t *testing.T) {
+	_, err := vault.CreateUnsealerKeyShares(3, 4)
+	if err == nil {
+		t.Error("created unsealer key shares with threshold greater than shares")
+		return
+	}
+
+	_, err = vault.CreateUnsealerKeyShares(3, 1)
+	if err == nil {
+		t.Error("created unsealer key shares with threshold of 1")
+		return
+	}
+}
+
+// sealUnsealProvider returns the name of the seal/unseal provider configured for the test run
+func sealUnsealProvider() string {
+	if os.Getenv("SEAL_UNSEAL_PROVIDER") != "" {
+		return os.Getenv("SEAL_UNSEAL_PROVIDER")
+	}
+	return providers.SealUnsealKeyProviderEnvironment
+}
+
+func TestRekey(t *testing.T) {
+	if !providers.RekeySupported(sealUnsealProvider()) {
+		t.Skipf("rekey not supported by configured seal/unseal provider: %s", sealUnsealProvider())
+	}
+
+	//correct everything after test
+	defer unsealVault()
+	hash := os.Getenv("SEAL_UNSEAL_VALIDATION_HASH")
+	defer setValidationHash(hash)
+	seed := os.Getenv("SEAL_UNSEAL_KEY")
+	defer os.Setenv("SEAL_UNSEAL_KEY", seed)
+
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for rekey unit test!")
+		return
+	}
+
+	key, err := vault.Secp256k1Factory(sealerDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create secp256k1 key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	response, _ := vault.CreateUnsealerKey()
+	newUnsealerKey := response.UnsealerKey
+
+	rekeyed, err := vault.Rekey(unsealerKey, *newUnsealerKey)
+	if err != nil {
+		t.Errorf("error rekeying vault; %s", err.Error())
+		return
+	}
+
+	// rekey back to the original unsealer key after the test
+	defer vault.Rekey(*newUnsealerKey, unsealerKey)
+
+	if *rekeyed.ValidationHash != *response.ValidationHash {
+		t.Errorf("expected validation hash %s after rekey; got %s", *response.ValidationHash, *rekeyed.ValidationHash)
+		return
+	}
+
+	if vault.IsSealed() {
+		t.Error("vault should be unsealed after rekey")
+		return
+	}
+
+	msg := []byte(common.RandomString(32))
+	sig, err := key.Sign(msg, nil)
+	if err != nil {
+		t.Errorf("failed to sign message using secp256k1 keypair after rekey: %s", err.Error())
+		return
+	}
+
+	err = key.Verify(msg, sig, nil)
+	if err != nil {
+		t.Errorf("failed to verify message using secp256k1 keypair after rekey: %s", err.Error())
+		return
+	}
+
+	// the original unsealer key is no longer valid
+	err = vault.ClearUnsealerKey(*newUnsealerKey)
+	if err != nil {
+		t.Errorf("error sealing vault: %s", err.Error())
+		return
+	}
+
+	err = vault.SetUnsealerKey(unsealerKey)
+	if err == nil {
+		t.Error("unsealed vault using unsealer key which was rotated by rekey")
+		return
+	}
+	t.Logf("error received: %s", err.Error())
+
+	err = vault.SetUnsealerKey(*newUnsealerKey)
+	if err != nil {
+		t.Errorf("failed to unseal vault using new unsealer key: %s", err.Error())
+		return
+	}
+}
+
+func TestRekeyUnsupportedProvider(t *testing.T) {
+	if providers.RekeySupported(sealUnsealProvider()) {
+		t.Skipf("rekey supported by configured seal/unseal provider: %s", sealUnsealProvider())
+	}
+
+	vlt := vaultFactory()
+	if vlt.ID == uuid.Nil {
+		t.Error("failed! no vault created for rekey unit test!")
+		return
+	}
+
+	key, err := vault.Secp256k1Factory(sealerDB, &vlt.ID, "test key", "just some key :D")
+	if err != nil {
+		t.Errorf("failed to create secp256k1 key for vault: %s; Error: %s", vlt.ID, err.Error())
+		return
+	}
+
+	response, _ := vault.CreateUnsealerKey()
+	_, err = vault.Rekey(unsealerKey, *response.UnsealerKey)
+	if err == nil {
+		t.Errorf("rekeyed vault using seal/unseal provider which cannot persist the unsealer key: %s", sealUnsealProvider())
+		return
+	}
+	t.Logf("error received: %s", err.Error())
+
+	if vault.IsSealed() {
+		t.Error("vault should remain unsealed after rejected rekey")
+		return
+	}
+
+	// the master keys remain wrapped under the current unsealer key
+	msg := []byte(common.RandomString(32))
+	_, err = key.Sign(msg, nil)
+	if err != nil {
+		t.Errorf("failed to sign message using secp256k1 keypair after rejected rekey: %s", err.Error())
+		return
+	}
+}
+
+func TestRekeyIncorrectKey(t *testing.T) {
+	response, _ := vault.CreateUnsealerKey()
+
+	_, err := vault.Rekey(*response.UnsealerKey, unsealerKey)
+	if err == nil {
+		t.Error("rekeyed vault using incorrect unsealer key")
+		return
+	}
+	t.Logf("error received: %s", err.Error())
+
+	if vault.IsSealed() {
+		t.Error("vault should remain unsealed after rejected rekey")
+		return
+	}
+}
+
+func TestClusterSealStatus(t *testing.T) {
+	//correct everything after test
+	defer unsealVault()
+
+	status, err := vault.GetClusterSealStatus()
+	if err != nil {
+		t.Errorf("error resolving cluster seal status; %s", err.Error())
+		return
+	}
+
+	if status.InstanceID == "" || status.Sealed {
+		t.Error("expected unsealed vault instance in cluster seal status")
+		return
+	}
+
+	if len(status.Instances) == 0 {
+		t.Error("expected at least one vault instance in cluster seal status")
+		return
+	}
+
+	err = vault.ClearUnsealerKey(unsealerKey)
+	if err != nil {
+		t.Errorf("error sealing vault: %s", err.Error())
+		return
+	}
+
+	status, err = vault.GetClusterSealStatus()
+	if err != nil {
+		t.Errorf("error resolving cluster seal status; %s", err.Error())
+		return
+	}
+
+	if !status.Sealed {
+		t.Error("expected sealed vault instance in cluster seal status")
+		return
+	}
+}
+
+func TestHealthStatusNotReadyWhileSealed(t *testing.T) {
+	//correct everything after test
+	defer unsealVault()
+
+	status := vault.GetHealthStatus()
+	if status.Sealed || !status.Ready {
+		t.Errorf("expected unsealed vault to be ready; checks: %v", status.Checks)
+		return
+	}
+
+	if status.Checks["database"] != vault.HealthStatusOK {
+		t.Errorf("expected database health check to succeed; got %s", status.Checks["database"])
+		return
+	}
+
+	err := vault.ClearUnsealerKey(unsealerKey)
+	if err != nil {
+		t.Errorf("error sealing vault: %s", err.Error())
+		return
+	}
+
+	status = vault.GetHealthStatus()
+	if !status.Sealed || status.Ready {
+		t.Error("expected sealed vault not to be ready")
+		return
+	}
+}
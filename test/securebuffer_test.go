@@ -0,0 +1,86 @@
+// +build unit
+
+package test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/provideplatform/vault/common"
+	vaultcrypto "github.com/provideplatform/vault/crypto"
+)
+
+func TestSecureBufferWipesSource(t *testing.T) {
+	src, _ := common.RandomBytes(32)
+	expected := make([]byte, len(src))
+	copy(expected, src)
+
+	buf := vaultcrypto.NewSecureBuffer(src)
+	defer buf.Destroy()
+
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Error("secure buffer does not contain the source key material")
+		return
+	}
+
+	if !bytes.Equal(src, make([]byte, len(src))) {
+		t.Error("source key material not wiped after moving into secure buffer")
+		return
+	}
+}
+
+func TestSecureBufferDestroy(t *testing.T) {
+	src, _ := common.RandomBytes(32)
+	buf := vaultcrypto.NewSecureBuffer(src)
+	buf.Destroy()
+
+	if buf.Size() != 0 || len(buf.Bytes()) != 0 {
+		t.Error("secure buffer key material accessible after destroy")
+		return
+	}
+
+	// destroying an already-destroyed buffer is a no-op
+	buf.Destroy()
+}
+
+func TestSecureEnclaveOpen(t *testing.T) {
+	src, _ := common.RandomBytes(32)
+	expected := make([]byte, len(src))
+	copy(expected, src)
+
+	enclave := vaultcrypto.NewSecureEnclave(src)
+	if !bytes.Equal(src, make([]byte, len(src))) {
+		t.Error("source key material not wiped after sealing into secure enclave")
+		return
+	}
+
+	buf, err := enclave.Open()
+	if err != nil {
+		t.Errorf("failed to open secure enclave; %s", err.Error())
+		return
+	}
+	defer buf.Destroy()
+
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Error("opened secure enclave does not contain the sealed key material")
+		return
+	}
+}
+
+func TestAES256GCMWipe(t *testing.T) {
+	seed, _ := vaultcrypto.CreateAES256GCMSeed()
+	key := vaultcrypto.AES256GCM{
+		PrivateKey: seed,
+	}
+	key.Wipe()
+
+	if key.PrivateKey != nil {
+		t.Error("private key not released after wipe")
+		return
+	}
+
+	if !bytes.Equal(seed, make([]byte, len(seed))) {
+		t.Error("private key not zeroed after wipe")
+		return
+	}
+}
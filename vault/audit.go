@@ -23,6 +23,8 @@ type AuditLogEvent struct {
 	Host           *string    `json:"host"`
 	ResponseStatus *int       `json:"status,omitempty"`
 	Latency        *int64     `json:"latency,omitempty"`
+	Subject        *string    `json:"subject,omitempty"`
+	Reason         *string    `json:"reason,omitempty"`
 }
 
 // AuditRequest logs data from the http request for audit purposes
@@ -126,6 +128,42 @@ func AuditLogMiddleware() gin.HandlerFunc {
 	}
 }
 
+// AuditAuthorizationDenied logs a denied authorization attempt for audit purposes
+func AuditAuthorizationDenied(c *gin.Context, subject *string, reason string) {
+	timestamp := time.Now().UTC()
+	requestID := c.GetString("RequestId")
+	userID := c.GetString("user_id")
+	appID := c.GetString("application_id")
+	orgID := c.GetString("organization_id")
+	remoteAddress := c.Request.RemoteAddr
+	requestPath := c.Request.URL.RequestURI()
+	requestMethod := c.Request.Method
+	host := c.Request.Host
+	statusCode := 403
+
+	auditEvent := &AuditLogEvent{
+		AuditMessage:   common.StringOrNil("Audit:AuthorizationDenied"),
+		Timestamp:      &timestamp,
+		RequestID:      common.StringOrNil(requestID),
+		UserID:         common.StringOrNil(userID),
+		AppID:          common.StringOrNil(appID),
+		OrgID:          common.StringOrNil(orgID),
+		RemoteAddress:  common.StringOrNil(remoteAddress),
+		RequestPath:    common.StringOrNil(requestPath),
+		RequestMethod:  common.StringOrNil(requestMethod),
+		Host:           common.StringOrNil(host),
+		ResponseStatus: &statusCode,
+		Subject:        subject,
+		Reason:         common.StringOrNil(reason),
+	}
+
+	writeAuditLogEvent(auditEvent)
+
+	// denials are also logged at warning level so they are visible without trace logging
+	logEvent, _ := json.Marshal(*auditEvent)
+	common.Log.Warningf("authorization denied; %s", string(logEvent))
+}
+
 func writeAuditLogEvent(event *AuditLogEvent) {
 	logEvent, _ := json.Marshal(*event)
 	common.Log.Trace(string(logEvent))
@@ -0,0 +1,88 @@
+package vault
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	identcommon "github.com/provideplatform/ident/common"
+	"github.com/provideplatform/ident/token"
+	provide "github.com/provideplatform/provide-go/common"
+	"github.com/provideplatform/vault/common"
+)
+
+// operatorSubjectWildcard is rejected as an operator subject, as it would authorize any
+// authenticated bearer to perform operator actions
+const operatorSubjectWildcard = "*"
+
+var (
+	// operatorPermission is the permission which authorizes a bearer to perform operator
+	// actions (i.e., seal, unseal, rekey and generate unsealer keys); defaults to sudo
+	operatorPermission identcommon.Permission
+)
+
+func init() {
+	operatorPermission = identcommon.Sudo
+	if os.Getenv("VAULT_OPERATOR_PERMISSION") != "" {
+		permission, err := strconv.ParseUint(os.Getenv("VAULT_OPERATOR_PERMISSION"), 0, 32)
+		if err != nil || permission == 0 {
+			common.Log.Warningf("failed to parse VAULT_OPERATOR_PERMISSION; defaulting to sudo permission")
+		} else {
+			operatorPermission = identcommon.Permission(permission)
+		}
+	}
+
+	for _, subject := range strings.Split(os.Getenv("VAULT_OPERATOR_SUBJECTS"), ",") {
+		if strings.TrimSpace(subject) == operatorSubjectWildcard {
+			common.Log.Panicf("VAULT_OPERATOR_SUBJECTS must not authorize any authenticated bearer to seal, unseal and rekey the vault")
+		}
+	}
+}
+
+// operatorSubjects returns the configured allowlist of subjects (i.e., user:<id> or
+// application:<id>) which are authorized to perform operator actions; the wildcard is
+// never authorized
+func operatorSubjects() map[string]bool {
+	subjects := map[string]bool{}
+	for _, subject := range strings.Split(os.Getenv("VAULT_OPERATOR_SUBJECTS"), ",") {
+		subject = strings.TrimSpace(subject)
+		if subject != "" && subject != operatorSubjectWildcard {
+			subjects[subject] = true
+		}
+	}
+	return subjects
+}
+
+// IsOperator returns true if the given bearer is authorized to perform operator actions,
+// either by way of the configured operator permission or the configured subject allowlist
+func IsOperator(bearer *token.Token) bool {
+	if bearer == nil {
+		return false
+	}
+
+	if operatorPermission != 0 && bearer.HasPermission(operatorPermission) {
+		return true
+	}
+
+	return bearer.Subject != nil && operatorSubjects()[*bearer.Subject]
+}
+
+// requireOperator resolves the bearer from the given context and ensures it is authorized
+// to perform operator actions; denied attempts are audited and a 403 is rendered, in which
+// case the caller should return immediately
+func requireOperator(c *gin.Context) (*token.Token, bool) {
+	bearer := token.InContext(c)
+	if IsOperator(bearer) {
+		return bearer, true
+	}
+
+	var subject *string
+	if bearer != nil {
+		subject = bearer.Subject
+	}
+
+	AuditAuthorizationDenied(c, subject, "operator authorization required")
+	provide.RenderError("forbidden", 403, c)
+	return nil, false
+}
@@ -0,0 +1,229 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/kthomas/go.uuid"
+	"github.com/provideplatform/ident/token"
+	provide "github.com/provideplatform/provide-go/common"
+	"github.com/provideplatform/vault/common"
+)
+
+// DefaultBatchMaxSize is the default maximum number of items in a batch request
+const DefaultBatchMaxSize = 1000
+
+// batchMaxSize is the maximum number of items in a batch request; configurable using BATCH_MAX_SIZE
+var batchMaxSize int
+
+func init() {
+	batchMaxSize = DefaultBatchMaxSize
+	if os.Getenv("BATCH_MAX_SIZE") != "" {
+		size, err := strconv.Atoi(os.Getenv("BATCH_MAX_SIZE"))
+		if err != nil || size < 1 {
+			common.Log.Warningf("invalid BATCH_MAX_SIZE: %s; using default maximum batch size: %d", os.Getenv("BATCH_MAX_SIZE"), DefaultBatchMaxSize)
+		} else {
+			batchMaxSize = size
+		}
+	}
+}
+
+// KeyEncryptDecryptBatchRequestResponse contains a batch of items to be encrypted/decrypted
+// using a single key; results are returned in the order of the items, and each result
+// contains either the encrypted/decrypted data or the error which caused the item to fail
+type KeyEncryptDecryptBatchRequestResponse struct {
+	Items   []*KeyEncryptDecryptRequestResponse `json:"items,omitempty"`
+	Results []*KeyEncryptDecryptBatchResult     `json:"results,omitempty"`
+}
+
+// KeyEncryptDecryptBatchResult is the result of a single item of an encrypt/decrypt batch
+type KeyEncryptDecryptBatchResult struct {
+	*KeyEncryptDecryptRequestResponse
+	Error *string `json:"error,omitempty"`
+}
+
+// KeySignVerifyBatchRequestResponse contains a batch of messages to be signed/verified
+// using a single key; results are returned in the order of the items, and each result
+// contains either the signature/verification or the error which caused the item to fail
+type KeySignVerifyBatchRequestResponse struct {
+	Items   []*KeySignVerifyRequestResponse `json:"items,omitempty"`
+	Results []*KeySignVerifyBatchResult     `json:"results,omitempty"`
+}
+
+// KeySignVerifyBatchResult is the result of a single item of a sign/verify batch
+type KeySignVerifyBatchResult struct {
+	*KeySignVerifyRequestResponse
+	Error *string `json:"error,omitempty"`
+}
+
+// ValidateBatchSize returns an error if a batch of the given size is empty or exceeds the maximum batch size
+func ValidateBatchSize(size int) error {
+	if size == 0 {
+		return fmt.Errorf("requires at least one item")
+	}
+
+	if size > batchMaxSize {
+		return fmt.Errorf("batch of %d items exceeds maximum batch size of %d items", size, batchMaxSize)
+	}
+
+	return nil
+}
+
+// resolveBatchKey parses the batch request into params and resolves the key for the
+// batch; renders the appropriate error and returns nil if the batch cannot be processed
+func resolveBatchKey(c *gin.Context, params interface{}, size func() int) *Key {
+	bearer := token.InContext(c)
+
+	if vaultIsSealed() {
+		provide.RenderError("vault is sealed", 403, c)
+		return nil
+	}
+
+	buf, err := c.GetRawData()
+	if err != nil {
+		provide.RenderError(err.Error(), 400, c)
+		return nil
+	}
+
+	err = json.Unmarshal(buf, params)
+	if err != nil {
+		provide.RenderError(err.Error(), 400, c)
+		return nil
+	}
+
+	err = ValidateBatchSize(size())
+	if err != nil {
+		provide.RenderError(err.Error(), 422, c)
+		return nil
+	}
+
+	var key = &Key{}
+	key = GetVaultKey(c.Param("keyId"), c.Param("id"), bearer.ApplicationID, bearer.OrganizationID, bearer.UserID)
+
+	if key.ID == uuid.Nil {
+		provide.RenderError("key not found", 404, c)
+		return nil
+	}
+
+	return key
+}
+
+// EncryptBatch encrypts each item using the key, which is unsealed once for the batch;
+// an error is returned only if the key cannot be unsealed, otherwise a failed item is
+// reported in its result without failing the remainder of the batch
+func (k *Key) EncryptBatch(items []*KeyEncryptDecryptRequestResponse) ([]*KeyEncryptDecryptBatchResult, error) {
+	release, err := k.holdDecrypted()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	results := make([]*KeyEncryptDecryptBatchResult, len(items))
+	for i, item := range items {
+		results[i] = &KeyEncryptDecryptBatchResult{}
+		if item == nil || item.Data == nil {
+			results[i].Error = common.StringOrNil("requires data to be encrypted")
+			continue
+		}
+
+		resp, _, err := encryptRequest(k, item)
+		if err != nil {
+			results[i].Error = common.StringOrNil(err.Error())
+			continue
+		}
+		results[i].KeyEncryptDecryptRequestResponse = resp
+	}
+
+	return results, nil
+}
+
+// DecryptBatch decrypts each item using the key, which is unsealed once for the batch;
+// an error is returned only if the key cannot be unsealed, otherwise a failed item is
+// reported in its result without failing the remainder of the batch
+func (k *Key) DecryptBatch(db *gorm.DB, items []*KeyEncryptDecryptRequestResponse) ([]*KeyEncryptDecryptBatchResult, error) {
+	release, err := k.holdDecrypted()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	results := make([]*KeyEncryptDecryptBatchResult, len(items))
+	for i, item := range items {
+		results[i] = &KeyEncryptDecryptBatchResult{}
+		if item == nil || item.Data == nil {
+			results[i].Error = common.StringOrNil("requires data to be decrypted")
+			continue
+		}
+
+		resp, _, err := decryptRequest(db, k, item)
+		if err != nil {
+			results[i].Error = common.StringOrNil(err.Error())
+			continue
+		}
+		results[i].KeyEncryptDecryptRequestResponse = resp
+	}
+
+	return results, nil
+}
+
+// SignBatch signs each message using the key, which is unsealed once for the batch;
+// an error is returned only if the key cannot be unsealed, otherwise a failed item is
+// reported in its result without failing the remainder of the batch
+func (k *Key) SignBatch(items []*KeySignVerifyRequestResponse) ([]*KeySignVerifyBatchResult, error) {
+	release, err := k.holdDecrypted()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	results := make([]*KeySignVerifyBatchResult, len(items))
+	for i, item := range items {
+		results[i] = &KeySignVerifyBatchResult{}
+		if item == nil || item.Message == nil || item.Signature != nil || item.Verified != nil {
+			results[i].Error = common.StringOrNil("only the message to be signed should be provided")
+			continue
+		}
+
+		resp, _, err := signRequest(k, item)
+		if err != nil {
+			results[i].Error = common.StringOrNil(err.Error())
+			continue
+		}
+		results[i].KeySignVerifyRequestResponse = resp
+	}
+
+	return results, nil
+}
+
+// VerifyBatch verifies each signature using the key, which is unsealed once for the batch;
+// an error is returned only if the key cannot be unsealed, otherwise a failed item is
+// reported in its result without failing the remainder of the batch
+func (k *Key) VerifyBatch(db *gorm.DB, items []*KeySignVerifyRequestResponse) ([]*KeySignVerifyBatchResult, error) {
+	release, err := k.holdDecrypted()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	results := make([]*KeySignVerifyBatchResult, len(items))
+	for i, item := range items {
+		results[i] = &KeySignVerifyBatchResult{}
+		if item == nil || item.Signature == nil || item.Message == nil || item.Verified != nil {
+			results[i].Error = common.StringOrNil("only the message and signature to be verified should be provided")
+			continue
+		}
+
+		resp, _, err := verifyRequest(db, k, item)
+		if err != nil {
+			results[i].Error = common.StringOrNil(err.Error())
+			continue
+		}
+		results[i].KeySignVerifyRequestResponse = resp
+	}
+
+	return results, nil
+}
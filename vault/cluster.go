@@ -0,0 +1,246 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/kthomas/go-redisutil"
+	uuid "github.com/kthomas/go.uuid"
+	"github.com/provideplatform/vault/common"
+)
+
+// clusterSealChannel is the redis pub/sub channel on which seal events are broadcast
+const clusterSealChannel = "vault.seal"
+
+// clusterInstancesKey is the redis hash in which the seal status of each instance is stored
+const clusterInstancesKey = "vault.instances"
+
+// clusterHeartbeatInterval is the interval at which each instance reports its seal status
+const clusterHeartbeatInterval = time.Second * 5
+
+// clusterInstanceTTL is the duration after which an instance which has not reported its
+// seal status is considered to have left the cluster
+const clusterInstanceTTL = clusterHeartbeatInterval * 3
+
+var (
+	// instanceID uniquely identifies this vault instance within the cluster
+	instanceID string
+
+	// instanceHostname is the hostname of this vault instance
+	instanceHostname string
+)
+
+func init() {
+	id, _ := uuid.NewV4()
+	instanceID = id.String()
+	instanceHostname, _ = os.Hostname()
+}
+
+// SealEvent is broadcast to all vault instances when an instance is sealed
+type SealEvent struct {
+	InstanceID string    `json:"instance_id"`
+	Sealed     bool      `json:"sealed"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// InstanceStatus is the seal status reported by a vault instance
+type InstanceStatus struct {
+	InstanceID string    `json:"instance_id"`
+	Hostname   string    `json:"hostname,omitempty"`
+	Provider   string    `json:"provider,omitempty"`
+	Sealed     bool      `json:"sealed"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ClusterSealStatus is the seal status of this vault instance and each known instance in the cluster
+type ClusterSealStatus struct {
+	InstanceID string            `json:"instance_id"`
+	Sealed     bool              `json:"sealed"`
+	Instances  []*InstanceStatus `json:"instances"`
+}
+
+// RunClusterSync subscribes to seal events broadcast by other vault instances, sealing
+// this instance immediately upon receipt, and periodically reports the seal status of
+// this instance; it returns once the given context is done
+func RunClusterSync(ctx context.Context) {
+	if redisutil.RedisClient == nil && redisutil.RedisClusterClient == nil {
+		common.Log.Warning("redis not configured; seal events will not be propagated to other vault instances")
+		return
+	}
+
+	var pubsub *redis.PubSub
+	if redisutil.RedisClusterClient != nil {
+		pubsub = redisutil.RedisClusterClient.Subscribe(clusterSealChannel)
+	} else {
+		pubsub = redisutil.RedisClient.Subscribe(clusterSealChannel)
+	}
+	defer pubsub.Close()
+
+	common.Log.Debugf("vault instance %s subscribed to seal events on channel: %s", instanceID, clusterSealChannel)
+
+	reportInstanceStatus()
+	defer removeInstanceStatus()
+
+	timer := time.NewTicker(clusterHeartbeatInterval)
+	defer timer.Stop()
+
+	messages := pubsub.Channel()
+
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				common.Log.Warning("seal event subscription closed")
+				return
+			}
+			handleSealEvent([]byte(msg.Payload))
+		case <-timer.C:
+			reportInstanceStatus()
+		case <-ctx.Done():
+			common.Log.Debugf("vault instance %s unsubscribing from seal events", instanceID)
+			return
+		}
+	}
+}
+
+// GetClusterSealStatus returns the seal status of this vault instance and each
+// known instance in the cluster
+func GetClusterSealStatus() (*ClusterSealStatus, error) {
+	status := &ClusterSealStatus{
+		InstanceID: instanceID,
+		Sealed:     vaultIsSealed(),
+		Instances:  make([]*InstanceStatus, 0),
+	}
+
+	if redisutil.RedisClient == nil && redisutil.RedisClusterClient == nil {
+		status.Instances = append(status.Instances, currentInstanceStatus())
+		return status, nil
+	}
+
+	var instances map[string]string
+	var err error
+
+	if redisutil.RedisClusterClient != nil {
+		instances, err = redisutil.RedisClusterClient.HGetAll(clusterInstancesKey).Result()
+	} else {
+		instances, err = redisutil.RedisClient.HGetAll(clusterInstancesKey).Result()
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve vault instance seal status; %s", err.Error())
+	}
+
+	for id, raw := range instances {
+		instance := &InstanceStatus{}
+		err := json.Unmarshal([]byte(raw), &instance)
+		if err != nil {
+			common.Log.Warningf("failed to unmarshal seal status of vault instance %s; %s", id, err.Error())
+			continue
+		}
+
+		if time.Since(instance.UpdatedAt) > clusterInstanceTTL {
+			common.Log.Debugf("pruning seal status of departed vault instance %s", id)
+			hdelInstanceStatus(id)
+			continue
+		}
+
+		status.Instances = append(status.Instances, instance)
+	}
+
+	sort.Slice(status.Instances, func(i, j int) bool {
+		return status.Instances[i].InstanceID < status.Instances[j].InstanceID
+	})
+
+	return status, nil
+}
+
+// publishSealEvent broadcasts that this instance has been sealed, so that every
+// other instance seals immediately
+func publishSealEvent() {
+	reportInstanceStatus()
+
+	payload, _ := json.Marshal(&SealEvent{
+		InstanceID: instanceID,
+		Sealed:     true,
+		Timestamp:  time.Now(),
+	})
+
+	var err error
+	if redisutil.RedisClusterClient != nil {
+		err = redisutil.RedisClusterClient.Publish(clusterSealChannel, string(payload)).Err()
+	} else if redisutil.RedisClient != nil {
+		err = redisutil.RedisClient.Publish(clusterSealChannel, string(payload)).Err()
+	} else {
+		return
+	}
+
+	if err != nil {
+		common.Log.Warningf("failed to broadcast seal event to vault instances; %s", err.Error())
+		return
+	}
+
+	common.Log.Debugf("broadcast seal event from vault instance %s", instanceID)
+}
+
+// handleSealEvent seals this instance upon receipt of a seal event from another instance
+func handleSealEvent(payload []byte) {
+	evt := &SealEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		common.Log.Warningf("failed to unmarshal seal event; %s", err.Error())
+		return
+	}
+
+	if evt.InstanceID == instanceID || !evt.Sealed {
+		return
+	}
+
+	common.Log.Debugf("sealing vault instance %s; seal event received from vault instance %s", instanceID, evt.InstanceID)
+	sealVault()
+	reportInstanceStatus()
+}
+
+// currentInstanceStatus returns the seal status of this instance
+func currentInstanceStatus() *InstanceStatus {
+	return &InstanceStatus{
+		InstanceID: instanceID,
+		Hostname:   instanceHostname,
+		Provider:   providerName,
+		Sealed:     vaultIsSealed(),
+		UpdatedAt:  time.Now(),
+	}
+}
+
+// reportInstanceStatus writes the seal status of this instance to the cluster instances hash
+func reportInstanceStatus() {
+	payload, _ := json.Marshal(currentInstanceStatus())
+
+	var err error
+	if redisutil.RedisClusterClient != nil {
+		err = redisutil.RedisClusterClient.HSet(clusterInstancesKey, instanceID, string(payload)).Err()
+	} else if redisutil.RedisClient != nil {
+		err = redisutil.RedisClient.HSet(clusterInstancesKey, instanceID, string(payload)).Err()
+	}
+
+	if err != nil {
+		common.Log.Warningf("failed to report seal status of vault instance %s; %s", instanceID, err.Error())
+	}
+}
+
+// removeInstanceStatus removes this instance from the cluster instances hash
+func removeInstanceStatus() {
+	hdelInstanceStatus(instanceID)
+}
+
+func hdelInstanceStatus(id string) {
+	if redisutil.RedisClusterClient != nil {
+		redisutil.RedisClusterClient.HDel(clusterInstancesKey, id)
+	} else if redisutil.RedisClient != nil {
+		redisutil.RedisClient.HDel(clusterInstancesKey, id)
+	}
+}
@@ -0,0 +1,58 @@
+package vault
+
+import (
+	"fmt"
+
+	"github.com/provideplatform/vault/common"
+	"github.com/provideplatform/vault/crypto"
+)
+
+// DataKeySize is the size in bytes of generated data keys
+const DataKeySize = 32
+
+// KeyGenerateDataKeyResponse contains a generated data key; the plaintext data key is
+// hex-encoded and omitted when generated without plaintext, and the ciphertext is the
+// data key wrapped by the vault key as a ciphertext envelope, which may be decrypted
+// using the vault key to recover the plaintext data key
+type KeyGenerateDataKeyResponse struct {
+	Plaintext  *string `json:"plaintext,omitempty"`
+	Ciphertext *string `json:"ciphertext"`
+	Version    *int    `json:"version"`
+}
+
+// GenerateDataKey generates a random 256-bit data key for local envelope encryption and
+// wraps it using the latest version of the key; returns the plaintext and wrapped data key
+func (k *Key) GenerateDataKey() ([]byte, []byte, error) {
+	if k.Spec == nil || (*k.Spec != KeySpecAES256GCM && *k.Spec != KeySpecChaCha20 && *k.Spec != KeySpecXChaCha20Poly1305 && *k.Spec != KeySpecAES256SIV) {
+		return nil, nil, fmt.Errorf("failed to generate data key using key: %s; nil or invalid key spec", k.ID)
+	}
+
+	if k.Usage == nil || *k.Usage != KeyUsageEncryptDecrypt {
+		return nil, nil, fmt.Errorf("failed to generate data key using key: %s; key usage must be %s", k.ID, KeyUsageEncryptDecrypt)
+	}
+
+	dataKey, err := common.RandomBytes(DataKeySize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key using key: %s; %s", k.ID, err.Error())
+	}
+
+	wrappedDataKey, err := k.Encrypt(dataKey, nil)
+	if err != nil {
+		crypto.WipeBytes(dataKey)
+		return nil, nil, fmt.Errorf("failed to wrap data key using key: %s; %s", k.ID, err.Error())
+	}
+
+	return dataKey, wrappedDataKey, nil
+}
+
+// GenerateDataKeyWithoutPlaintext generates a random 256-bit data key as GenerateDataKey
+// does, but returns only the wrapped data key; the plaintext is wiped from memory
+func (k *Key) GenerateDataKeyWithoutPlaintext() ([]byte, error) {
+	dataKey, wrappedDataKey, err := k.GenerateDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	crypto.WipeBytes(dataKey)
+	return wrappedDataKey, nil
+}
@@ -0,0 +1,89 @@
+package vault
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DataEncodingUTF8 utf8 data encoding; the data is used as-is and must be valid utf8
+const DataEncodingUTF8 = "utf8"
+
+// DataEncodingHex hex data encoding
+const DataEncodingHex = "hex"
+
+// DataEncodingBase64 standard base64 data encoding, with padding
+const DataEncodingBase64 = "base64"
+
+// DataEncodingBase64URL url-safe base64 data encoding; encoded without padding, and
+// decoded with or without padding
+const DataEncodingBase64URL = "base64url"
+
+// ValidateDataEncoding performs a case-insensitive data encoding validation and returns
+// the correctly cased encoding, or the given default encoding when encoding is nil
+func ValidateDataEncoding(encoding *string, defaultEncoding string) (string, error) {
+	if encoding == nil {
+		return defaultEncoding, nil
+	}
+
+	switch strings.ToLower(*encoding) {
+	case DataEncodingUTF8, "utf-8":
+		return DataEncodingUTF8, nil
+	case DataEncodingHex:
+		return DataEncodingHex, nil
+	case DataEncodingBase64:
+		return DataEncodingBase64, nil
+	case DataEncodingBase64URL:
+		return DataEncodingBase64URL, nil
+	}
+
+	return "", fmt.Errorf("invalid encoding: %s; encoding must be one of (%s, %s, %s, %s)", *encoding, DataEncodingUTF8, DataEncodingHex, DataEncodingBase64, DataEncodingBase64URL)
+}
+
+// DecodeData decodes the given data using the given encoding
+func DecodeData(data, encoding string) ([]byte, error) {
+	var decoded []byte
+	var err error
+
+	switch encoding {
+	case DataEncodingUTF8:
+		decoded = []byte(data)
+	case DataEncodingHex:
+		decoded, err = hex.DecodeString(data)
+	case DataEncodingBase64:
+		decoded, err = base64.StdEncoding.DecodeString(data)
+	case DataEncodingBase64URL:
+		decoded, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(data, "="))
+	default:
+		return nil, fmt.Errorf("failed to decode data; invalid encoding: %s", encoding)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode data from %s; %s", encoding, err.Error())
+	}
+
+	return decoded, nil
+}
+
+// EncodeData encodes the given data using the given encoding; returns an error when
+// the encoding is utf8 and the data is not valid utf8, as the data would otherwise be
+// corrupted when rendered as json
+func EncodeData(data []byte, encoding string) (string, error) {
+	switch encoding {
+	case DataEncodingUTF8:
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("failed to encode data; data is not valid %s; use a binary-safe encoding (%s, %s or %s)", DataEncodingUTF8, DataEncodingHex, DataEncodingBase64, DataEncodingBase64URL)
+		}
+		return string(data), nil
+	case DataEncodingHex:
+		return hex.EncodeToString(data), nil
+	case DataEncodingBase64:
+		return base64.StdEncoding.EncodeToString(data), nil
+	case DataEncodingBase64URL:
+		return base64.RawURLEncoding.EncodeToString(data), nil
+	}
+
+	return "", fmt.Errorf("failed to encode data; invalid encoding: %s", encoding)
+}
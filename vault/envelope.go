@@ -0,0 +1,118 @@
+package vault
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ciphertextEnvelopePrefix is the prefix of every ciphertext envelope
+const ciphertextEnvelopePrefix = "vault"
+
+// ciphertextEnvelopeV1 is the current ciphertext envelope format version
+const ciphertextEnvelopeV1 = "v1"
+
+// ciphertextEnvelopeSeparator separates the fields of a ciphertext envelope
+const ciphertextEnvelopeSeparator = ":"
+
+// CiphertextEnvelope is a self-describing ciphertext, serialized as
+// vault:v1:<key-version>:<alg>:<base64>; the algorithm is the spec of the key which
+// produced the ciphertext and determines the length of the nonce, if any, which is
+// prepended to the base64-encoded ciphertext
+type CiphertextEnvelope struct {
+	KeyVersion int
+	Algorithm  string
+	Ciphertext []byte // nonce||ciphertext
+}
+
+// IsCiphertextEnvelope returns true if the given data is a serialized ciphertext envelope
+func IsCiphertextEnvelope(data string) bool {
+	return strings.HasPrefix(data, ciphertextEnvelopePrefix+ciphertextEnvelopeSeparator)
+}
+
+// ParseCiphertextEnvelope parses the given serialized ciphertext envelope
+func ParseCiphertextEnvelope(data string) (*CiphertextEnvelope, error) {
+	parts := strings.Split(data, ciphertextEnvelopeSeparator)
+	if len(parts) != 5 || parts[0] != ciphertextEnvelopePrefix {
+		return nil, fmt.Errorf("failed to parse ciphertext envelope; malformed envelope")
+	}
+
+	if parts[1] != ciphertextEnvelopeV1 {
+		return nil, fmt.Errorf("failed to parse ciphertext envelope; unsupported envelope version: %s", parts[1])
+	}
+
+	keyVersion, err := strconv.Atoi(parts[2])
+	if err != nil || keyVersion < 0 {
+		return nil, fmt.Errorf("failed to parse ciphertext envelope; invalid key version: %s", parts[2])
+	}
+
+	if parts[3] == "" {
+		return nil, fmt.Errorf("failed to parse ciphertext envelope; nil algorithm")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ciphertext envelope; %s", err.Error())
+	}
+
+	return &CiphertextEnvelope{
+		KeyVersion: keyVersion,
+		Algorithm:  parts[3],
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// String serializes the ciphertext envelope
+func (e *CiphertextEnvelope) String() string {
+	return strings.Join([]string{
+		ciphertextEnvelopePrefix,
+		ciphertextEnvelopeV1,
+		strconv.Itoa(e.KeyVersion),
+		strings.ToLower(e.Algorithm),
+		base64.StdEncoding.EncodeToString(e.Ciphertext),
+	}, ciphertextEnvelopeSeparator)
+}
+
+// ciphertextEnvelope wraps the given ciphertext, produced by the latest version
+// of the key, in a ciphertext envelope
+func (k *Key) ciphertextEnvelope(ciphertext []byte) *CiphertextEnvelope {
+	return &CiphertextEnvelope{
+		KeyVersion: k.Version,
+		Algorithm:  *k.Spec,
+		Ciphertext: ciphertext,
+	}
+}
+
+// parseCiphertext parses the given ciphertext envelope or, for ciphertexts produced prior
+// to the envelope format, raw hex-encoded ciphertext; returns the ciphertext and the key
+// version which produced it, or the given version when the ciphertext is raw hex
+func (k *Key) parseCiphertext(data string, version *int) ([]byte, *int, error) {
+	if !IsCiphertextEnvelope(data) {
+		ciphertext, err := hex.DecodeString(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error decoding encrypted string to binary")
+		}
+		return ciphertext, version, nil
+	}
+
+	envelope, err := ParseCiphertextEnvelope(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if k.Spec == nil || !strings.EqualFold(envelope.Algorithm, *k.Spec) {
+		return nil, nil, fmt.Errorf("ciphertext envelope algorithm %s does not match spec of key: %s", envelope.Algorithm, k.ID)
+	}
+
+	if version != nil && *version != envelope.KeyVersion {
+		return nil, nil, fmt.Errorf("ciphertext envelope key version %d does not match requested version %d", envelope.KeyVersion, *version)
+	}
+
+	if len(envelope.Ciphertext) < k.nonceSize() {
+		return nil, nil, fmt.Errorf("ciphertext envelope does not contain a %d-byte nonce and ciphertext", k.nonceSize())
+	}
+
+	return envelope.Ciphertext, &envelope.KeyVersion, nil
+}
@@ -187,6 +187,61 @@ func Chacha20Factory(db *gorm.DB, vaultID *uuid.UUID, name, description string)
 	return key, nil
 }
 
+// XChaCha20Poly1305Factory XChaCha20-Poly1305
+func XChaCha20Poly1305Factory(db *gorm.DB, vaultID *uuid.UUID, name, description string) (*Key, error) {
+	key := &Key{
+		VaultID:     vaultID,
+		Name:        common.StringOrNil(name),
+		Description: common.StringOrNil(description),
+		Spec:        common.StringOrNil(KeySpecXChaCha20Poly1305),
+		Type:        common.StringOrNil(KeyTypeSymmetric),
+		Usage:       common.StringOrNil(KeyUsageEncryptDecrypt),
+	}
+
+	if !key.createPersisted(db) {
+		return nil, fmt.Errorf("error creating/persisting %s key: %v", KeySpecXChaCha20Poly1305, *key.Errors[0].Message)
+	}
+
+	return key, nil
+}
+
+// AES256SIVFactory AES-256-SIV; deterministic enables deterministic encryption using the key
+func AES256SIVFactory(db *gorm.DB, vaultID *uuid.UUID, name, description string, deterministic bool) (*Key, error) {
+	key := &Key{
+		VaultID:       vaultID,
+		Name:          common.StringOrNil(name),
+		Description:   common.StringOrNil(description),
+		Spec:          common.StringOrNil(KeySpecAES256SIV),
+		Type:          common.StringOrNil(KeyTypeSymmetric),
+		Usage:         common.StringOrNil(KeyUsageEncryptDecrypt),
+		Deterministic: &deterministic,
+	}
+
+	if !key.createPersisted(db) {
+		return nil, fmt.Errorf("error creating/persisting %s key: %v", KeySpecAES256SIV, *key.Errors[0].Message)
+	}
+
+	return key, nil
+}
+
+// HMACFactory HMAC-SHA256, HMAC-SHA384 or HMAC-SHA512, according to the given spec
+func HMACFactory(db *gorm.DB, vaultID *uuid.UUID, spec, name, description string) (*Key, error) {
+	key := &Key{
+		VaultID:     vaultID,
+		Name:        common.StringOrNil(name),
+		Description: common.StringOrNil(description),
+		Spec:        common.StringOrNil(spec),
+		Type:        common.StringOrNil(KeyTypeSymmetric),
+		Usage:       common.StringOrNil(KeyUsageMACVerify),
+	}
+
+	if !key.createPersisted(db) {
+		return nil, fmt.Errorf("error creating/persisting %s key: %v", spec, *key.Errors[0].Message)
+	}
+
+	return key, nil
+}
+
 // Ed25519Factory Ed25519
 func Ed25519Factory(db *gorm.DB, vaultID *uuid.UUID, name, description string) (*Key, error) {
 	key := &Key{
@@ -241,6 +296,24 @@ func Secp256k1Factory(db *gorm.DB, vaultID *uuid.UUID, name, description string)
 	return key, nil
 }
 
+// ECDSAFactory NIST P-256, P-384 or P-521, according to the given spec
+func ECDSAFactory(db *gorm.DB, vaultID *uuid.UUID, spec, name, description string) (*Key, error) {
+	key := &Key{
+		VaultID:     vaultID,
+		Name:        common.StringOrNil(name),
+		Description: common.StringOrNil(description),
+		Spec:        common.StringOrNil(spec),
+		Type:        common.StringOrNil(KeyTypeAsymmetric),
+		Usage:       common.StringOrNil(KeyUsageSignVerify),
+	}
+
+	if !key.createPersisted(db) {
+		return nil, fmt.Errorf("error creating/persisting %s keypair: %v", spec, *key.Errors[0].Message)
+	}
+
+	return key, nil
+}
+
 // EthHDWalletFactory secp256k1 HD wallet for deriving ETH keys/addresses
 func EthHDWalletFactory(db *gorm.DB, vaultID *uuid.UUID, name, description string) (*Key, error) {
 	key := &Key{
@@ -1,12 +1,15 @@
 package vault
 
 import (
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
+	"strconv"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts"
@@ -21,6 +24,7 @@ import (
 	provide "github.com/provideplatform/provide-go/common"
 	"github.com/provideplatform/vault/common"
 	"github.com/provideplatform/vault/crypto"
+	"github.com/provideplatform/vault/vault/providers"
 )
 
 // InstallAPI installs the handlers using the given gin Engine
@@ -35,24 +39,40 @@ func installSealUnsealAPI(r *gin.Engine) {
 	r.POST("/api/v1/unsealerkey", createUnsealerKeyHandler)
 	r.POST("/api/v1/unseal", unsealHandler)
 	r.POST("/api/v1/seal", sealHandler)
+	r.GET("/api/v1/seal/status", sealStatusHandler)
+	r.POST("/api/v1/rekey", rekeyHandler)
 }
 
 func installVaultsAPI(r *gin.Engine) {
 	r.GET("/api/v1/vaults", vaultsListHandler)
 	r.POST("/api/v1/vaults", createVaultHandler)
 	r.DELETE("/api/v1/vaults/:id", deleteVaultHandler)
+	r.POST("/api/v1/vaults/:id/rotate", rotateVaultMasterKeyHandler)
 }
 
 func installKeysAPI(r *gin.Engine) {
 	r.GET("/api/v1/vaults/:id/keys", vaultKeysListHandler)
 	r.POST("/api/v1/vaults/:id/keys", createVaultKeyHandler)
+	r.POST("/api/v1/vaults/:id/import/params", vaultKeyImportParamsHandler)
+	r.POST("/api/v1/vaults/:id/import", vaultKeyImportHandler)
 	r.GET("api/v1/vaults/:id/keys/:keyId", vaultKeyDetailsHandler)
 	r.POST("api/v1/vaults/:id/keys/:keyId/derive", vaultKeyDeriveHandler)
 	r.POST("/api/v1/vaults/:id/keys/:keyId/encrypt", vaultKeyEncryptHandler)
 	r.POST("/api/v1/vaults/:id/keys/:keyId/decrypt", vaultKeyDecryptHandler)
+	r.POST("/api/v1/vaults/:id/keys/:keyId/encrypt/batch", vaultKeyEncryptBatchHandler)
+	r.POST("/api/v1/vaults/:id/keys/:keyId/decrypt/batch", vaultKeyDecryptBatchHandler)
+	r.POST("/api/v1/vaults/:id/keys/:keyId/reencrypt", vaultKeyReencryptHandler)
+	r.POST("/api/v1/vaults/:id/keys/:keyId/generate-data-key", vaultKeyGenerateDataKeyHandler)
+	r.POST("/api/v1/vaults/:id/keys/:keyId/generate-data-key/without-plaintext", vaultKeyGenerateDataKeyWithoutPlaintextHandler)
 	r.POST("/api/v1/vaults/:id/keys/:keyId/sign", vaultKeySignHandler)
 	r.POST("/api/v1/vaults/:id/keys/:keyId/verify", vaultKeyVerifyHandler)
+	r.POST("/api/v1/vaults/:id/keys/:keyId/sign/batch", vaultKeySignBatchHandler)
+	r.POST("/api/v1/vaults/:id/keys/:keyId/verify/batch", vaultKeyVerifyBatchHandler)
+	r.POST("/api/v1/vaults/:id/keys/:keyId/mac", vaultKeyMACHandler)
+	r.POST("/api/v1/vaults/:id/keys/:keyId/verify-mac", vaultKeyVerifyMACHandler)
 	r.DELETE("/api/v1/vaults/:id/keys/:keyId", deleteVaultKeyHandler)
+	r.POST("/api/v1/vaults/:id/keys/:keyId/rotate", rotateVaultKeyHandler)
+	r.DELETE("/api/v1/vaults/:id/keys/:keyId/versions/:version", destroyVaultKeyVersionHandler)
 	r.POST("api/v1/bls/aggregate", blsAggregateHandler)
 	r.POST("api/v1/bls/verify", blsAggregateVerifyHandler)
 	r.POST("/api/v1/verify", verifyDetachedVerifyHandler)
@@ -67,14 +87,41 @@ func installSecretsAPI(r *gin.Engine) {
 
 // createUnsealerKeyHandler creates the unsealer key
 func createUnsealerKeyHandler(c *gin.Context) {
-	_ = token.InContext(c)
+	if _, ok := requireOperator(c); !ok {
+		return
+	}
 
-	_, err := c.GetRawData()
+	buf, err := c.GetRawData()
 	if err != nil {
 		provide.RenderError(err.Error(), 400, c)
 		return
 	}
 
+	params := &SealUnsealRequestResponse{}
+	if len(buf) > 0 {
+		err = json.Unmarshal(buf, &params)
+		if err != nil {
+			provide.RenderError(err.Error(), 400, c)
+			return
+		}
+	}
+
+	if params.Shares != nil || params.Threshold != nil {
+		if params.Shares == nil || params.Threshold == nil {
+			provide.RenderError("shares and threshold are both required to split the unsealer key", 422, c)
+			return
+		}
+
+		key, err := CreateUnsealerKeyShares(*params.Shares, *params.Threshold)
+		if err != nil {
+			provide.RenderError(err.Error(), 422, c)
+			return
+		}
+
+		provide.Render(key, 201, c)
+		return
+	}
+
 	key, err := CreateUnsealerKey()
 	if err != nil {
 		provide.RenderError(err.Error(), 500, c)
@@ -86,9 +133,9 @@ func createUnsealerKeyHandler(c *gin.Context) {
 
 // unsealHandler enables unlocking the master key for all vaults
 func unsealHandler(c *gin.Context) {
-	// TODO what elements are required in the token to enable the locking/ unlocking of the vault?
-	// currently, it's just a valid token from IDENT and a valid unsealer key
-	_ = token.InContext(c)
+	if _, ok := requireOperator(c); !ok {
+		return
+	}
 
 	buf, err := c.GetRawData()
 	if err != nil {
@@ -103,6 +150,29 @@ func unsealHandler(c *gin.Context) {
 		return
 	}
 
+	if params.KeyShare != nil {
+		if params.UnsealerKey != nil {
+			provide.RenderError("only one of unsealer key or unsealer key share should be provided", 422, c)
+			return
+		}
+
+		resp, err := SubmitUnsealerKeyShare(*params.KeyShare)
+		if err != nil {
+			msg := fmt.Sprintf("failed to unseal vault; %s", err.Error())
+			common.Log.Warning(msg)
+			provide.RenderError(msg, 500, c)
+			return
+		}
+
+		if resp.Sealed != nil && *resp.Sealed {
+			provide.Render(resp, 202, c)
+			return
+		}
+
+		provide.Render(nil, 204, c)
+		return
+	}
+
 	if params.UnsealerKey == nil {
 		provide.RenderError("unsealer key material required", 422, c)
 		return
@@ -121,9 +191,9 @@ func unsealHandler(c *gin.Context) {
 
 // sealHandler enables locking the master key for all vaults
 func sealHandler(c *gin.Context) {
-	// TODO what elements are required in the token to enable the locking/ unlocking of the vault?
-	// currently, it's just a valid token from IDENT and a valid unsealer key
-	_ = token.InContext(c)
+	if _, ok := requireOperator(c); !ok {
+		return
+	}
 
 	buf, err := c.GetRawData()
 	if err != nil {
@@ -154,6 +224,68 @@ func sealHandler(c *gin.Context) {
 	provide.Render(nil, 204, c)
 }
 
+// sealStatusHandler reports the seal status of each known vault instance
+func sealStatusHandler(c *gin.Context) {
+	if _, ok := requireOperator(c); !ok {
+		return
+	}
+
+	status, err := GetClusterSealStatus()
+	if err != nil {
+		provide.RenderError(err.Error(), 500, c)
+		return
+	}
+
+	provide.Render(status, 200, c)
+}
+
+// rekeyHandler rotates the unsealer key and re-wraps the master key for all vaults
+func rekeyHandler(c *gin.Context) {
+	if _, ok := requireOperator(c); !ok {
+		return
+	}
+
+	buf, err := c.GetRawData()
+	if err != nil {
+		provide.RenderError(err.Error(), 400, c)
+		return
+	}
+
+	params := &SealUnsealRequestResponse{}
+	err = json.Unmarshal(buf, &params)
+	if err != nil {
+		provide.RenderError(err.Error(), 400, c)
+		return
+	}
+
+	if params.UnsealerKey == nil {
+		provide.RenderError("unsealer key material required", 422, c)
+		return
+	}
+
+	// the new unsealer key is never generated on behalf of the caller, as the master keys
+	// of a rekey which fails part of the way through are only recoverable using it
+	if params.NewUnsealerKey == nil {
+		provide.RenderError("new unsealer key material required", 422, c)
+		return
+	}
+
+	if !providers.RekeySupported(providerName) {
+		provide.RenderError(fmt.Sprintf("%s: %s", providers.ErrRekeyNotSupported.Error(), providerName), 422, c)
+		return
+	}
+
+	resp, err := Rekey(*params.UnsealerKey, *params.NewUnsealerKey)
+	if err != nil {
+		msg := fmt.Sprintf("failed to rekey vault; %s", err.Error())
+		common.Log.Warning(msg)
+		provide.RenderError(msg, 500, c)
+		return
+	}
+
+	provide.Render(resp, 200, c)
+}
+
 func vaultKeyEncryptHandler(c *gin.Context) {
 	bearer := token.InContext(c)
 
@@ -180,12 +312,47 @@ func vaultKeyEncryptHandler(c *gin.Context) {
 		return
 	}
 
-	// handle empty nonces
-	nonce := []byte{}
-	if params.Nonce != nil {
-		nonce = []byte(*params.Nonce)
-	} else {
-		nonce = nil
+	var key = &Key{}
+	key = GetVaultKey(c.Param("keyId"), c.Param("id"), bearer.ApplicationID, bearer.OrganizationID, bearer.UserID)
+
+	if key.ID == uuid.Nil {
+		provide.RenderError("key not found", 404, c)
+		return
+	}
+
+	resp, status, err := encryptRequest(key, params)
+	if err != nil {
+		provide.RenderError(err.Error(), status, c)
+		return
+	}
+
+	provide.Render(resp, 200, c)
+}
+
+func vaultKeyDecryptHandler(c *gin.Context) {
+	bearer := token.InContext(c)
+
+	if vaultIsSealed() {
+		provide.RenderError("vault is sealed", 403, c)
+		return
+	}
+
+	buf, err := c.GetRawData()
+	if err != nil {
+		provide.RenderError(err.Error(), 400, c)
+		return
+	}
+
+	params := &KeyEncryptDecryptRequestResponse{}
+	err = json.Unmarshal(buf, &params)
+	if err != nil {
+		provide.RenderError(err.Error(), 400, c)
+		return
+	}
+
+	if params.Data == nil {
+		provide.RenderError("requires data to be decrypted", 422, c)
+		return
 	}
 
 	var key = &Key{}
@@ -196,20 +363,152 @@ func vaultKeyEncryptHandler(c *gin.Context) {
 		return
 	}
 
-	encryptedData, err := key.Encrypt([]byte(*params.Data), nonce)
+	resp, status, err := decryptRequest(dbconf.DatabaseConnection(), key, params)
+	if err != nil {
+		provide.RenderError(err.Error(), status, c)
+		return
+	}
+
+	provide.Render(resp, 200, c)
+}
+
+// vaultKeyEncryptBatchHandler encrypts each item of the batch using the key, which is unsealed once for the batch
+func vaultKeyEncryptBatchHandler(c *gin.Context) {
+	params := &KeyEncryptDecryptBatchRequestResponse{}
+	key := resolveBatchKey(c, params, func() int { return len(params.Items) })
+	if key == nil {
+		return
+	}
+
+	results, err := key.EncryptBatch(params.Items)
 	if err != nil {
 		provide.RenderError(err.Error(), 500, c)
 		return
 	}
 
-	encryptedDataHex := hex.EncodeToString(encryptedData)
+	provide.Render(&KeyEncryptDecryptBatchRequestResponse{
+		Results: results,
+	}, 200, c)
+}
 
-	provide.Render(&KeyEncryptDecryptRequestResponse{
-		Data: common.StringOrNil(string(encryptedDataHex)),
+// vaultKeyDecryptBatchHandler decrypts each item of the batch using the key, which is unsealed once for the batch
+func vaultKeyDecryptBatchHandler(c *gin.Context) {
+	params := &KeyEncryptDecryptBatchRequestResponse{}
+	key := resolveBatchKey(c, params, func() int { return len(params.Items) })
+	if key == nil {
+		return
+	}
+
+	results, err := key.DecryptBatch(dbconf.DatabaseConnection(), params.Items)
+	if err != nil {
+		provide.RenderError(err.Error(), 500, c)
+		return
+	}
+
+	provide.Render(&KeyEncryptDecryptBatchRequestResponse{
+		Results: results,
 	}, 200, c)
 }
 
-func vaultKeyDecryptHandler(c *gin.Context) {
+// encryptRequest encrypts the data of the given request using the key; returns the response,
+// or the http status and error which describe why the data could not be encrypted
+func encryptRequest(key *Key, params *KeyEncryptDecryptRequestResponse) (*KeyEncryptDecryptRequestResponse, int, error) {
+	encoding, err := ValidateDataEncoding(params.Encoding, DataEncodingUTF8)
+	if err != nil {
+		return nil, 422, err
+	}
+
+	data, err := DecodeData(*params.Data, encoding)
+	if err != nil {
+		return nil, 422, err
+	}
+
+	// handle empty nonces
+	nonce := []byte{}
+	if params.Nonce != nil {
+		nonce = []byte(*params.Nonce)
+	} else {
+		nonce = nil
+	}
+
+	var encryptedData []byte
+	if params.Deterministic != nil && *params.Deterministic {
+		if nonce != nil {
+			return nil, 422, errors.New("nonce not supported for deterministic encryption")
+		}
+
+		if !key.isDeterministic() {
+			return nil, 422, fmt.Errorf("deterministic encryption not enabled for key: %s", key.ID)
+		}
+
+		encryptedData, err = key.EncryptDeterministic(data, additionalData(params.Context))
+	} else {
+		encryptedData, err = key.EncryptWithAAD(data, nonce, additionalData(params.Context))
+	}
+
+	if err != nil {
+		return nil, 500, err
+	}
+
+	return &KeyEncryptDecryptRequestResponse{
+		Data:    common.StringOrNil(key.ciphertextEnvelope(encryptedData).String()),
+		Version: &key.Version,
+	}, 200, nil
+}
+
+// decryptRequest decrypts the data of the given request using the key; returns the response,
+// or the http status and error which describe why the data could not be decrypted
+func decryptRequest(db *gorm.DB, key *Key, params *KeyEncryptDecryptRequestResponse) (*KeyEncryptDecryptRequestResponse, int, error) {
+	encoding, err := ValidateDataEncoding(params.Encoding, DataEncodingUTF8)
+	if err != nil {
+		return nil, 422, err
+	}
+
+	dataToDecrypt, keyVersion, err := key.parseCiphertext(*params.Data, params.Version)
+	if err != nil {
+		return nil, 422, err
+	}
+
+	err = key.requireCiphertextVersion(keyVersion)
+	if err != nil {
+		return nil, 422, err
+	}
+
+	var decryptedData []byte
+	var version int
+	if params.Deterministic != nil && *params.Deterministic {
+		decryptedData, version, err = key.DecryptDeterministicVersioned(db, dataToDecrypt, additionalData(params.Context), keyVersion)
+	} else {
+		decryptedData, version, err = key.DecryptVersioned(db, dataToDecrypt, additionalData(params.Context), keyVersion)
+	}
+
+	if err != nil {
+		return nil, 500, err
+	}
+
+	decryptedDataString, err := EncodeData(decryptedData, encoding)
+	crypto.WipeBytes(decryptedData)
+	if err != nil {
+		return nil, 422, err
+	}
+
+	return &KeyEncryptDecryptRequestResponse{
+		Data:     common.StringOrNil(decryptedDataString),
+		Version:  &version,
+		Encoding: common.StringOrNil(encoding),
+	}, 200, nil
+}
+
+// additionalData returns the additional authenticated data for the given optional context
+func additionalData(context *string) []byte {
+	if context == nil {
+		return nil
+	}
+	return []byte(*context)
+}
+
+// vaultKeyReencryptHandler decrypts the given ciphertext using the key and encrypts it using the destination key
+func vaultKeyReencryptHandler(c *gin.Context) {
 	bearer := token.InContext(c)
 
 	if vaultIsSealed() {
@@ -223,7 +522,7 @@ func vaultKeyDecryptHandler(c *gin.Context) {
 		return
 	}
 
-	params := &KeyEncryptDecryptRequestResponse{}
+	params := &KeyReencryptRequestResponse{}
 	err = json.Unmarshal(buf, &params)
 	if err != nil {
 		provide.RenderError(err.Error(), 400, c)
@@ -231,15 +530,20 @@ func vaultKeyDecryptHandler(c *gin.Context) {
 	}
 
 	if params.Data == nil {
-		provide.RenderError("requires data to be decrypted", 422, c)
+		provide.RenderError("requires data to be re-encrypted", 422, c)
 		return
 	}
 
-	dataToDecrypt, err := hex.DecodeString(*params.Data)
-	if err != nil {
-		provide.RenderError("error decoding encrypted string to binary", 422, c)
+	if params.DestinationKeyID == nil {
+		provide.RenderError("requires destination key id", 422, c)
 		return
 	}
+
+	destinationVaultID := c.Param("id")
+	if params.DestinationVaultID != nil {
+		destinationVaultID = *params.DestinationVaultID
+	}
+
 	var key = &Key{}
 	key = GetVaultKey(c.Param("keyId"), c.Param("id"), bearer.ApplicationID, bearer.OrganizationID, bearer.UserID)
 
@@ -248,19 +552,98 @@ func vaultKeyDecryptHandler(c *gin.Context) {
 		return
 	}
 
-	decryptedData, err := key.Decrypt(dataToDecrypt)
+	var destination = &Key{}
+	destination = GetVaultKey(*params.DestinationKeyID, destinationVaultID, bearer.ApplicationID, bearer.OrganizationID, bearer.UserID)
+
+	if destination.ID == uuid.Nil {
+		provide.RenderError("destination key not found", 404, c)
+		return
+	}
+
+	ciphertext, version, err := key.parseCiphertext(*params.Data, params.Version)
 	if err != nil {
-		provide.RenderError(err.Error(), 500, c)
+		provide.RenderError(err.Error(), 422, c)
 		return
 	}
 
-	decryptedDataString := string(decryptedData[:])
+	err = key.requireCiphertextVersion(version)
+	if err != nil {
+		provide.RenderError(err.Error(), 422, c)
+		return
+	}
+
+	reencrypted, err := key.Reencrypt(
+		dbconf.DatabaseConnection(),
+		ciphertext,
+		additionalData(params.Context),
+		version,
+		destination,
+		additionalData(params.DestinationContext),
+	)
+	if err != nil {
+		provide.RenderError(err.Error(), 500, c)
+		return
+	}
 
 	provide.Render(&KeyEncryptDecryptRequestResponse{
-		Data: common.StringOrNil(decryptedDataString),
+		Data:    common.StringOrNil(destination.ciphertextEnvelope(reencrypted).String()),
+		Version: &destination.Version,
 	}, 200, c)
 }
 
+// vaultKeyGenerateDataKeyHandler generates a data key and returns it in plaintext and wrapped by the key
+func vaultKeyGenerateDataKeyHandler(c *gin.Context) {
+	generateDataKey(c, true)
+}
+
+// vaultKeyGenerateDataKeyWithoutPlaintextHandler generates a data key and returns it wrapped by the key
+func vaultKeyGenerateDataKeyWithoutPlaintextHandler(c *gin.Context) {
+	generateDataKey(c, false)
+}
+
+func generateDataKey(c *gin.Context, withPlaintext bool) {
+	bearer := token.InContext(c)
+
+	if vaultIsSealed() {
+		provide.RenderError("vault is sealed", 403, c)
+		return
+	}
+
+	var key = &Key{}
+	key = GetVaultKey(c.Param("keyId"), c.Param("id"), bearer.ApplicationID, bearer.OrganizationID, bearer.UserID)
+
+	if key.ID == uuid.Nil {
+		provide.RenderError("key not found", 404, c)
+		return
+	}
+
+	resp := &KeyGenerateDataKeyResponse{
+		Version: &key.Version,
+	}
+
+	if withPlaintext {
+		dataKey, wrappedDataKey, err := key.GenerateDataKey()
+		if err != nil {
+			provide.RenderError(err.Error(), 422, c)
+			return
+		}
+
+		resp.Plaintext = common.StringOrNil(hex.EncodeToString(dataKey))
+		resp.Ciphertext = common.StringOrNil(key.ciphertextEnvelope(wrappedDataKey).String())
+		crypto.WipeBytes(dataKey)
+	} else {
+		wrappedDataKey, err := key.GenerateDataKeyWithoutPlaintext()
+		if err != nil {
+			provide.RenderError(err.Error(), 422, c)
+			return
+		}
+
+		resp.Ciphertext = common.StringOrNil(key.ciphertextEnvelope(wrappedDataKey).String())
+	}
+
+	provide.Render(resp, 201, c)
+}
+
 func vaultsListHandler(c *gin.Context) {
 	bearer := token.InContext(c)
 
@@ -406,6 +789,46 @@ func deleteVaultHandler(c *gin.Context) {
 	provide.Render(nil, 204, c)
 }
 
+// rotateVaultMasterKeyHandler creates a new master key version for the vault; key material
+// wrapped by previous versions is re-wrapped in the background
+func rotateVaultMasterKeyHandler(c *gin.Context) {
+	bearer := token.InContext(c)
+
+	if vaultIsSealed() {
+		provide.RenderError("vault is sealed", 403, c)
+		return
+	}
+
+	if bearer == nil {
+		provide.RenderError("unauthorized", 401, c)
+		return
+	}
+
+	userID := bearer.UserID
+	appID := bearer.ApplicationID
+	orgID := bearer.OrganizationID
+	if (userID == nil || *userID == uuid.Nil) && (appID == nil || *appID == uuid.Nil) && (orgID == nil || *orgID == uuid.Nil) {
+		provide.RenderError("unauthorized", 401, c)
+		return
+	}
+
+	db := dbconf.DatabaseConnection()
+
+	vault := GetVault(db, c.Param("id"), appID, orgID, userID)
+	if vault == nil || vault.ID == uuid.Nil {
+		provide.RenderError("vault not found", 404, c)
+		return
+	}
+
+	masterKeyVersion, err := vault.RotateMasterKey(db)
+	if err != nil {
+		provide.RenderError(err.Error(), 500, c)
+		return
+	}
+
+	provide.Render(masterKeyVersion, 202, c)
+}
+
 func vaultKeysListHandler(c *gin.Context) {
 	bearer := token.InContext(c)
 
@@ -450,8 +873,132 @@ func vaultKeysListHandler(c *gin.Context) {
 	provide.Render(keys, 200, c)
 }
 
-// Creates a key and stores it in vault
-func createVaultKeyHandler(c *gin.Context) {
+// Creates a key and stores it in vault
+func createVaultKeyHandler(c *gin.Context) {
+	bearer := token.InContext(c)
+
+	if vaultIsSealed() {
+		provide.RenderError("vault is sealed", 403, c)
+		return
+	}
+
+	buf, err := c.GetRawData()
+	if err != nil {
+		provide.RenderError(err.Error(), 400, c)
+		return
+	}
+
+	key := &Key{}
+	err = json.Unmarshal(buf, &key)
+	if err != nil {
+		provide.RenderError(err.Error(), 400, c)
+		return
+	}
+
+	if key.VaultID != nil {
+		provide.RenderError("vault_id cannot be set explicitly", 422, c)
+		return
+	}
+
+	if key.PublicKey != nil {
+		provide.RenderError("public_key should not be provided; key material must be imported using an import token", 422, c)
+		return
+	}
+
+	// ensure the key spec is valid and correct the case
+	keySpec, err := ValidateKeySpec(key.Spec)
+	if err != nil {
+		provide.RenderError(err.Error(), 422, c)
+		return
+	}
+	if err == nil {
+		key.Spec = keySpec
+	}
+
+	db := dbconf.DatabaseConnection()
+	vault := &Vault{}
+	vault = GetVault(db, c.Param("id"), bearer.ApplicationID, bearer.OrganizationID, bearer.UserID)
+
+	if vault == nil || vault.ID == uuid.Nil {
+		provide.RenderError("vault not found", 404, c)
+		return
+	}
+
+	key.VaultID = &vault.ID
+	key.vault = vault
+
+	if key.createPersisted(db) {
+		provide.Render(key, 201, c)
+	} else {
+		obj := map[string]interface{}{}
+		obj["errors"] = key.Errors
+		provide.Render(obj, 422, c)
+	}
+}
+
+// vaultKeyImportParamsHandler issues an ephemeral wrapping key and import token to import key material into the vault
+func vaultKeyImportParamsHandler(c *gin.Context) {
+	bearer := token.InContext(c)
+
+	if vaultIsSealed() {
+		provide.RenderError("vault is sealed", 403, c)
+		return
+	}
+
+	buf, err := c.GetRawData()
+	if err != nil {
+		provide.RenderError(err.Error(), 400, c)
+		return
+	}
+
+	params := &KeyImportParamsRequestResponse{}
+	err = json.Unmarshal(buf, &params)
+	if err != nil {
+		provide.RenderError(err.Error(), 400, c)
+		return
+	}
+
+	if params.Spec == nil {
+		provide.RenderError("requires spec of the key material to be imported", 422, c)
+		return
+	}
+
+	keySpec, err := ValidateKeySpec(params.Spec)
+	if err != nil {
+		provide.RenderError(err.Error(), 422, c)
+		return
+	}
+
+	wrappingAlgorithm := crypto.KeyWrapAlgorithmRSAOAEPSHA256
+	if params.WrappingAlgorithm != nil {
+		wrappingAlgorithm = strings.ToUpper(*params.WrappingAlgorithm)
+	}
+
+	db := dbconf.DatabaseConnection()
+	vault := GetVault(db, c.Param("id"), bearer.ApplicationID, bearer.OrganizationID, bearer.UserID)
+
+	if vault == nil || vault.ID == uuid.Nil {
+		provide.RenderError("vault not found", 404, c)
+		return
+	}
+
+	keyImport, err := CreateKeyImport(db, vault, *keySpec, wrappingAlgorithm)
+	if err != nil {
+		provide.RenderError(err.Error(), 422, c)
+		return
+	}
+
+	resp, err := keyImport.ParamsResponse()
+	if err != nil {
+		provide.RenderError(err.Error(), 500, c)
+		return
+	}
+
+	provide.Render(resp, 201, c)
+}
+
+// vaultKeyImportHandler creates a key in the vault using key material wrapped by the wrapping key of an import token
+func vaultKeyImportHandler(c *gin.Context) {
 	bearer := token.InContext(c)
 
 	if vaultIsSealed() {
@@ -465,52 +1012,57 @@ func createVaultKeyHandler(c *gin.Context) {
 		return
 	}
 
-	key := &Key{}
-	err = json.Unmarshal(buf, &key)
+	params := &KeyImportRequest{}
+	err = json.Unmarshal(buf, &params)
 	if err != nil {
 		provide.RenderError(err.Error(), 400, c)
 		return
 	}
 
-	if key.VaultID != nil {
-		provide.RenderError("vault_id cannot be set explicitly", 422, c)
+	if params.ImportToken == nil {
+		provide.RenderError("requires import_token", 422, c)
 		return
 	}
 
-	if key.PublicKey != nil {
-		provide.RenderError("importing key material is not currently supported; public_key should not be provided", 422, c)
+	if params.WrappedKeyMaterial == nil {
+		provide.RenderError("requires wrapped_key_material", 422, c)
 		return
 	}
 
-	// ensure the key spec is valid and correct the case
-	keySpec, err := ValidateKeySpec(key.Spec)
+	wrappedKeyMaterial, err := base64.StdEncoding.DecodeString(*params.WrappedKeyMaterial)
 	if err != nil {
-		provide.RenderError(err.Error(), 422, c)
+		provide.RenderError(fmt.Sprintf("wrapped_key_material must be base64-encoded; %s", err.Error()), 422, c)
 		return
 	}
-	if err == nil {
-		key.Spec = keySpec
-	}
 
 	db := dbconf.DatabaseConnection()
-	vault := &Vault{}
-	vault = GetVault(db, c.Param("id"), bearer.ApplicationID, bearer.OrganizationID, bearer.UserID)
+	vault := GetVault(db, c.Param("id"), bearer.ApplicationID, bearer.OrganizationID, bearer.UserID)
 
 	if vault == nil || vault.ID == uuid.Nil {
 		provide.RenderError("vault not found", 404, c)
 		return
 	}
 
-	key.VaultID = &vault.ID
-	key.vault = vault
+	keyImport := GetKeyImport(db, *params.ImportToken, vault.ID)
+	if keyImport == nil || keyImport.ID == uuid.Nil {
+		provide.RenderError("import token not found", 404, c)
+		return
+	}
 
-	if key.createPersisted(db) {
-		provide.Render(key, 201, c)
-	} else {
-		obj := map[string]interface{}{}
-		obj["errors"] = key.Errors
-		provide.Render(obj, 422, c)
+	key := &Key{
+		Name:        params.Name,
+		Description: params.Description,
+		Usage:       params.Usage,
+		vault:       vault,
+	}
+
+	err = keyImport.Import(db, key, wrappedKeyMaterial)
+	if err != nil {
+		provide.RenderError(err.Error(), 422, c)
+		return
 	}
+
+	provide.Render(key, 201, c)
 }
 
 func deleteVaultKeyHandler(c *gin.Context) {
@@ -538,6 +1090,64 @@ func deleteVaultKeyHandler(c *gin.Context) {
 	provide.Render(nil, 204, c)
 }
 
+// rotateVaultKeyHandler generates a new version of the key material under the same key id
+func rotateVaultKeyHandler(c *gin.Context) {
+	bearer := token.InContext(c)
+
+	if vaultIsSealed() {
+		provide.RenderError("vault is sealed", 403, c)
+		return
+	}
+
+	var key = &Key{}
+	key = GetVaultKey(c.Param("keyId"), c.Param("id"), bearer.ApplicationID, bearer.OrganizationID, bearer.UserID)
+
+	if key.ID == uuid.Nil {
+		provide.RenderError("key not found", 404, c)
+		return
+	}
+
+	err := key.Rotate(dbconf.DatabaseConnection())
+	if err != nil {
+		provide.RenderError(err.Error(), 422, c)
+		return
+	}
+
+	provide.Render(key, 200, c)
+}
+
+// destroyVaultKeyVersionHandler destroys the key material of a previous version of the key
+func destroyVaultKeyVersionHandler(c *gin.Context) {
+	bearer := token.InContext(c)
+
+	if vaultIsSealed() {
+		provide.RenderError("vault is sealed", 403, c)
+		return
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		provide.RenderError("invalid key version", 400, c)
+		return
+	}
+
+	var key = &Key{}
+	key = GetVaultKey(c.Param("keyId"), c.Param("id"), bearer.ApplicationID, bearer.OrganizationID, bearer.UserID)
+
+	if key.ID == uuid.Nil {
+		provide.RenderError("key not found", 404, c)
+		return
+	}
+
+	err = key.DestroyVersion(dbconf.DatabaseConnection(), version)
+	if err != nil {
+		provide.RenderError(err.Error(), 422, c)
+		return
+	}
+
+	provide.Render(nil, 204, c)
+}
+
 // vaultKeyDetailsHandler fetches details for a specific key
 func vaultKeyDetailsHandler(c *gin.Context) {
 	bearer := token.InContext(c)
@@ -615,7 +1225,7 @@ func vaultKeyDeriveHandler(c *gin.Context) {
 	var derivedKey *Key
 
 	switch *key.Spec {
-	case KeySpecChaCha20:
+	case KeySpecChaCha20, KeySpecXChaCha20Poly1305:
 		// handle empty nonces - replace with random 32-bit integer
 		// and convert to bigendian 16-byte array
 		nonceAsBytes := make([]byte, 16)
@@ -715,20 +1325,116 @@ func vaultKeySignHandler(c *gin.Context) {
 		return
 	}
 
-	msg, err := hex.DecodeString(*params.Message)
+	resp, status, err := signRequest(key, params)
 	if err != nil {
-		msg := fmt.Sprintf("failed to decode message from hex; %s", err.Error())
-		common.Log.Warningf(msg)
-		provide.RenderError(msg, 422, c)
+		provide.RenderError(err.Error(), status, c)
 		return
 	}
 
-	signature, err := key.Sign(msg, params.Options)
+	provide.Render(resp, 201, c)
+}
+
+func vaultKeyVerifyHandler(c *gin.Context) {
+	bearer := token.InContext(c)
+
+	if vaultIsSealed() {
+		provide.RenderError("vault is sealed", 403, c)
+		return
+	}
+
+	buf, err := c.GetRawData()
+	if err != nil {
+		provide.RenderError(err.Error(), 400, c)
+		return
+	}
+
+	params := &KeySignVerifyRequestResponse{}
+	err = json.Unmarshal(buf, &params)
+	if err != nil {
+		provide.RenderError(err.Error(), 400, c)
+		return
+	}
+
+	if params.Signature == nil || params.Message == nil || params.Verified != nil {
+		provide.RenderError("only the message and signature to be verified should be provided", 422, c)
+		return
+	}
+
+	var key = &Key{}
+	key = GetVaultKey(c.Param("keyId"), c.Param("id"), bearer.ApplicationID, bearer.OrganizationID, bearer.UserID)
+
+	if key.ID == uuid.Nil {
+		provide.RenderError("key not found", 404, c)
+		return
+	}
+
+	resp, status, err := verifyRequest(dbconf.DatabaseConnection(), key, params)
+	if err != nil {
+		provide.RenderError(err.Error(), status, c)
+		return
+	}
+
+	provide.Render(resp, 200, c)
+}
+
+// vaultKeySignBatchHandler signs each message of the batch using the key, which is unsealed once for the batch
+func vaultKeySignBatchHandler(c *gin.Context) {
+	params := &KeySignVerifyBatchRequestResponse{}
+	key := resolveBatchKey(c, params, func() int { return len(params.Items) })
+	if key == nil {
+		return
+	}
+
+	results, err := key.SignBatch(params.Items)
+	if err != nil {
+		provide.RenderError(err.Error(), 500, c)
+		return
+	}
+
+	provide.Render(&KeySignVerifyBatchRequestResponse{
+		Results: results,
+	}, 201, c)
+}
+
+// vaultKeyVerifyBatchHandler verifies each signature of the batch using the key
+func vaultKeyVerifyBatchHandler(c *gin.Context) {
+	params := &KeySignVerifyBatchRequestResponse{}
+	key := resolveBatchKey(c, params, func() int { return len(params.Items) })
+	if key == nil {
+		return
+	}
+
+	results, err := key.VerifyBatch(dbconf.DatabaseConnection(), params.Items)
 	if err != nil {
 		provide.RenderError(err.Error(), 500, c)
 		return
 	}
 
+	provide.Render(&KeySignVerifyBatchRequestResponse{
+		Results: results,
+	}, 200, c)
+}
+
+// signRequest signs the message of the given request using the key; returns the response,
+// or the http status and error which describe why the message could not be signed
+func signRequest(key *Key, params *KeySignVerifyRequestResponse) (*KeySignVerifyRequestResponse, int, error) {
+	encoding, err := ValidateDataEncoding(params.Encoding, DataEncodingHex)
+	if err != nil {
+		return nil, 422, err
+	}
+
+	msg, err := DecodeData(*params.Message, encoding)
+	if err != nil {
+		err = fmt.Errorf("failed to decode message; %s", err.Error())
+		common.Log.Warningf(err.Error())
+		return nil, 422, err
+	}
+
+	signature, err := key.Sign(msg, params.Options)
+	if err != nil {
+		return nil, 500, err
+	}
+
 	sighex := make([]byte, hex.EncodedLen(len(signature)))
 	hex.Encode(sighex, signature)
 
@@ -742,14 +1448,51 @@ func vaultKeySignHandler(c *gin.Context) {
 		path = *key.DerivationPath
 	}
 
-	provide.Render(&KeySignVerifyRequestResponse{
+	return &KeySignVerifyRequestResponse{
 		Signature:      common.StringOrNil(string(sighex)),
 		Address:        common.StringOrNil(address),
 		DerivationPath: common.StringOrNil(path),
-	}, 201, c)
+		Version:        &key.Version,
+	}, 201, nil
 }
 
-func vaultKeyVerifyHandler(c *gin.Context) {
+// verifyRequest verifies the signature of the given request using the key; returns the response,
+// or the http status and error which describe why the signature could not be verified
+func verifyRequest(db *gorm.DB, key *Key, params *KeySignVerifyRequestResponse) (*KeySignVerifyRequestResponse, int, error) {
+	encoding, err := ValidateDataEncoding(params.Encoding, DataEncodingHex)
+	if err != nil {
+		return nil, 422, err
+	}
+
+	msg, err := DecodeData(*params.Message, encoding)
+	if err != nil {
+		err = fmt.Errorf("failed to decode message; %s", err.Error())
+		common.Log.Warningf(err.Error())
+		return nil, 422, err
+	}
+
+	sig, err := hex.DecodeString(*params.Signature)
+	if err != nil {
+		err = fmt.Errorf("failed to decode signature from hex; %s", err.Error())
+		common.Log.Warningf(err.Error())
+		return nil, 422, err
+	}
+
+	version, err := key.VerifyVersioned(db, msg, sig, params.Options, params.Version)
+	verified := err == nil
+
+	resp := &KeySignVerifyRequestResponse{
+		Verified: &verified,
+	}
+	if verified {
+		resp.Version = &version
+	}
+
+	return resp, 200, nil
+}
+
+// vaultKeyMACHandler computes the HMAC of the given message using the key
+func vaultKeyMACHandler(c *gin.Context) {
 	bearer := token.InContext(c)
 
 	if vaultIsSealed() {
@@ -763,15 +1506,15 @@ func vaultKeyVerifyHandler(c *gin.Context) {
 		return
 	}
 
-	params := &KeySignVerifyRequestResponse{}
+	params := &KeyMACRequestResponse{}
 	err = json.Unmarshal(buf, &params)
 	if err != nil {
 		provide.RenderError(err.Error(), 400, c)
 		return
 	}
 
-	if params.Signature == nil || params.Message == nil || params.Verified != nil {
-		provide.RenderError("only the message and signature to be verified should be provided", 422, c)
+	if params.Message == nil || params.MAC != nil || params.Verified != nil {
+		provide.RenderError("only the message to be authenticated should be provided", 422, c)
 		return
 	}
 
@@ -783,28 +1526,126 @@ func vaultKeyVerifyHandler(c *gin.Context) {
 		return
 	}
 
-	msg, err := hex.DecodeString(*params.Message)
+	resp, status, err := macRequest(key, params)
 	if err != nil {
-		msg := fmt.Sprintf("failed to decode message from hex; %s", err.Error())
-		common.Log.Warningf(msg)
-		provide.RenderError(msg, 422, c)
+		provide.RenderError(err.Error(), status, c)
 		return
 	}
 
-	sig, err := hex.DecodeString(*params.Signature)
+	provide.Render(resp, 201, c)
+}
+
+// vaultKeyVerifyMACHandler verifies the HMAC of the given message using the key
+func vaultKeyVerifyMACHandler(c *gin.Context) {
+	bearer := token.InContext(c)
+
+	if vaultIsSealed() {
+		provide.RenderError("vault is sealed", 403, c)
+		return
+	}
+
+	buf, err := c.GetRawData()
 	if err != nil {
-		msg := fmt.Sprintf("failed to decode signature from hex; %s", err.Error())
-		common.Log.Warningf(msg)
-		provide.RenderError(msg, 422, c)
+		provide.RenderError(err.Error(), 400, c)
+		return
+	}
+
+	params := &KeyMACRequestResponse{}
+	err = json.Unmarshal(buf, &params)
+	if err != nil {
+		provide.RenderError(err.Error(), 400, c)
+		return
+	}
+
+	if params.Message == nil || params.MAC == nil || params.Verified != nil {
+		provide.RenderError("only the message and mac to be verified should be provided", 422, c)
+		return
+	}
+
+	var key = &Key{}
+	key = GetVaultKey(c.Param("keyId"), c.Param("id"), bearer.ApplicationID, bearer.OrganizationID, bearer.UserID)
+
+	if key.ID == uuid.Nil {
+		provide.RenderError("key not found", 404, c)
+		return
+	}
+
+	resp, status, err := verifyMACRequest(dbconf.DatabaseConnection(), key, params)
+	if err != nil {
+		provide.RenderError(err.Error(), status, c)
 		return
 	}
 
-	err = key.Verify(msg, sig, params.Options)
+	provide.Render(resp, 200, c)
+}
+
+// macRequest computes the HMAC of the message of the given request using the key; returns the
+// response, or the http status and error which describe why the mac could not be computed
+func macRequest(key *Key, params *KeyMACRequestResponse) (*KeyMACRequestResponse, int, error) {
+	if !key.isHMAC() {
+		return nil, 422, fmt.Errorf("mac not supported by key spec: %s", *key.Spec)
+	}
+
+	encoding, err := ValidateDataEncoding(params.Encoding, DataEncodingHex)
+	if err != nil {
+		return nil, 422, err
+	}
+
+	msg, err := DecodeData(*params.Message, encoding)
+	if err != nil {
+		err = fmt.Errorf("failed to decode message; %s", err.Error())
+		common.Log.Warningf(err.Error())
+		return nil, 422, err
+	}
+
+	mac, err := key.MAC(msg)
+	if err != nil {
+		return nil, 500, err
+	}
+
+	return &KeyMACRequestResponse{
+		MAC:     common.StringOrNil(hex.EncodeToString(mac)),
+		Version: &key.Version,
+	}, 201, nil
+}
+
+// verifyMACRequest verifies the HMAC of the given request using the key; returns the response,
+// or the http status and error which describe why the mac could not be verified
+func verifyMACRequest(db *gorm.DB, key *Key, params *KeyMACRequestResponse) (*KeyMACRequestResponse, int, error) {
+	if !key.isHMAC() {
+		return nil, 422, fmt.Errorf("mac not supported by key spec: %s", *key.Spec)
+	}
+
+	encoding, err := ValidateDataEncoding(params.Encoding, DataEncodingHex)
+	if err != nil {
+		return nil, 422, err
+	}
+
+	msg, err := DecodeData(*params.Message, encoding)
+	if err != nil {
+		err = fmt.Errorf("failed to decode message; %s", err.Error())
+		common.Log.Warningf(err.Error())
+		return nil, 422, err
+	}
+
+	mac, err := hex.DecodeString(*params.MAC)
+	if err != nil {
+		err = fmt.Errorf("failed to decode mac from hex; %s", err.Error())
+		common.Log.Warningf(err.Error())
+		return nil, 422, err
+	}
+
+	version, err := key.VerifyMACVersioned(db, msg, mac, params.Version)
 	verified := err == nil
 
-	provide.Render(&KeySignVerifyRequestResponse{
+	resp := &KeyMACRequestResponse{
 		Verified: &verified,
-	}, 200, c)
+	}
+	if verified {
+		resp.Version = &version
+	}
+
+	return resp, 200, nil
 }
 
 func vaultSecretsListHandler(c *gin.Context) {
@@ -1088,7 +1929,20 @@ func verifyDetachedVerifyHandler(c *gin.Context) {
 				provide.RenderError("failed to decode RSA public key", 422, c)
 				return
 			}
-			publicKey, _ = json.Marshal(*rsaPublicKey)
+			publicKey, err = x509.MarshalPKIXPublicKey(rsaPublicKey)
+			if err != nil {
+				provide.RenderError("failed to encode RSA public key", 422, c)
+				return
+			}
+		}
+
+		if curve := ecdsaCurve(*params.Spec); curve != nil {
+			ecdsaPublicKey, err := crypto.ECDSAPublicKeyFromPEM(curve, []byte(*params.PublicKey))
+			if err != nil {
+				provide.RenderError(fmt.Sprintf("failed to decode %s public key", *params.Spec), 422, c)
+				return
+			}
+			publicKey = ecdsaPublicKey
 		}
 	}
 
@@ -0,0 +1,97 @@
+package vault
+
+import (
+	"context"
+	"time"
+
+	dbconf "github.com/kthomas/go-db-config"
+	"github.com/kthomas/go-redisutil"
+	"github.com/provideplatform/vault/common"
+)
+
+// HealthStatusOK is reported when a health check succeeded
+const HealthStatusOK = "ok"
+
+// HealthStatusUnavailable is reported when a health check failed
+const HealthStatusUnavailable = "unavailable"
+
+// HealthStatusNotConfigured is reported when the checked dependency is not configured
+const HealthStatusNotConfigured = "not_configured"
+
+// healthCheckTimeout is the maximum duration of each dependency health check
+const healthCheckTimeout = time.Second * 2
+
+// HealthStatus reports the seal state, seal/unseal provider, dependency
+// connectivity and build of this vault instance
+type HealthStatus struct {
+	Ready       bool              `json:"ready"`
+	InstanceID  string            `json:"instance_id"`
+	Sealed      bool              `json:"sealed"`
+	Provider    string            `json:"provider"`
+	Checks      map[string]string `json:"checks"`
+	Version     string            `json:"version"`
+	BuildCommit string            `json:"build_commit,omitempty"`
+	BuildDate   string            `json:"build_date,omitempty"`
+	Timestamp   time.Time         `json:"timestamp"`
+}
+
+// GetHealthStatus checks database and redis connectivity and returns the health of
+// this vault instance; the instance is ready only when unsealed and both are reachable
+func GetHealthStatus() *HealthStatus {
+	status := &HealthStatus{
+		InstanceID:  instanceID,
+		Sealed:      vaultIsSealed(),
+		Provider:    providerName,
+		Checks:      map[string]string{},
+		Version:     common.Version,
+		BuildCommit: common.BuildCommit,
+		BuildDate:   common.BuildDate,
+		Timestamp:   time.Now(),
+	}
+
+	status.Checks["database"] = checkDatabase()
+	status.Checks["redis"] = checkRedis()
+
+	status.Ready = !status.Sealed &&
+		status.Checks["database"] == HealthStatusOK &&
+		status.Checks["redis"] != HealthStatusUnavailable
+
+	return status
+}
+
+func checkDatabase() string {
+	db := dbconf.DatabaseConnection()
+	if db == nil || db.DB() == nil {
+		return HealthStatusUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+
+	err := db.DB().PingContext(ctx)
+	if err != nil {
+		common.Log.Warningf("database health check failed; %s", err.Error())
+		return HealthStatusUnavailable
+	}
+
+	return HealthStatusOK
+}
+
+func checkRedis() string {
+	var err error
+
+	if redisutil.RedisClusterClient != nil {
+		err = redisutil.RedisClusterClient.Ping().Err()
+	} else if redisutil.RedisClient != nil {
+		err = redisutil.RedisClient.Ping().Err()
+	} else {
+		return HealthStatusNotConfigured
+	}
+
+	if err != nil {
+		common.Log.Warningf("redis health check failed; %s", err.Error())
+		return HealthStatusUnavailable
+	}
+
+	return HealthStatusOK
+}
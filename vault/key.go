@@ -4,10 +4,8 @@ import (
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
-	"crypto/rsa"
 	"crypto/x509"
 	"encoding/hex"
-	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
@@ -29,8 +27,6 @@ import (
 	"golang.org/x/crypto/chacha20"
 )
 
-const defaultVaultMasterKeyName = "master0"
-
 const maxHDIteration = 4294967295
 
 // KeyTypeAsymmetric asymmetric key type
@@ -45,12 +41,32 @@ const KeyUsageEncryptDecrypt = "encrypt/decrypt"
 // KeyUsageSignVerify sign/verify usage
 const KeyUsageSignVerify = "sign/verify"
 
+// KeyUsageMACVerify mac/verify usage
+const KeyUsageMACVerify = "mac/verify"
+
 // KeySpecAES256GCM AES-256-GCM key spec
 const KeySpecAES256GCM = "AES-256-GCM"
 
-// KeySpecChaCha20 ChaCha20 key spec
+// KeySpecChaCha20 ChaCha20 key spec; unauthenticated, superseded by XChaCha20-Poly1305;
+// data encrypted under ChaCha20 keys can be migrated to XChaCha20-Poly1305 keys using reencrypt
 const KeySpecChaCha20 = "ChaCha20"
 
+// KeySpecXChaCha20Poly1305 XChaCha20-Poly1305 key spec
+const KeySpecXChaCha20Poly1305 = "XChaCha20-Poly1305"
+
+// KeySpecAES256SIV AES-256-SIV (RFC 5297) key spec; misuse-resistant, and supports
+// deterministic encryption when the key is created with deterministic enabled
+const KeySpecAES256SIV = "AES-256-SIV"
+
+// KeySpecHMACSHA256 HMAC-SHA256 key spec
+const KeySpecHMACSHA256 = "HMAC-SHA256"
+
+// KeySpecHMACSHA384 HMAC-SHA384 key spec
+const KeySpecHMACSHA384 = "HMAC-SHA384"
+
+// KeySpecHMACSHA512 HMAC-SHA512 key spec
+const KeySpecHMACSHA512 = "HMAC-SHA512"
+
 // KeySpecECCBabyJubJub babyJubJub key spec
 const KeySpecECCBabyJubJub = "babyJubJub"
 
@@ -75,9 +91,15 @@ const KeySpecBLS12381 = "BLS12-381"
 // NonceSizeSymmetric chacha20 & aes256 encrypt/decrypt nonce size
 const NonceSizeSymmetric = 12
 
-// const KeySpecECCSecp256r1 = "ECC-NIST-P256"
-// const KeySpecECCSecp2048 = "ECC-NIST-P384"
-// const KeySpecECCSecp521r1 = "ECC-NIST-P521"
+// KeySpecECCNISTP256 NIST P-256 (secp256r1) ECDSA key spec; signs using SHA-256, i.e., ES256
+const KeySpecECCNISTP256 = "ECC-NIST-P256"
+
+// KeySpecECCNISTP384 NIST P-384 (secp384r1) ECDSA key spec; signs using SHA-384, i.e., ES384
+const KeySpecECCNISTP384 = "ECC-NIST-P384"
+
+// KeySpecECCNISTP521 NIST P-521 (secp521r1) ECDSA key spec; signs using SHA-512, i.e., ES512
+const KeySpecECCNISTP521 = "ECC-NIST-P521"
+
 // const KeySpecECCSecpP256k1 = "ECC-SECG-P256K1"
 
 // KeySpecRSA2048 rsa 2048 key spec
@@ -111,6 +133,9 @@ type Key struct {
 	PublicKey               *[]byte    `sql:"type:bytea" json:"-"`
 	PrivateKey              *[]byte    `sql:"type:bytea" json:"-"`
 	IterativeDerivationPath *string    `gorm:"column:iterative_hd_derivation_path" json:"-"`
+	MasterKeyID             *uuid.UUID `sql:"type:uuid" json:"-"`                                    // master key version which wraps the key material
+	Version                 int        `sql:"not null;default:0" json:"version"`                     // latest version of the key material
+	Deterministic           *bool      `sql:"not null;default:false" json:"deterministic,omitempty"` // deterministic encryption enabled; AES-256-SIV only
 	Mnemonic                *string    `sql:"-" json:"mnemonic,omitempty"`
 
 	Address             *string `sql:"-" json:"address,omitempty"`
@@ -122,19 +147,46 @@ type Key struct {
 	// HardenedDerivationPath      *string `json:"hardened_hd_derivation_path,omitempty"` <-- may be useful to store this, i.e., m/44'/60'/0'
 
 	encrypted *bool      `sql:"-"`
+	isMaster  bool       `sql:"-"` // true when resolved as a vault master key version
+	held      bool       `sql:"-"` // true while the fields are held decrypted for a batch operation
 	mutex     sync.Mutex `sql:"-"`
 	vault     *Vault     `sql:"-"` // vault cache
 }
 
 // KeyEncryptDecryptRequestResponse contains the data to be encrypted/decrypted
-// data is submitted and received as a string
-// encrypted data is returned hex encoded
-// decrypted data is returned as received
+// data is submitted and received as a string, encoded using the optional encoding (utf8, hex,
+// base64 or base64url; defaults to utf8); binary data must use one of the binary-safe encodings
+// encrypted data is returned as a ciphertext envelope; raw hex-encoded ciphertext is accepted on decrypt
+// decrypted data is returned using the given encoding
 // nonce is optional and a random nonce will be created if not present
 // note that nonces must not be reused and using 2^32 random nonces is not secure
+// version identifies the key version which encrypted the data; when omitted on decrypt,
+// the latest version and each previous non-destroyed version are attempted
+// context is optional additional authenticated data bound to the ciphertext, which must
+// be provided again on decrypt; only supported by AEAD key specs
+// deterministic requests deterministic encryption, such that identical data and context
+// always produce identical ciphertext, and must be provided again on decrypt; only
+// supported by AES-256-SIV keys created with deterministic enabled
 type KeyEncryptDecryptRequestResponse struct {
-	Data  *string `json:"data,omitempty"`
-	Nonce *string `json:"nonce,omitempty"` //optional nonce parameter
+	Data          *string `json:"data,omitempty"`
+	Nonce         *string `json:"nonce,omitempty"`         //optional nonce parameter
+	Version       *int    `json:"version,omitempty"`       //optional key version parameter
+	Context       *string `json:"context,omitempty"`       //optional additional authenticated data parameter
+	Deterministic *bool   `json:"deterministic,omitempty"` //optional deterministic encryption parameter
+	Encoding      *string `json:"encoding,omitempty"`      //optional data encoding parameter
+}
+
+// KeyReencryptRequestResponse contains the ciphertext to be re-encrypted and the destination
+// key, which may reside in another vault accessible to the caller; the destination vault
+// defaults to the vault of the source key; the re-encrypted data is returned as a
+// ciphertext envelope along with the version of the destination key which produced it
+type KeyReencryptRequestResponse struct {
+	Data               *string `json:"data,omitempty"`
+	Version            *int    `json:"version,omitempty"` //optional source key version parameter
+	Context            *string `json:"context,omitempty"` //optional source additional authenticated data parameter
+	DestinationKeyID   *string `json:"destination_key_id,omitempty"`
+	DestinationVaultID *string `json:"destination_vault_id,omitempty"`
+	DestinationContext *string `json:"destination_context,omitempty"` //optional destination additional authenticated data parameter
 }
 
 // KeyDeriveRequest contains the details for the derivation of a new key,
@@ -149,16 +201,22 @@ type KeyDeriveRequest struct {
 
 // SigningOptions contains the options for the signing algorithm
 // such as rsa algorithm (RS256, RS384, RS512, PS256, PS384, PS512)
+// ecdsa algorithm (ES256, ES384, ES512; optional, must match the curve of the key)
+// ecdsa signature format (der or raw r||s; defaults to der)
 // hd wallet coin type (BTC, ETH)
 // hd wallet derivation path or iteration (deterministic account index)
 // and likely other stuff in the future...
 type SigningOptions struct {
-	Algorithm *string          `json:"algorithm,omitempty"`
-	HDWallet  *crypto.HDWallet `json:"hdwallet,omitempty"`
+	Algorithm       *string          `json:"algorithm,omitempty"`
+	HDWallet        *crypto.HDWallet `json:"hdwallet,omitempty"`
+	SignatureFormat *string          `json:"signature_format,omitempty"`
 }
 
 // KeySignVerifyRequestResponse represents the API request/response parameters
-// needed to sign or verify an arbitrary message
+// needed to sign or verify an arbitrary message; version identifies the key
+// version which created the signature; the message is encoded using the optional
+// encoding (utf8, hex, base64 or base64url; defaults to hex) and the signature is
+// always hex-encoded
 type KeySignVerifyRequestResponse struct {
 	Message        *string         `json:"message,omitempty"`
 	Options        *SigningOptions `json:"options,omitempty"`
@@ -166,6 +224,8 @@ type KeySignVerifyRequestResponse struct {
 	Verified       *bool           `json:"verified,omitempty"`
 	Address        *string         `json:"address,omitempty"`
 	DerivationPath *string         `json:"hd_derivation_path,omitempty"`
+	Version        *int            `json:"version,omitempty"`
+	Encoding       *string         `json:"encoding,omitempty"`
 }
 
 // BLSAggregateRequestResponse aggregates n BLS signatures into one signature
@@ -210,6 +270,63 @@ func (k *Key) createAES256GCM() error {
 	return nil
 }
 
+// createAES256SIV creates a key using a random private key
+func (k *Key) createAES256SIV() error {
+	privatekey, err := crypto.CreateAES256SIVKey()
+	if err != nil {
+		return err
+	}
+
+	k.PrivateKey = &privatekey
+	k.Type = common.StringOrNil(KeyTypeSymmetric)
+	k.Spec = common.StringOrNil(KeySpecAES256SIV)
+
+	common.Log.Debugf("created AES-256-SIV key for vault: %s;", k.VaultID)
+	return nil
+}
+
+// createHMAC creates a HMAC key using a random private key of the digest size of the spec
+func (k *Key) createHMAC(spec string) error {
+	var size int
+	switch spec {
+	case KeySpecHMACSHA256:
+		size = crypto.HMACSHA256KeySize
+	case KeySpecHMACSHA384:
+		size = crypto.HMACSHA384KeySize
+	case KeySpecHMACSHA512:
+		size = crypto.HMACSHA512KeySize
+	default:
+		return fmt.Errorf("invalid HMAC key spec: %s", spec)
+	}
+
+	privatekey, err := crypto.CreateHMACKey(size)
+	if err != nil {
+		return err
+	}
+
+	k.PrivateKey = &privatekey
+	k.Type = common.StringOrNil(KeyTypeSymmetric)
+	k.Spec = common.StringOrNil(spec)
+
+	common.Log.Debugf("created %s key for vault: %s;", spec, k.VaultID)
+	return nil
+}
+
+// createXChaCha20Poly1305 creates a key using a random seed
+func (k *Key) createXChaCha20Poly1305() error {
+	seed, err := crypto.CreateXChaCha20Poly1305Seed()
+	if err != nil {
+		return crypto.ErrCannotGenerateKey
+	}
+
+	k.Seed = &seed
+	k.Type = common.StringOrNil(KeyTypeSymmetric)
+	k.Spec = common.StringOrNil(KeySpecXChaCha20Poly1305)
+
+	common.Log.Debugf("created xchacha20-poly1305 key for vault: %s;", k.VaultID)
+	return nil
+}
+
 // createBabyJubJubKeypair creates a keypair on the twisted edwards babyJubJub curve
 func (k *Key) createBabyJubJubKeypair() error {
 	publicKey, privateKey, err := providecrypto.TECGenerateKeyPair()
@@ -481,7 +598,13 @@ func (k *Key) resolveVault(db *gorm.DB) error {
 	return nil
 }
 
+// resolveMasterKey resolves the master key version which wraps the key material; when the key
+// material has not yet been wrapped, the active master key version of the vault is resolved
 func (k *Key) resolveMasterKey(db *gorm.DB) (*Key, error) {
+	if k.isMaster {
+		return nil, fmt.Errorf("unable to resolve master key: %s; current key is master; vault id: %s", k.ID, k.VaultID)
+	}
+
 	err := k.resolveVault(db)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve vault for master key resolution without vault id for key: %s", k.ID)
@@ -491,11 +614,17 @@ func (k *Key) resolveMasterKey(db *gorm.DB) (*Key, error) {
 		return nil, fmt.Errorf("failed to resolve master key without vault id for key: %s", k.ID)
 	}
 
-	if k.vault.MasterKeyID != nil && k.vault.MasterKeyID.String() == k.ID.String() {
+	if k.MasterKeyID == nil && k.ID != uuid.Nil && k.vault.isMasterKeyVersion(db, k.ID) {
+		k.isMaster = true
 		return nil, fmt.Errorf("unable to resolve master key: %s; current key is master; vault id: %s", k.ID, k.VaultID)
 	}
 
-	masterKey, err := k.vault.resolveMasterKey(db)
+	var masterKey *Key
+	if k.MasterKeyID != nil {
+		masterKey, err = k.vault.resolveMasterKeyVersion(db, *k.MasterKeyID)
+	} else {
+		masterKey, err = k.vault.resolveMasterKey(db)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve master key for key: %s; %s", k.ID, err.Error())
 	}
@@ -511,7 +640,11 @@ func (k *Key) decryptFields() error {
 	k.mutex.Lock()
 	defer k.mutex.Unlock()
 
-	if unsealerKey == nil {
+	if k.held {
+		return nil
+	}
+
+	if vaultIsSealed() {
 		return fmt.Errorf("vault is sealed")
 	}
 
@@ -524,6 +657,11 @@ func (k *Key) decryptFields() error {
 	}
 
 	masterKey, err := k.resolveMasterKey(dbconf.DatabaseConnection())
+	if err != nil && !k.isMaster {
+		// only master keys are wrapped by the unsealer key
+		return fmt.Errorf("failed to decrypt fields for key: %s; %s", k.ID, err.Error())
+	}
+
 	if err != nil {
 		common.Log.Tracef("decrypting master key fields for vault: %s", k.VaultID)
 
@@ -571,11 +709,43 @@ func (k *Key) decryptFields() error {
 	return nil
 }
 
+// holdDecrypted decrypts the fields of the key once for the duration of a batch operation;
+// decryptFields and encryptFields are no-ops until the returned release func is called,
+// which encrypts the fields and wipes the plaintext key material
+func (k *Key) holdDecrypted() (func(), error) {
+	if k.Spec != nil && *k.Spec == KeySpecECCBIP39 {
+		// hd wallets reload and persist the key when advancing the iterative derivation path
+		// upon signing, so the fields are instead decrypted for each operation
+		return func() {}, nil
+	}
+
+	err := k.decryptFields()
+	if err != nil {
+		return nil, err
+	}
+
+	k.mutex.Lock()
+	k.held = true
+	k.mutex.Unlock()
+
+	return func() {
+		k.mutex.Lock()
+		k.held = false
+		k.mutex.Unlock()
+
+		k.encryptFields()
+	}, nil
+}
+
 func (k *Key) encryptFields() error {
 	k.mutex.Lock()
 	defer k.mutex.Unlock()
 
-	if unsealerKey == nil {
+	if k.held {
+		return nil
+	}
+
+	if vaultIsSealed() {
 		return fmt.Errorf("vault is sealed")
 	}
 
@@ -588,6 +758,11 @@ func (k *Key) encryptFields() error {
 	}
 
 	masterKey, err := k.resolveMasterKey(dbconf.DatabaseConnection())
+	if err != nil && !k.isMaster {
+		// only master keys are wrapped by the unsealer key
+		return fmt.Errorf("failed to encrypt fields for key: %s; %s", k.ID, err.Error())
+	}
+
 	if err != nil {
 		common.Log.Tracef("encrypting master key fields for vault: %s", k.VaultID)
 
@@ -597,6 +772,8 @@ func (k *Key) encryptFields() error {
 			if err != nil {
 				return err
 			}
+			// wipe the plaintext seed in memory before garbage collection
+			crypto.WipeBytes(*k.Seed)
 			k.Seed = &seed
 		}
 
@@ -606,6 +783,8 @@ func (k *Key) encryptFields() error {
 			if err != nil {
 				return err
 			}
+			// wipe the plaintext private key in memory before garbage collection
+			crypto.WipeBytes(*k.PrivateKey)
 			k.PrivateKey = &privateKey
 		}
 	} else {
@@ -619,6 +798,8 @@ func (k *Key) encryptFields() error {
 			if err != nil {
 				return err
 			}
+			// wipe the plaintext seed in memory before garbage collection
+			crypto.WipeBytes(*k.Seed)
 			k.Seed = &seed
 		}
 
@@ -627,8 +808,12 @@ func (k *Key) encryptFields() error {
 			if err != nil {
 				return err
 			}
+			// wipe the plaintext private key in memory before garbage collection
+			crypto.WipeBytes(*k.PrivateKey)
 			k.PrivateKey = &privateKey
 		}
+
+		k.MasterKeyID = &masterKey.ID
 	}
 
 	k.setEncrypted(true)
@@ -639,9 +824,12 @@ func (k *Key) encryptFields() error {
 // have a compressed representation (i.e., crypto address)
 func (k *Key) Enrich() {
 	enrichRSA := func() {
-		var rsaPublicKey rsa.PublicKey
-		json.Unmarshal(*k.PublicKey, &rsaPublicKey)
-		publicKeyBytes, _ := x509.MarshalPKIXPublicKey(&rsaPublicKey)
+		rsaPublicKey, err := crypto.ParseRSAPublicKey(*k.PublicKey)
+		if err != nil {
+			common.Log.Warningf("failed to enrich RSA key: %s; %s", k.ID, err.Error())
+			return
+		}
+		publicKeyBytes, _ := x509.MarshalPKIXPublicKey(rsaPublicKey)
 		k.PublicKeyHex = common.StringOrNil(string(pem.EncodeToMemory(&pem.Block{
 			Type:  "PUBLIC KEY",
 			Bytes: publicKeyBytes,
@@ -671,6 +859,8 @@ func (k *Key) Enrich() {
 			enrichRSA()
 		case KeySpecRSA4096:
 			enrichRSA()
+		case KeySpecECCNISTP256, KeySpecECCNISTP384, KeySpecECCNISTP521:
+			k.enrichECDSA()
 		default:
 			k.PublicKeyHex = common.StringOrNil(fmt.Sprintf("0x%s", hex.EncodeToString(*k.PublicKey)))
 		}
@@ -737,6 +927,21 @@ func (k *Key) create() error {
 			if err != nil {
 				return fmt.Errorf("failed to create ChaCha20 key; %s", err.Error())
 			}
+		case KeySpecXChaCha20Poly1305:
+			err := k.createXChaCha20Poly1305()
+			if err != nil {
+				return fmt.Errorf("failed to create XChaCha20-Poly1305 key; %s", err.Error())
+			}
+		case KeySpecAES256SIV:
+			err := k.createAES256SIV()
+			if err != nil {
+				return fmt.Errorf("failed to create AES-256-SIV key; %s", err.Error())
+			}
+		case KeySpecHMACSHA256, KeySpecHMACSHA384, KeySpecHMACSHA512:
+			err := k.createHMAC(*k.Spec)
+			if err != nil {
+				return fmt.Errorf("failed to create %s key; %s", *k.Spec, err.Error())
+			}
 		case KeySpecECCBabyJubJub:
 			err := k.createBabyJubJubKeypair()
 			if err != nil {
@@ -762,6 +967,11 @@ func (k *Key) create() error {
 			if err != nil {
 				return fmt.Errorf("failed to create hd wallet; %s", err.Error())
 			}
+		case KeySpecECCNISTP256, KeySpecECCNISTP384, KeySpecECCNISTP521:
+			err := k.createECDSAKeypair(*k.Spec)
+			if err != nil {
+				return fmt.Errorf("failed to create %s keypair; %s", *k.Spec, err.Error())
+			}
 		case KeySpecECCSecp256k1:
 			err := k.createSecp256k1Keypair()
 			if err != nil {
@@ -930,23 +1140,68 @@ func (k *Key) save(db *gorm.DB) bool {
 
 // Decrypt a ciphertext using the key according to its spec
 func (k *Key) Decrypt(ciphertext []byte) ([]byte, error) {
+	return k.DecryptWithAAD(ciphertext, nil)
+}
+
+// DecryptWithAAD decrypts a ciphertext using the key according to its spec; decryption
+// fails unless the additional authenticated data matches that given on encryption
+func (k *Key) DecryptWithAAD(ciphertext, aad []byte) ([]byte, error) {
 	if k.Type != nil && *k.Type == KeyTypeSymmetric {
-		return k.decryptSymmetric(ciphertext[NonceSizeSymmetric:], ciphertext[0:NonceSizeSymmetric])
+		nonceSize := k.nonceSize()
+		if len(ciphertext) < nonceSize {
+			return nil, fmt.Errorf("failed to decrypt %d-byte ciphertext using key: %s; ciphertext does not contain a %d-byte nonce", len(ciphertext), k.ID, nonceSize)
+		}
+		return k.decryptSymmetric(ciphertext[nonceSize:], ciphertext[0:nonceSize], aad)
 	}
 
 	if k.Type != nil && *k.Type == KeyTypeAsymmetric {
-		return k.decryptAsymmetric(ciphertext)
+		if len(aad) > 0 && !k.isHybrid() {
+			return nil, fmt.Errorf("failed to decrypt %d-byte ciphertext using key: %s; %s", len(ciphertext), k.ID, crypto.ErrUnsupportedAAD.Error())
+		}
+		return k.decryptAsymmetric(ciphertext, aad)
 	}
 
 	return nil, fmt.Errorf("failed to decrypt %d-byte ciphertext using key: %s; nil or invalid key type", len(ciphertext), k.ID)
 }
 
+// isHybrid returns true if the key is an asymmetric key which supports hybrid public key
+// encryption, i.e., C25519 (HPKE) and secp256k1 (ECIES) keys; hybrid ciphertexts may be
+// bound to additional authenticated data
+func (k *Key) isHybrid() bool {
+	return k.Spec != nil && (*k.Spec == KeySpecECCC25519 || *k.Spec == KeySpecECCSecp256k1)
+}
+
+// nonceSize returns the size of the nonce prepended to ciphertexts produced using the key
+func (k *Key) nonceSize() int {
+	if k.Spec == nil {
+		return 0
+	}
+
+	switch *k.Spec {
+	case KeySpecAES256GCM:
+		return NonceSizeSymmetric
+	case KeySpecChaCha20:
+		return NonceSizeSymmetric
+	case KeySpecXChaCha20Poly1305:
+		return crypto.NonceSizeXChaCha20Poly1305
+	case KeySpecAES256SIV:
+		return crypto.NonceSizeAESSIV
+	}
+
+	return 0
+}
+
 // decryptAsymmetric attempts asymmetric decryption using the key;
 // returns the plaintext and any error
-func (k *Key) decryptAsymmetric(ciphertext []byte) ([]byte, error) {
+// aad is the optional additional authenticated data, which is only supported by hybrid specs
+func (k *Key) decryptAsymmetric(ciphertext, aad []byte) ([]byte, error) {
 	// k.mutex.Lock()
 	// defer k.mutex.Unlock()
 
+	if k.Spec == nil {
+		return nil, fmt.Errorf("failed to decrypt using key: %s; nil key spec", k.ID)
+	}
+
 	if k.PrivateKey == nil {
 		return nil, fmt.Errorf("failed to decrypt using key: %s; nil private key", k.ID)
 	}
@@ -963,7 +1218,7 @@ func (k *Key) decryptAsymmetric(ciphertext []byte) ([]byte, error) {
 		rsa4096.PrivateKey = *k.PrivateKey
 		plaintext, err = rsa4096.Decrypt(ciphertext)
 		if err != nil {
-			return nil, crypto.ErrCannotDecrypt
+			return nil, fmt.Errorf("failed to decrypt using key: %s; %s", k.ID, err.Error())
 		}
 
 	case KeySpecRSA3072:
@@ -971,23 +1226,46 @@ func (k *Key) decryptAsymmetric(ciphertext []byte) ([]byte, error) {
 		rsa3072.PrivateKey = *k.PrivateKey
 		plaintext, err = rsa3072.Decrypt(ciphertext)
 		if err != nil {
-			return nil, crypto.ErrCannotDecrypt
+			return nil, fmt.Errorf("failed to decrypt using key: %s; %s", k.ID, err.Error())
 		}
 
 	case KeySpecRSA2048:
 		rsa2048 := crypto.RSAKeyPair{}
 		rsa2048.PrivateKey = *k.PrivateKey
 		plaintext, err = rsa2048.Decrypt(ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt using key: %s; %s", k.ID, err.Error())
+		}
+
+	case KeySpecECCC25519:
+		c25519 := crypto.C25519{}
+		c25519.PrivateKey = *k.PrivateKey
+		if k.PublicKey != nil {
+			c25519.PublicKey = *k.PublicKey
+		}
+		plaintext, err = c25519.Decrypt(ciphertext, nil, aad)
 		if err != nil {
 			return nil, crypto.ErrCannotDecrypt
 		}
+
+	case KeySpecECCSecp256k1:
+		secp256k1 := crypto.Secp256k1{}
+		secp256k1.PrivateKey = *k.PrivateKey
+		plaintext, err = secp256k1.Decrypt(ciphertext, aad)
+		if err != nil {
+			return nil, crypto.ErrCannotDecrypt
+		}
+
+	default:
+		return nil, fmt.Errorf("failed to decrypt using key: %s; decryption not supported by key spec: %s", k.ID, *k.Spec)
 	}
 	return plaintext, nil
 }
 
 // decryptSymmetric attempts symmetric decryption,
 // returns the plaintext and any error
-func (k *Key) decryptSymmetric(ciphertext, nonce []byte) ([]byte, error) {
+// aad is the optional additional authenticated data, which is only supported by AEAD specs
+func (k *Key) decryptSymmetric(ciphertext, nonce, aad []byte) ([]byte, error) {
 	// k.mutex.Lock()
 	// defer k.mutex.Unlock()
 	//TODO validate mutex
@@ -1000,6 +1278,18 @@ func (k *Key) decryptSymmetric(ciphertext, nonce []byte) ([]byte, error) {
 		return nil, fmt.Errorf("failed to decrypt using key: %s; nil seed", k.ID)
 	}
 
+	if *k.Spec == KeySpecChaCha20 && len(aad) > 0 {
+		return nil, fmt.Errorf("failed to decrypt using key: %s; %s", k.ID, crypto.ErrUnsupportedAAD.Error())
+	}
+
+	if *k.Spec == KeySpecXChaCha20Poly1305 && k.Seed == nil {
+		return nil, fmt.Errorf("failed to decrypt using key: %s; nil seed", k.ID)
+	}
+
+	if *k.Spec == KeySpecAES256SIV && k.PrivateKey == nil {
+		return nil, fmt.Errorf("failed to decrypt using key: %s; nil private key", k.ID)
+	}
+
 	k.decryptFields()
 	defer k.encryptFields()
 
@@ -1011,7 +1301,7 @@ func (k *Key) decryptSymmetric(ciphertext, nonce []byte) ([]byte, error) {
 		aes256.PrivateKey = *k.PrivateKey
 
 		var err error
-		plaintext, err = aes256.Decrypt(ciphertext, nonce)
+		plaintext, err = aes256.DecryptWithAAD(ciphertext, nonce, aad)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decrypt %d-byte ciphertext using key %s. Error: %s", len(plaintext), k.ID, err.Error())
 		}
@@ -1025,6 +1315,31 @@ func (k *Key) decryptSymmetric(ciphertext, nonce []byte) ([]byte, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to decrypt %d-byte ciphertext using key %s. Error: %s", len(plaintext), k.ID, err.Error())
 		}
+
+	case KeySpecXChaCha20Poly1305:
+		xchacha := crypto.XChaCha20Poly1305{
+			Seed: *k.Seed,
+		}
+
+		var err error
+		plaintext, err = xchacha.Decrypt(ciphertext, nonce, aad)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %d-byte ciphertext using key %s. Error: %s", len(ciphertext), k.ID, err.Error())
+		}
+
+	case KeySpecAES256SIV:
+		aessiv := crypto.AES256SIV{
+			PrivateKey: *k.PrivateKey,
+		}
+
+		var err error
+		plaintext, err = aessiv.Decrypt(ciphertext, nonce, aad)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %d-byte ciphertext using key %s. Error: %s", len(ciphertext), k.ID, err.Error())
+		}
+
+	default:
+		return nil, fmt.Errorf("failed to decrypt %d-byte ciphertext using key %s; decryption not supported by key spec: %s", len(ciphertext), k.ID, *k.Spec)
 	}
 
 	return plaintext, nil
@@ -1069,7 +1384,7 @@ func (k *Key) deriveSecp256k1KeyFromHDWallet(path accounts.DerivationPath) (*cry
 // using the given nonce and key generation context identifier; note that the nonce
 // must not be reused or the secret will be exposed...
 func (k *Key) DeriveSymmetric(nonce, context []byte, name, description string) (*Key, error) {
-	if k.Spec == nil || (*k.Spec != KeySpecChaCha20) {
+	if k.Spec == nil || (*k.Spec != KeySpecChaCha20 && *k.Spec != KeySpecXChaCha20Poly1305) {
 		return nil, fmt.Errorf("failed to derive symmetric key from key: %s; nil or invalid key spec", k.ID)
 	}
 
@@ -1109,6 +1424,35 @@ func (k *Key) DeriveSymmetric(nonce, context []byte, name, description string) (
 			return nil, fmt.Errorf("failed to save derived symmetric key from key: %s; %s", k.ID, *chacha20Key.Errors[0].Message)
 		}
 		return chacha20Key, nil
+
+	case KeySpecXChaCha20Poly1305:
+		key := []byte(*k.Seed)
+		derivedKey, err := chacha20.HChaCha20(key, nonce)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive symmetric key from key: %s; %s", k.ID, err.Error())
+		}
+
+		xchachaKey := &Key{
+			VaultID:     k.VaultID,
+			Type:        common.StringOrNil(KeyTypeSymmetric),
+			Usage:       common.StringOrNil(KeyUsageEncryptDecrypt),
+			Spec:        common.StringOrNil(KeySpecXChaCha20Poly1305),
+			Name:        common.StringOrNil(name),
+			Description: common.StringOrNil(description),
+			Seed:        &derivedKey,
+		}
+
+		db := dbconf.DatabaseConnection()
+
+		err = xchachaKey.create()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create derived symmetric key from key: %s; %s", k.ID, err.Error())
+		}
+
+		if !xchachaKey.save(db) {
+			return nil, fmt.Errorf("failed to save derived symmetric key from key: %s; %s", k.ID, *xchachaKey.Errors[0].Message)
+		}
+		return xchachaKey, nil
 	}
 
 	return nil, fmt.Errorf("failed to derive symmetric key from key: %s; %s key spec not implemented", k.ID, *k.Spec)
@@ -1118,26 +1462,66 @@ func (k *Key) DeriveSymmetric(nonce, context []byte, name, description string) (
 // nonce is optional and a random nonce will be generated if nil
 // never use more than 2^32 random nonces with a given key because of the risk of a repeat.
 func (k *Key) Encrypt(plaintext []byte, nonce []byte) ([]byte, error) {
+	return k.EncryptWithAAD(plaintext, nonce, nil)
+}
+
+// EncryptWithAAD encrypts the given plaintext with the key, according to its spec, binding
+// the optional additional authenticated data to the ciphertext; the same additional data
+// must be provided to decrypt the ciphertext
+func (k *Key) EncryptWithAAD(plaintext, nonce, aad []byte) ([]byte, error) {
 	if k.Type != nil && *k.Type == KeyTypeSymmetric {
-		return k.encryptSymmetric(plaintext, nonce)
+		return k.encryptSymmetric(plaintext, nonce, aad)
 	}
 
 	if k.Type != nil && *k.Type == KeyTypeAsymmetric {
-		return k.encryptAsymmetric(plaintext)
+		if len(aad) > 0 && !k.isHybrid() {
+			return nil, fmt.Errorf("failed to encrypt %d-byte plaintext using key: %s; %s", len(plaintext), k.ID, crypto.ErrUnsupportedAAD.Error())
+		}
+		return k.encryptAsymmetric(plaintext, aad)
 	}
 
 	return nil, fmt.Errorf("failed to encrypt %d-byte plaintext using key: %s; nil or invalid key type", len(plaintext), k.ID)
 }
 
+// Reencrypt decrypts the ciphertext using the given version of the key, or any of its
+// non-destroyed versions when no version is given, and encrypts the plaintext using the
+// latest version of the destination key; the plaintext never leaves the service; aad and
+// destinationAAD are the optional additional authenticated data of each ciphertext
+func (k *Key) Reencrypt(db *gorm.DB, ciphertext, aad []byte, version *int, destination *Key, destinationAAD []byte) ([]byte, error) {
+	if destination == nil || destination.ID == uuid.Nil {
+		return nil, fmt.Errorf("failed to re-encrypt %d-byte ciphertext using key: %s; nil destination key", len(ciphertext), k.ID)
+	}
+
+	plaintext, _, err := k.DecryptVersioned(db, ciphertext, aad, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to re-encrypt %d-byte ciphertext using key: %s; %s", len(ciphertext), k.ID, err.Error())
+	}
+
+	// wipe the plaintext in memory before garbage collection
+	defer crypto.WipeBytes(plaintext)
+
+	reencrypted, err := destination.EncryptWithAAD(plaintext, nil, destinationAAD)
+	if err != nil {
+		return nil, fmt.Errorf("failed to re-encrypt %d-byte ciphertext using destination key: %s; %s", len(ciphertext), destination.ID, err.Error())
+	}
+
+	return reencrypted, nil
+}
+
 // encryptAsymmetric attempts asymmetric encryption using the public key;
 // returns the ciphertext any error
-func (k *Key) encryptAsymmetric(plaintext []byte) ([]byte, error) {
+// aad is the optional additional authenticated data, which is only supported by hybrid specs
+func (k *Key) encryptAsymmetric(plaintext, aad []byte) ([]byte, error) {
 	defer func() {
 		if r := recover(); r != nil {
 			common.Log.Warningf("recovered from panic during encryptAsymmetric(); %s", r)
 		}
 	}()
 
+	if k.Spec == nil {
+		return nil, fmt.Errorf("failed to encrypt using key: %s; nil key spec", k.ID)
+	}
+
 	k.decryptFields()
 	defer k.encryptFields()
 
@@ -1174,6 +1558,29 @@ func (k *Key) encryptAsymmetric(plaintext []byte) ([]byte, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to encrypt. Error: %s", err.Error())
 		}
+
+	case KeySpecECCC25519:
+		c25519key := crypto.C25519{}
+		if k.PublicKey != nil {
+			c25519key.PublicKey = *k.PublicKey
+		}
+		ciphertext, err = c25519key.Encrypt(plaintext, nil, aad)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt. Error: %s", err.Error())
+		}
+
+	case KeySpecECCSecp256k1:
+		secp256k1key := crypto.Secp256k1{}
+		if k.PublicKey != nil {
+			secp256k1key.PublicKey = *k.PublicKey
+		}
+		ciphertext, err = secp256k1key.Encrypt(plaintext, aad)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt. Error: %s", err.Error())
+		}
+
+	default:
+		return nil, fmt.Errorf("failed to encrypt using key: %s; encryption not supported by key spec: %s", k.ID, *k.Spec)
 	}
 	return ciphertext, nil
 }
@@ -1181,7 +1588,8 @@ func (k *Key) encryptAsymmetric(plaintext []byte) ([]byte, error) {
 // encryptSymmetric attempts symmetric AES-256-GCM encryption using the key;
 // returns the ciphertext-- with 12-byte nonce prepended-- and any error
 // nonce is optional and if nil is passed in, a random nonce is created
-func (k *Key) encryptSymmetric(plaintext []byte, nonce []byte) ([]byte, error) {
+// aad is the optional additional authenticated data, which is only supported by AEAD specs
+func (k *Key) encryptSymmetric(plaintext, nonce, aad []byte) ([]byte, error) {
 	defer func() {
 		if r := recover(); r != nil {
 			common.Log.Warningf("recovered from panic during encryptSymmetric(); %s", r)
@@ -1192,10 +1600,22 @@ func (k *Key) encryptSymmetric(plaintext []byte, nonce []byte) ([]byte, error) {
 		return nil, fmt.Errorf("failed to encrypt using key: %s; nil seed", k.ID)
 	}
 
+	if *k.Spec == KeySpecChaCha20 && len(aad) > 0 {
+		return nil, fmt.Errorf("failed to encrypt using key: %s; %s", k.ID, crypto.ErrUnsupportedAAD.Error())
+	}
+
+	if *k.Spec == KeySpecXChaCha20Poly1305 && k.Seed == nil {
+		return nil, fmt.Errorf("failed to encrypt using key: %s; nil seed", k.ID)
+	}
+
 	if *k.Spec == KeySpecAES256GCM && k.PrivateKey == nil {
 		return nil, fmt.Errorf("failed to encrypt using key: %s; nil private key", k.ID)
 	}
 
+	if *k.Spec == KeySpecAES256SIV && k.PrivateKey == nil {
+		return nil, fmt.Errorf("failed to encrypt using key: %s; nil private key", k.ID)
+	}
+
 	k.decryptFields()
 	defer k.encryptFields()
 
@@ -1208,7 +1628,7 @@ func (k *Key) encryptSymmetric(plaintext []byte, nonce []byte) ([]byte, error) {
 		}
 
 		var err error
-		ciphertext, err = aes256.Encrypt(plaintext, nonce)
+		ciphertext, err = aes256.EncryptWithAAD(plaintext, nonce, aad)
 		if err != nil {
 			return nil, fmt.Errorf("failed to encrypt %d-byte plaintext using key %s. Error: %s", len(plaintext), k.ID, err.Error())
 		}
@@ -1223,6 +1643,31 @@ func (k *Key) encryptSymmetric(plaintext []byte, nonce []byte) ([]byte, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to encrypt %d-byte plaintext using key %s. Error: %s", len(plaintext), k.ID, err.Error())
 		}
+
+	case KeySpecXChaCha20Poly1305:
+		xchacha := crypto.XChaCha20Poly1305{
+			Seed: *k.Seed,
+		}
+
+		var err error
+		ciphertext, err = xchacha.Encrypt(plaintext, nonce, aad)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt %d-byte plaintext using key %s. Error: %s", len(plaintext), k.ID, err.Error())
+		}
+
+	case KeySpecAES256SIV:
+		aessiv := crypto.AES256SIV{
+			PrivateKey: *k.PrivateKey,
+		}
+
+		var err error
+		ciphertext, err = aessiv.Encrypt(plaintext, nonce, aad)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt %d-byte plaintext using key %s. Error: %s", len(plaintext), k.ID, err.Error())
+		}
+
+	default:
+		return nil, fmt.Errorf("failed to encrypt %d-byte plaintext using key %s; encryption not supported by key spec: %s", len(plaintext), k.ID, *k.Spec)
 	}
 
 	return ciphertext, nil
@@ -1230,7 +1675,7 @@ func (k *Key) encryptSymmetric(plaintext []byte, nonce []byte) ([]byte, error) {
 
 // Sign the input with the private key
 func (k *Key) Sign(payload []byte, opts *SigningOptions) ([]byte, error) {
-	if k.Spec == nil || (*k.Spec != KeySpecECCBabyJubJub && *k.Spec != KeySpecECCEd25519 && *k.Spec != KeySpecECCEd25519NKey && *k.Spec != KeySpecECCSecp256k1 && *k.Spec != KeySpecRSA4096 && *k.Spec != KeySpecRSA3072 && *k.Spec != KeySpecRSA2048 && *k.Spec != KeySpecECCBIP39 && *k.Spec != KeySpecBLS12381) {
+	if k.Spec == nil || (*k.Spec != KeySpecECCBabyJubJub && *k.Spec != KeySpecECCEd25519 && *k.Spec != KeySpecECCEd25519NKey && *k.Spec != KeySpecECCSecp256k1 && *k.Spec != KeySpecRSA4096 && *k.Spec != KeySpecRSA3072 && *k.Spec != KeySpecRSA2048 && *k.Spec != KeySpecECCBIP39 && *k.Spec != KeySpecBLS12381 && !k.isECDSA()) {
 		return nil, fmt.Errorf("failed to sign %d-byte payload using key: %s; nil or invalid key spec", len(payload), k.ID)
 	}
 
@@ -1319,6 +1764,12 @@ func (k *Key) Sign(payload []byte, opts *SigningOptions) ([]byte, error) {
 		secp256k1.PrivateKey = *k.PrivateKey
 		sig, sigerr = secp256k1.Sign(payload)
 
+	case KeySpecECCNISTP256, KeySpecECCNISTP384, KeySpecECCNISTP521:
+		if k.PrivateKey == nil {
+			return nil, fmt.Errorf("failed to sign %d-byte payload using key: %s; nil private key", len(payload), k.ID)
+		}
+		sig, sigerr = k.signECDSA(payload, opts)
+
 	case KeySpecRSA4096:
 		if opts == nil || opts.Algorithm == nil {
 			return nil, fmt.Errorf("failed to sign %d-byte payload using key: %s; nil signing options", len(payload), k.ID)
@@ -1373,7 +1824,7 @@ func (k *Key) Sign(payload []byte, opts *SigningOptions) ([]byte, error) {
 
 // Verify the given payload against a signature using the public key
 func (k *Key) Verify(payload, sig []byte, opts *SigningOptions) error {
-	if k.Spec == nil || (*k.Spec != KeySpecECCBabyJubJub && *k.Spec != KeySpecECCEd25519 && *k.Spec != KeySpecECCEd25519NKey && *k.Spec != KeySpecECCSecp256k1 && *k.Spec != KeySpecRSA4096 && *k.Spec != KeySpecRSA3072 && *k.Spec != KeySpecRSA2048 && *k.Spec != KeySpecECCBIP39 && *k.Spec != KeySpecBLS12381) {
+	if k.Spec == nil || (*k.Spec != KeySpecECCBabyJubJub && *k.Spec != KeySpecECCEd25519 && *k.Spec != KeySpecECCEd25519NKey && *k.Spec != KeySpecECCSecp256k1 && *k.Spec != KeySpecRSA4096 && *k.Spec != KeySpecRSA3072 && *k.Spec != KeySpecRSA2048 && *k.Spec != KeySpecECCBIP39 && *k.Spec != KeySpecBLS12381 && !k.isECDSA()) {
 		return fmt.Errorf("failed to verify signature of %d-byte payload using key: %s; nil or invalid key spec", len(payload), k.ID)
 	}
 
@@ -1430,6 +1881,9 @@ func (k *Key) Verify(payload, sig []byte, opts *SigningOptions) error {
 		}
 		return secp256k1.Verify(payload, sig)
 
+	case KeySpecECCNISTP256, KeySpecECCNISTP384, KeySpecECCNISTP521:
+		return k.verifyECDSA(payload, sig, opts)
+
 	case KeySpecRSA4096:
 		if opts == nil || opts.Algorithm == nil {
 			return fmt.Errorf("failed to verify signature of %d-byte payload using key: %s; no algorithm provided", len(payload), k.ID)
@@ -1499,6 +1953,12 @@ func (k *Key) Validate() bool {
 		})
 	}
 
+	if k.Deterministic != nil && *k.Deterministic && (k.Spec == nil || *k.Spec != KeySpecAES256SIV) {
+		k.Errors = append(k.Errors, &provide.Error{
+			Message: common.StringOrNil(fmt.Sprintf("deterministic encryption is only supported by %s keys", KeySpecAES256SIV)),
+		})
+	}
+
 	return len(k.Errors) == 0
 }
 
@@ -1589,6 +2049,21 @@ func ValidateKeySpec(keySpec *string) (*string, error) {
 	case strings.ToUpper(KeySpecChaCha20):
 		return common.StringOrNil(KeySpecChaCha20), nil
 
+	case strings.ToUpper(KeySpecXChaCha20Poly1305):
+		return common.StringOrNil(KeySpecXChaCha20Poly1305), nil
+
+	case strings.ToUpper(KeySpecAES256SIV):
+		return common.StringOrNil(KeySpecAES256SIV), nil
+
+	case strings.ToUpper(KeySpecHMACSHA256):
+		return common.StringOrNil(KeySpecHMACSHA256), nil
+
+	case strings.ToUpper(KeySpecHMACSHA384):
+		return common.StringOrNil(KeySpecHMACSHA384), nil
+
+	case strings.ToUpper(KeySpecHMACSHA512):
+		return common.StringOrNil(KeySpecHMACSHA512), nil
+
 	case strings.ToUpper(KeySpecECCBIP39):
 		return common.StringOrNil(KeySpecECCBIP39), nil
 
@@ -1607,6 +2082,15 @@ func ValidateKeySpec(keySpec *string) (*string, error) {
 	case strings.ToUpper(KeySpecECCSecp256k1):
 		return common.StringOrNil(KeySpecECCSecp256k1), nil
 
+	case strings.ToUpper(KeySpecECCNISTP256):
+		return common.StringOrNil(KeySpecECCNISTP256), nil
+
+	case strings.ToUpper(KeySpecECCNISTP384):
+		return common.StringOrNil(KeySpecECCNISTP384), nil
+
+	case strings.ToUpper(KeySpecECCNISTP521):
+		return common.StringOrNil(KeySpecECCNISTP521), nil
+
 	case strings.ToUpper(KeySpecRSA2048):
 		return common.StringOrNil(KeySpecRSA2048), nil
 
@@ -0,0 +1,79 @@
+package vault
+
+import (
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+	"github.com/provideplatform/vault/crypto"
+)
+
+// isDeterministic returns true if deterministic encryption is enabled for the key
+func (k *Key) isDeterministic() bool {
+	return k.Spec != nil && *k.Spec == KeySpecAES256SIV && k.Deterministic != nil && *k.Deterministic
+}
+
+// EncryptDeterministic encrypts the given plaintext with the key without a nonce, binding
+// the optional additional authenticated data to the ciphertext; identical plaintext and
+// additional data always produce identical ciphertext for a given version of the key,
+// which allows equality lookups on encrypted data at the cost of revealing which
+// ciphertexts share a plaintext; the key must be created with deterministic enabled
+func (k *Key) EncryptDeterministic(plaintext, aad []byte) ([]byte, error) {
+	if !k.isDeterministic() {
+		return nil, fmt.Errorf("failed to encrypt %d-byte plaintext using key: %s; deterministic encryption not enabled for key", len(plaintext), k.ID)
+	}
+
+	if k.PrivateKey == nil {
+		return nil, fmt.Errorf("failed to encrypt using key: %s; nil private key", k.ID)
+	}
+
+	k.decryptFields()
+	defer k.encryptFields()
+
+	aessiv := crypto.AES256SIV{
+		PrivateKey: *k.PrivateKey,
+	}
+
+	ciphertext, err := aessiv.EncryptDeterministic(plaintext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt %d-byte plaintext using key %s. Error: %s", len(plaintext), k.ID, err.Error())
+	}
+
+	return ciphertext, nil
+}
+
+// DecryptDeterministic decrypts a ciphertext which was deterministically encrypted using
+// the key; decryption fails unless the additional authenticated data matches that given
+// on encryption
+func (k *Key) DecryptDeterministic(ciphertext, aad []byte) ([]byte, error) {
+	if k.Spec == nil || *k.Spec != KeySpecAES256SIV {
+		return nil, fmt.Errorf("failed to decrypt %d-byte ciphertext using key: %s; deterministic encryption not supported by key spec", len(ciphertext), k.ID)
+	}
+
+	if k.PrivateKey == nil {
+		return nil, fmt.Errorf("failed to decrypt using key: %s; nil private key", k.ID)
+	}
+
+	k.decryptFields()
+	defer k.encryptFields()
+
+	aessiv := crypto.AES256SIV{
+		PrivateKey: *k.PrivateKey,
+	}
+
+	plaintext, err := aessiv.DecryptDeterministic(ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %d-byte ciphertext using key %s. Error: %s", len(ciphertext), k.ID, err.Error())
+	}
+
+	return plaintext, nil
+}
+
+// DecryptDeterministicVersioned decrypts the deterministically encrypted ciphertext using
+// the given version of the key or, when no version is given, using the latest version
+// followed by each previous non-destroyed version; returns the plaintext and the version
+// which decrypted the ciphertext
+func (k *Key) DecryptDeterministicVersioned(db *gorm.DB, ciphertext, aad []byte, version *int) ([]byte, int, error) {
+	return k.decryptVersioned(db, version, func(key *Key) ([]byte, error) {
+		return key.DecryptDeterministic(ciphertext, aad)
+	})
+}
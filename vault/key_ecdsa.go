@@ -0,0 +1,138 @@
+package vault
+
+import (
+	"crypto/elliptic"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/provideplatform/vault/common"
+	"github.com/provideplatform/vault/crypto"
+)
+
+// isECDSA returns true if the key is a NIST P-256, P-384 or P-521 ECDSA key
+func (k *Key) isECDSA() bool {
+	return k.Spec != nil && ecdsaCurve(*k.Spec) != nil
+}
+
+// ecdsaCurve returns the curve of the given ECDSA key spec, or nil
+func ecdsaCurve(spec string) elliptic.Curve {
+	switch spec {
+	case KeySpecECCNISTP256:
+		return elliptic.P256()
+	case KeySpecECCNISTP384:
+		return elliptic.P384()
+	case KeySpecECCNISTP521:
+		return elliptic.P521()
+	}
+	return nil
+}
+
+// ecdsaAlgorithm returns the JWS algorithm of the given ECDSA key spec
+func ecdsaAlgorithm(spec string) string {
+	switch spec {
+	case KeySpecECCNISTP384:
+		return "ES384"
+	case KeySpecECCNISTP521:
+		return "ES512"
+	}
+	return "ES256"
+}
+
+// createECDSAKeypair creates a keypair on the NIST curve of the given spec
+func (k *Key) createECDSAKeypair(spec string) error {
+	curve := ecdsaCurve(spec)
+	if curve == nil {
+		return fmt.Errorf("invalid ECDSA key spec: %s", spec)
+	}
+
+	keypair, err := crypto.CreateECDSAKeyPair(curve)
+	if err != nil {
+		return err
+	}
+
+	k.PrivateKey = &keypair.PrivateKey
+	k.PublicKey = &keypair.PublicKey
+	k.Type = common.StringOrNil(KeyTypeAsymmetric)
+	k.Spec = common.StringOrNil(spec)
+
+	common.Log.Debugf("created %s key for vault: %s; public key: 0x%s", spec, k.VaultID, hex.EncodeToString(*k.PublicKey))
+	return nil
+}
+
+// ecdsaSignatureFormat validates the ECDSA options for the key and returns the
+// signature format, which defaults to der
+func (k *Key) ecdsaSignatureFormat(opts *SigningOptions) (string, error) {
+	if opts == nil {
+		return crypto.ECDSASignatureFormatDER, nil
+	}
+
+	if opts.Algorithm != nil && !strings.EqualFold(*opts.Algorithm, ecdsaAlgorithm(*k.Spec)) {
+		return "", fmt.Errorf("unsupported algorithm %s for %s key: %s; %s required", *opts.Algorithm, *k.Spec, k.ID, ecdsaAlgorithm(*k.Spec))
+	}
+
+	if opts.SignatureFormat == nil {
+		return crypto.ECDSASignatureFormatDER, nil
+	}
+
+	switch strings.ToLower(*opts.SignatureFormat) {
+	case crypto.ECDSASignatureFormatDER:
+		return crypto.ECDSASignatureFormatDER, nil
+	case crypto.ECDSASignatureFormatRaw:
+		return crypto.ECDSASignatureFormatRaw, nil
+	}
+
+	return "", fmt.Errorf("invalid signature format: %s; signature format must be (%s or %s)", *opts.SignatureFormat, crypto.ECDSASignatureFormatDER, crypto.ECDSASignatureFormatRaw)
+}
+
+// signECDSA signs the payload using the ECDSA private key, according to the signing options
+func (k *Key) signECDSA(payload []byte, opts *SigningOptions) ([]byte, error) {
+	format, err := k.ecdsaSignatureFormat(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	keypair := crypto.ECDSAKeyPair{
+		Curve:      ecdsaCurve(*k.Spec),
+		PrivateKey: *k.PrivateKey,
+	}
+
+	sig, err := keypair.Sign(payload, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign %d-byte payload using key: %s; %s", len(payload), k.ID, err.Error())
+	}
+
+	return sig, nil
+}
+
+// verifyECDSA verifies the signature of the payload using the ECDSA public key, according
+// to the signing options
+func (k *Key) verifyECDSA(payload, sig []byte, opts *SigningOptions) error {
+	format, err := k.ecdsaSignatureFormat(opts)
+	if err != nil {
+		return err
+	}
+
+	keypair := crypto.ECDSAKeyPair{
+		Curve:     ecdsaCurve(*k.Spec),
+		PublicKey: *k.PublicKey,
+	}
+
+	return keypair.Verify(payload, sig, format)
+}
+
+// enrichECDSA sets the PEM-encoded PKIX public key of the ECDSA key
+func (k *Key) enrichECDSA() {
+	keypair := crypto.ECDSAKeyPair{
+		Curve:     ecdsaCurve(*k.Spec),
+		PublicKey: *k.PublicKey,
+	}
+
+	publicKey, err := keypair.PublicKeyPEM()
+	if err != nil {
+		common.Log.Warningf("failed to encode public key of key: %s; %s", k.ID, err.Error())
+		return
+	}
+
+	k.PublicKeyHex = common.StringOrNil(string(publicKey))
+}
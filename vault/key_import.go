@@ -0,0 +1,358 @@
+package vault
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	uuid "github.com/kthomas/go.uuid"
+	provide "github.com/provideplatform/provide-go/api"
+	"github.com/provideplatform/vault/common"
+	"github.com/provideplatform/vault/crypto"
+)
+
+// KeyImportWrappingKeySpec is the spec of the ephemeral wrapping key issued for each key import
+const KeyImportWrappingKeySpec = KeySpecRSA4096
+
+// keyImportTTL is the duration for which an import token and its wrapping key remain valid
+const keyImportTTL = time.Hour * 24
+
+// KeyImport is an ephemeral RSA-4096 wrapping key issued to import key material of the given
+// spec into a vault; the id of the key import is the import token, which is used exactly once
+// to upload the key material wrapped by the public key. The private key of the wrapping key is
+// wrapped by the vault master key and is removed once the key material has been imported
+type KeyImport struct {
+	provide.Model
+	VaultID           *uuid.UUID `sql:"not null;type:uuid" json:"vault_id"`
+	Spec              *string    `sql:"not null" json:"spec"`
+	WrappingAlgorithm *string    `sql:"not null" json:"wrapping_algorithm"`
+	PublicKey         *[]byte    `sql:"type:bytea" json:"-"`
+	PrivateKey        *[]byte    `sql:"type:bytea" json:"-"`
+	MasterKeyID       *uuid.UUID `sql:"type:uuid" json:"-"` // master key version which wraps the wrapping key
+	ExpiresAt         *time.Time `sql:"not null" json:"expires_at"`
+	KeyID             *uuid.UUID `sql:"type:uuid" json:"key_id,omitempty"` // imported key
+	ImportedAt        *time.Time `json:"imported_at,omitempty"`
+}
+
+// KeyImportParamsRequestResponse contains the spec of the key material to be imported and the
+// key wrapping algorithm (RSAES_OAEP_SHA_256 or RSA_AES_KEY_WRAP_SHA_256; defaults to
+// RSAES_OAEP_SHA_256); the response contains the import token and the PEM-encoded public key
+// of the wrapping key, which are valid until the import token expires
+type KeyImportParamsRequestResponse struct {
+	Spec              *string    `json:"spec"`
+	WrappingAlgorithm *string    `json:"wrapping_algorithm,omitempty"`
+	WrappingKey       *string    `json:"wrapping_key,omitempty"`
+	WrappingKeySpec   *string    `json:"wrapping_key_spec,omitempty"`
+	ImportToken       *string    `json:"import_token,omitempty"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+}
+
+// KeyImportRequest contains the import token and the base64-encoded key material, wrapped by
+// the wrapping key of the import token, of the key to be created; the key material of symmetric
+// keys is the raw key, of Ed25519 keys the 32-byte seed or a DER-encoded PKCS#8 private key, of
+// secp256k1 and BLS12-381 keys the 32-byte private key and of RSA keys a DER-encoded PKCS#8
+// or PKCS#1 private key
+type KeyImportRequest struct {
+	ImportToken        *string `json:"import_token"`
+	Name               *string `json:"name"`
+	Description        *string `json:"description"`
+	Usage              *string `json:"usage"`
+	WrappedKeyMaterial *string `json:"wrapped_key_material"`
+}
+
+// isImportableKeySpec returns true if key material of the given spec may be imported
+func isImportableKeySpec(spec string) bool {
+	switch spec {
+	case KeySpecAES256GCM, KeySpecAES256SIV, KeySpecECCEd25519, KeySpecECCSecp256k1, KeySpecRSA2048, KeySpecRSA3072, KeySpecRSA4096, KeySpecBLS12381:
+		return true
+	}
+	return false
+}
+
+// CreateKeyImport creates an ephemeral wrapping key and import token to import key material of
+// the given spec into the vault; the key material of RSA keys is too long to be wrapped using
+// RSAES-OAEP and must instead be wrapped using RSA_AES_KEY_WRAP_SHA_256
+func CreateKeyImport(db *gorm.DB, vault *Vault, spec, wrappingAlgorithm string) (*KeyImport, error) {
+	if !isImportableKeySpec(spec) {
+		return nil, fmt.Errorf("importing key material is not supported for key spec: %s", spec)
+	}
+
+	err := crypto.ValidateKeyWrapAlgorithm(wrappingAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key import; %s: %s", err.Error(), wrappingAlgorithm)
+	}
+
+	if wrappingAlgorithm == crypto.KeyWrapAlgorithmRSAOAEPSHA256 && (spec == KeySpecRSA2048 || spec == KeySpecRSA3072 || spec == KeySpecRSA4096) {
+		return nil, fmt.Errorf("failed to create key import; %s key material must be wrapped using %s", spec, crypto.KeyWrapAlgorithmRSAAESKeyWrapSHA256)
+	}
+
+	rsaKeyPair, err := crypto.CreateRSAKeyPair(KeyBits4096)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key import wrapping key; %s", err.Error())
+	}
+
+	// wrap the private key of the wrapping key using the active vault master key
+	wrappingKey := &Key{
+		VaultID:    &vault.ID,
+		Type:       common.StringOrNil(KeyTypeAsymmetric),
+		Spec:       common.StringOrNil(KeyImportWrappingKeySpec),
+		PrivateKey: &rsaKeyPair.PrivateKey,
+		vault:      vault,
+	}
+	wrappingKey.setEncrypted(false)
+
+	err = wrappingKey.encryptFields()
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap key import wrapping key; %s", err.Error())
+	}
+
+	// remove the wrapping keys of previous imports which expired without being used
+	db.Model(&KeyImport{}).
+		Where("vault_id = ? AND imported_at IS NULL AND expires_at < ? AND private_key IS NOT NULL", vault.ID, time.Now()).
+		Update("private_key", gorm.Expr("NULL"))
+
+	expiresAt := time.Now().Add(keyImportTTL)
+	keyImport := &KeyImport{
+		VaultID:           &vault.ID,
+		Spec:              common.StringOrNil(spec),
+		WrappingAlgorithm: common.StringOrNil(wrappingAlgorithm),
+		PublicKey:         &rsaKeyPair.PublicKey,
+		PrivateKey:        wrappingKey.PrivateKey,
+		MasterKeyID:       wrappingKey.MasterKeyID,
+		ExpiresAt:         &expiresAt,
+	}
+
+	result := db.Create(&keyImport)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to persist key import; %s", result.Error.Error())
+	}
+
+	common.Log.Debugf("created key import %s for %s key material in vault %s", keyImport.ID, spec, vault.ID)
+	return keyImport, nil
+}
+
+// GetKeyImport returns the key import of the given import token within the vault
+func GetKeyImport(db *gorm.DB, importToken string, vaultID uuid.UUID) *KeyImport {
+	keyImport := &KeyImport{}
+	db.Where("id = ? AND vault_id = ?", importToken, vaultID).Find(&keyImport)
+	return keyImport
+}
+
+// ParamsResponse returns the import token and the PEM-encoded public key of the wrapping key
+func (i *KeyImport) ParamsResponse() (*KeyImportParamsRequestResponse, error) {
+	if i.PublicKey == nil {
+		return nil, fmt.Errorf("nil wrapping key for key import: %s", i.ID)
+	}
+
+	return &KeyImportParamsRequestResponse{
+		Spec:              i.Spec,
+		WrappingAlgorithm: i.WrappingAlgorithm,
+		WrappingKey: common.StringOrNil(string(pem.EncodeToMemory(&pem.Block{
+			Type:  "PUBLIC KEY",
+			Bytes: *i.PublicKey,
+		}))),
+		WrappingKeySpec: common.StringOrNil(KeyImportWrappingKeySpec),
+		ImportToken:     common.StringOrNil(i.ID.String()),
+		ExpiresAt:       i.ExpiresAt,
+	}, nil
+}
+
+// Import unwraps the key material using the wrapping key, validates it against the spec of the
+// key import and creates the given key using the key material, which is wrapped by the vault
+// master key; the import token is consumed within the same transaction as the key is created
+func (i *KeyImport) Import(db *gorm.DB, key *Key, wrappedKeyMaterial []byte) error {
+	if i.ImportedAt != nil || i.PrivateKey == nil {
+		return fmt.Errorf("import token has already been used: %s", i.ID)
+	}
+
+	if i.ExpiresAt == nil || time.Now().After(*i.ExpiresAt) {
+		return fmt.Errorf("import token has expired: %s", i.ID)
+	}
+
+	material, err := i.unwrap(wrappedKeyMaterial)
+	if err != nil {
+		return err
+	}
+	defer crypto.WipeBytes(material)
+
+	key.VaultID = i.VaultID
+	key.Spec = i.Spec
+	err = key.importKeyMaterial(material)
+	if err != nil {
+		return err
+	}
+
+	if key.Usage == nil {
+		if *key.Type == KeyTypeSymmetric {
+			key.Usage = common.StringOrNil(KeyUsageEncryptDecrypt)
+		} else {
+			key.Usage = common.StringOrNil(KeyUsageSignVerify)
+		}
+	}
+
+	tx := db.Begin()
+	defer tx.RollbackUnlessCommitted()
+
+	importedAt := time.Now()
+	result := tx.Model(&KeyImport{}).
+		Where("id = ? AND imported_at IS NULL AND expires_at > ?", i.ID, importedAt).
+		Updates(map[string]interface{}{
+			"imported_at": importedAt,
+			"private_key": gorm.Expr("NULL"),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to consume import token: %s; %s", i.ID, result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("import token has expired or has already been used: %s", i.ID)
+	}
+
+	if !key.createPersisted(tx) {
+		if len(key.Errors) > 0 {
+			return fmt.Errorf("failed to import key material; %s", *key.Errors[0].Message)
+		}
+		return fmt.Errorf("failed to import key material")
+	}
+
+	result = tx.Model(&KeyImport{}).Where("id = ?", i.ID).Update("key_id", key.ID)
+	if result.Error != nil {
+		return fmt.Errorf("failed to consume import token: %s; %s", i.ID, result.Error.Error())
+	}
+
+	result = tx.Commit()
+	if result.Error != nil {
+		return fmt.Errorf("failed to import key material; %s", result.Error.Error())
+	}
+
+	i.ImportedAt = &importedAt
+	i.KeyID = &key.ID
+	i.PrivateKey = nil
+
+	common.Log.Debugf("imported %s key material as key %s in vault %s using import token %s", *key.Spec, key.ID, key.VaultID, i.ID)
+	return nil
+}
+
+// unwrap unwraps the key material using the private key of the wrapping key
+func (i *KeyImport) unwrap(wrappedKeyMaterial []byte) ([]byte, error) {
+	wrappingKey := &Key{
+		VaultID:     i.VaultID,
+		Type:        common.StringOrNil(KeyTypeAsymmetric),
+		Spec:        common.StringOrNil(KeyImportWrappingKeySpec),
+		PrivateKey:  i.PrivateKey,
+		MasterKeyID: i.MasterKeyID,
+	}
+	wrappingKey.setEncrypted(true)
+
+	err := wrappingKey.decryptFields()
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap key import wrapping key: %s; %s", i.ID, err.Error())
+	}
+
+	// wipe the plaintext wrapping key in memory before garbage collection
+	defer crypto.WipeBytes(*wrappingKey.PrivateKey)
+
+	rsaKeyPair := crypto.RSAKeyPair{
+		PrivateKey: *wrappingKey.PrivateKey,
+	}
+
+	material, err := rsaKeyPair.Unwrap(wrappedKeyMaterial, *i.WrappingAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap key material using import token: %s; %s", i.ID, err.Error())
+	}
+
+	return material, nil
+}
+
+// importKeyMaterial validates the given key material against the spec of the key and sets the
+// key material and type of the key accordingly
+func (k *Key) importKeyMaterial(material []byte) error {
+	if k.Spec == nil {
+		return fmt.Errorf("failed to import key material; nil key spec")
+	}
+
+	switch *k.Spec {
+	case KeySpecAES256GCM, KeySpecAES256SIV:
+		size := 32
+		if *k.Spec == KeySpecAES256SIV {
+			size = crypto.AESSIVKeySize
+		}
+		if len(material) != size {
+			return fmt.Errorf("failed to import %s key material; expected %d-byte key; got %d bytes", *k.Spec, size, len(material))
+		}
+
+		privateKey := make([]byte, size)
+		copy(privateKey, material)
+		k.PrivateKey = &privateKey
+		k.Type = common.StringOrNil(KeyTypeSymmetric)
+
+	case KeySpecECCEd25519:
+		seed := make([]byte, ed25519.SeedSize)
+		if len(material) == ed25519.SeedSize {
+			copy(seed, material)
+		} else {
+			parsed, err := x509.ParsePKCS8PrivateKey(material)
+			if err != nil {
+				return fmt.Errorf("failed to import %s key material; expected 32-byte seed or PKCS#8 private key", *k.Spec)
+			}
+			privateKey, ok := parsed.(ed25519.PrivateKey)
+			if !ok {
+				return fmt.Errorf("failed to import %s key material; PKCS#8 private key is not an Ed25519 private key", *k.Spec)
+			}
+			copy(seed, privateKey.Seed())
+		}
+
+		publicKey := []byte(crypto.FromSeed(seed).Public().(ed25519.PublicKey))
+		k.Seed = &seed
+		k.PublicKey = &publicKey
+		k.Type = common.StringOrNil(KeyTypeAsymmetric)
+
+	case KeySpecECCSecp256k1:
+		secp256k1KeyPair, err := crypto.Secp256k1KeyPairFromPrivateKey(material)
+		if err != nil {
+			return fmt.Errorf("failed to import %s key material; %s", *k.Spec, err.Error())
+		}
+
+		k.PrivateKey = &secp256k1KeyPair.PrivateKey
+		k.PublicKey = &secp256k1KeyPair.PublicKey
+		k.Type = common.StringOrNil(KeyTypeAsymmetric)
+
+		if k.Description == nil {
+			desc := fmt.Sprintf("secp256k1 keypair; address: %s", *secp256k1KeyPair.Address)
+			k.Description = common.StringOrNil(desc)
+		}
+
+	case KeySpecRSA2048, KeySpecRSA3072, KeySpecRSA4096:
+		rsaKeyPair, bits, err := crypto.RSAKeyPairFromPrivateKey(material)
+		if err != nil {
+			return fmt.Errorf("failed to import %s key material; %s", *k.Spec, err.Error())
+		}
+
+		expectedBits := map[string]int{KeySpecRSA2048: KeyBits2048, KeySpecRSA3072: KeyBits3072, KeySpecRSA4096: KeyBits4096}[*k.Spec]
+		if bits != expectedBits {
+			crypto.WipeBytes(rsaKeyPair.PrivateKey)
+			return fmt.Errorf("failed to import %s key material; expected %d-bit key; got %d bits", *k.Spec, expectedBits, bits)
+		}
+
+		k.PrivateKey = &rsaKeyPair.PrivateKey
+		k.PublicKey = &rsaKeyPair.PublicKey
+		k.Type = common.StringOrNil(KeyTypeAsymmetric)
+
+	case KeySpecBLS12381:
+		blsKeyPair, err := crypto.BLS12381KeyPairFromPrivateKey(material)
+		if err != nil {
+			return fmt.Errorf("failed to import %s key material; %s", *k.Spec, err.Error())
+		}
+
+		k.PrivateKey = blsKeyPair.PrivateKey
+		k.PublicKey = blsKeyPair.PublicKey
+		k.Type = common.StringOrNil(KeyTypeAsymmetric)
+
+	default:
+		return fmt.Errorf("importing key material is not supported for key spec: %s", *k.Spec)
+	}
+
+	return nil
+}
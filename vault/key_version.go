@@ -0,0 +1,331 @@
+package vault
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	uuid "github.com/kthomas/go.uuid"
+	provide "github.com/provideplatform/provide-go/api"
+	"github.com/provideplatform/vault/common"
+)
+
+// KeyVersion is a previous version of the key material of a key; the latest version is
+// always held by the key itself and a version is archived when it is superseded by a
+// rotation, after which it remains usable to decrypt and verify until it is destroyed
+type KeyVersion struct {
+	provide.Model
+	KeyID       *uuid.UUID `sql:"not null;type:uuid" json:"key_id"`
+	Version     int        `sql:"not null" json:"version"`
+	Seed        *[]byte    `sql:"type:bytea" json:"-"`
+	PublicKey   *[]byte    `sql:"type:bytea" json:"-"`
+	PrivateKey  *[]byte    `sql:"type:bytea" json:"-"`
+	MasterKeyID *uuid.UUID `sql:"type:uuid" json:"-"` // master key version which wraps the key material
+	DestroyedAt *time.Time `json:"destroyed_at,omitempty"`
+}
+
+// isRotatable returns an error if the key does not support rotation
+func (k *Key) isRotatable(db *gorm.DB) error {
+	if k.ID == uuid.Nil {
+		return fmt.Errorf("unable to rotate key instance which only exists in-memory")
+	}
+
+	if k.Ephemeral != nil && *k.Ephemeral {
+		return fmt.Errorf("unable to rotate ephemeral key: %s", k.ID)
+	}
+
+	if k.Spec == nil || *k.Spec == KeySpecECCBIP39 {
+		return fmt.Errorf("unable to rotate key: %s; rotation not supported for key spec", k.ID)
+	}
+
+	err := k.resolveVault(db)
+	if err != nil {
+		return err
+	}
+
+	if k.vault.isMasterKeyVersion(db, k.ID) {
+		return fmt.Errorf("unable to rotate key: %s; vault master keys are rotated using the vault", k.ID)
+	}
+
+	return nil
+}
+
+// Rotate generates a new version of the key material under the same key id and marks it
+// as the latest version; the superseded version is archived and remains usable to
+// decrypt and verify until it is destroyed
+func (k *Key) Rotate(db *gorm.DB) error {
+	if vaultIsSealed() {
+		return fmt.Errorf("vault is sealed")
+	}
+
+	err := k.isRotatable(db)
+	if err != nil {
+		return err
+	}
+
+	tx := db.Begin()
+	defer tx.RollbackUnlessCommitted()
+
+	// lock the key row so concurrent rotations are serialized
+	current := &Key{}
+	tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", k.ID).Find(&current)
+	if current == nil || current.ID == uuid.Nil {
+		return fmt.Errorf("failed to rotate key; key not found: %s", k.ID)
+	}
+
+	archived := &KeyVersion{
+		KeyID:       &current.ID,
+		Version:     current.Version,
+		Seed:        current.Seed,
+		PublicKey:   current.PublicKey,
+		PrivateKey:  current.PrivateKey,
+		MasterKeyID: current.MasterKeyID,
+	}
+
+	result := tx.Create(&archived)
+	if result.Error != nil {
+		return fmt.Errorf("failed to archive version %d of key: %s; %s", current.Version, k.ID, result.Error.Error())
+	}
+
+	next := &Key{
+		VaultID:       current.VaultID,
+		Type:          current.Type,
+		Usage:         current.Usage,
+		Spec:          current.Spec,
+		Name:          current.Name,
+		Description:   current.Description,
+		Deterministic: current.Deterministic,
+	}
+
+	err = next.create()
+	if err != nil {
+		return fmt.Errorf("failed to generate key material for version %d of key: %s; %s", current.Version+1, k.ID, err.Error())
+	}
+
+	result = tx.Model(&Key{}).Where("id = ?", k.ID).Updates(map[string]interface{}{
+		"seed":          next.Seed,
+		"public_key":    next.PublicKey,
+		"private_key":   next.PrivateKey,
+		"master_key_id": next.MasterKeyID,
+		"version":       current.Version + 1,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to persist version %d of key: %s; %s", current.Version+1, k.ID, result.Error.Error())
+	}
+
+	result = tx.Commit()
+	if result.Error != nil {
+		return fmt.Errorf("failed to rotate key: %s; %s", k.ID, result.Error.Error())
+	}
+
+	k.Seed = next.Seed
+	k.PublicKey = next.PublicKey
+	k.PrivateKey = next.PrivateKey
+	k.MasterKeyID = next.MasterKeyID
+	k.Version = current.Version + 1
+	k.setEncrypted(true)
+	k.Enrich()
+
+	common.Log.Debugf("rotated key %s in vault %s; latest version: %d", k.ID, k.VaultID, k.Version)
+	return nil
+}
+
+// ResolveVersion returns the key material of the given version of the key; the latest
+// version is the key itself, and destroyed versions cannot be resolved
+func (k *Key) ResolveVersion(db *gorm.DB, version int) (*Key, error) {
+	if version == k.Version {
+		return k, nil
+	}
+
+	if version < 0 || version > k.Version {
+		return nil, fmt.Errorf("version %d not found for key: %s", version, k.ID)
+	}
+
+	keyVersion := &KeyVersion{}
+	db.Where("key_id = ? AND version = ?", k.ID, version).Find(&keyVersion)
+	if keyVersion == nil || keyVersion.ID == uuid.Nil {
+		return nil, fmt.Errorf("version %d not found for key: %s", version, k.ID)
+	}
+
+	if keyVersion.DestroyedAt != nil {
+		return nil, fmt.Errorf("version %d of key: %s has been destroyed", version, k.ID)
+	}
+
+	return k.withVersion(keyVersion), nil
+}
+
+// withVersion returns a copy of the key which holds the key material of the given version
+func (k *Key) withVersion(keyVersion *KeyVersion) *Key {
+	key := &Key{
+		VaultID:       k.VaultID,
+		Type:          k.Type,
+		Usage:         k.Usage,
+		Spec:          k.Spec,
+		Name:          k.Name,
+		Description:   k.Description,
+		Deterministic: k.Deterministic,
+		Seed:          keyVersion.Seed,
+		PublicKey:     keyVersion.PublicKey,
+		PrivateKey:    keyVersion.PrivateKey,
+		MasterKeyID:   keyVersion.MasterKeyID,
+		Version:       keyVersion.Version,
+		vault:         k.vault,
+	}
+	key.ID = k.ID
+	key.CreatedAt = k.CreatedAt
+	key.setEncrypted(true)
+
+	return key
+}
+
+// previousVersions returns each non-destroyed previous version of the key, latest first
+func (k *Key) previousVersions(db *gorm.DB) []*Key {
+	var keyVersions []*KeyVersion
+	db.Where("key_id = ? AND destroyed_at IS NULL", k.ID).Order("version DESC").Find(&keyVersions)
+
+	versions := make([]*Key, 0)
+	for _, keyVersion := range keyVersions {
+		versions = append(versions, k.withVersion(keyVersion))
+	}
+
+	return versions
+}
+
+// DestroyVersion irrecoverably destroys the key material of the given previous version of
+// the key; the latest version cannot be destroyed, and must instead be rotated first
+func (k *Key) DestroyVersion(db *gorm.DB, version int) error {
+	if version == k.Version {
+		return fmt.Errorf("unable to destroy latest version %d of key: %s", version, k.ID)
+	}
+
+	keyVersion := &KeyVersion{}
+	db.Where("key_id = ? AND version = ?", k.ID, version).Find(&keyVersion)
+	if keyVersion == nil || keyVersion.ID == uuid.Nil {
+		return fmt.Errorf("version %d not found for key: %s", version, k.ID)
+	}
+
+	if keyVersion.DestroyedAt != nil {
+		return fmt.Errorf("version %d of key: %s has already been destroyed", version, k.ID)
+	}
+
+	result := db.Model(keyVersion).Updates(map[string]interface{}{
+		"seed":          gorm.Expr("NULL"),
+		"public_key":    gorm.Expr("NULL"),
+		"private_key":   gorm.Expr("NULL"),
+		"master_key_id": gorm.Expr("NULL"),
+		"destroyed_at":  time.Now(),
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to destroy version %d of key: %s; %s", version, k.ID, result.Error.Error())
+	}
+
+	common.Log.Debugf("destroyed version %d of key %s in vault %s", version, k.ID, k.VaultID)
+	return nil
+}
+
+// DecryptVersioned decrypts the ciphertext, authenticating the optional additional data,
+// using the given version of the key or, when no version is given, using the latest version
+// followed by each previous non-destroyed version; returns the plaintext and the version
+// which decrypted the ciphertext. The version is required for unauthenticated (i.e.,
+// ChaCha20) keys, for which decryption using the wrong version does not fail
+func (k *Key) DecryptVersioned(db *gorm.DB, ciphertext, aad []byte, version *int) ([]byte, int, error) {
+	return k.decryptVersioned(db, version, func(key *Key) ([]byte, error) {
+		return key.DecryptWithAAD(ciphertext, aad)
+	})
+}
+
+// hasAuthenticatedEncryption returns true if decryption using the key fails for ciphertext
+// which was not produced by the key, such that each version of the key may be tried in turn
+func (k *Key) hasAuthenticatedEncryption() bool {
+	if k.Spec == nil {
+		return false
+	}
+
+	switch *k.Spec {
+	case KeySpecAES256GCM, KeySpecXChaCha20Poly1305, KeySpecAES256SIV, KeySpecECCC25519, KeySpecECCSecp256k1, KeySpecRSA2048, KeySpecRSA3072, KeySpecRSA4096:
+		return true
+	}
+
+	return false
+}
+
+// requireCiphertextVersion returns an error if no version is given for ciphertext which
+// cannot be decrypted by trying each version of the key in turn; unauthenticated decryption
+// (i.e., ChaCha20) never fails, so the version must be given explicitly or by an envelope
+func (k *Key) requireCiphertextVersion(version *int) error {
+	if version == nil && !k.hasAuthenticatedEncryption() {
+		return fmt.Errorf("key version is required to decrypt unauthenticated %s ciphertext using key: %s; provide the version or a ciphertext envelope", *k.Spec, k.ID)
+	}
+	return nil
+}
+
+// decryptVersioned decrypts using the given decrypt func with the given version of the key
+// or, when no version is given, with the latest version followed by each previous
+// non-destroyed version; returns the plaintext and the version which decrypted it. The
+// version is required for keys without authenticated encryption
+func (k *Key) decryptVersioned(db *gorm.DB, version *int, decrypt func(key *Key) ([]byte, error)) ([]byte, int, error) {
+	err := k.requireCiphertextVersion(version)
+	if err != nil {
+		return nil, k.Version, err
+	}
+
+	if version != nil {
+		key, err := k.ResolveVersion(db, *version)
+		if err != nil {
+			return nil, *version, err
+		}
+
+		plaintext, err := decrypt(key)
+		return plaintext, *version, err
+	}
+
+	plaintext, err := decrypt(k)
+	if err == nil {
+		return plaintext, k.Version, nil
+	}
+
+	for _, key := range k.previousVersions(db) {
+		plaintext, verr := decrypt(key)
+		if verr == nil {
+			return plaintext, key.Version, nil
+		}
+	}
+
+	return nil, k.Version, err
+}
+
+// VerifyVersioned verifies the signature using the given version of the key or, when no
+// version is given, using the latest version followed by each previous non-destroyed
+// version; returns the version which verified the signature
+func (k *Key) VerifyVersioned(db *gorm.DB, payload, sig []byte, opts *SigningOptions, version *int) (int, error) {
+	return k.verifyVersioned(db, version, func(key *Key) error {
+		return key.Verify(payload, sig, opts)
+	})
+}
+
+// verifyVersioned verifies using the given verify func with the given version of the key
+// or, when no version is given, with the latest version followed by each previous
+// non-destroyed version; returns the version which verified
+func (k *Key) verifyVersioned(db *gorm.DB, version *int, verify func(key *Key) error) (int, error) {
+	if version != nil {
+		key, err := k.ResolveVersion(db, *version)
+		if err != nil {
+			return *version, err
+		}
+
+		return *version, verify(key)
+	}
+
+	err := verify(k)
+	if err == nil {
+		return k.Version, nil
+	}
+
+	for _, key := range k.previousVersions(db) {
+		if verify(key) == nil {
+			return key.Version, nil
+		}
+	}
+
+	return k.Version, err
+}
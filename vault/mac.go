@@ -0,0 +1,96 @@
+package vault
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+
+	"github.com/jinzhu/gorm"
+	"github.com/provideplatform/vault/crypto"
+)
+
+// KeyMACRequestResponse represents the API request/response parameters needed to compute
+// or verify the HMAC of an arbitrary message; the message is encoded using the optional
+// encoding (utf8, hex, base64 or base64url; defaults to hex) and the mac is always
+// hex-encoded; version identifies the key version which computed the mac
+type KeyMACRequestResponse struct {
+	Message  *string `json:"message,omitempty"`
+	MAC      *string `json:"mac,omitempty"`
+	Verified *bool   `json:"verified,omitempty"`
+	Version  *int    `json:"version,omitempty"`
+	Encoding *string `json:"encoding,omitempty"`
+}
+
+// isHMAC returns true if the key is a HMAC key
+func (k *Key) isHMAC() bool {
+	return k.Spec != nil && (*k.Spec == KeySpecHMACSHA256 || *k.Spec == KeySpecHMACSHA384 || *k.Spec == KeySpecHMACSHA512)
+}
+
+// hmacHash returns the hash function of the HMAC key spec
+func (k *Key) hmacHash() func() hash.Hash {
+	switch *k.Spec {
+	case KeySpecHMACSHA384:
+		return sha512.New384
+	case KeySpecHMACSHA512:
+		return sha512.New
+	}
+	return sha256.New
+}
+
+// MAC computes the HMAC of the given payload using the key
+func (k *Key) MAC(payload []byte) ([]byte, error) {
+	if !k.isHMAC() {
+		return nil, fmt.Errorf("failed to compute mac of %d-byte payload using key: %s; nil or invalid key spec", len(payload), k.ID)
+	}
+
+	if k.PrivateKey == nil {
+		return nil, fmt.Errorf("failed to compute mac of %d-byte payload using key: %s; nil private key", len(payload), k.ID)
+	}
+
+	k.decryptFields()
+	defer k.encryptFields()
+
+	hmac := crypto.HMAC{
+		PrivateKey: *k.PrivateKey,
+		Hash:       k.hmacHash(),
+	}
+
+	mac, err := hmac.MAC(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute mac of %d-byte payload using key: %s; %s", len(payload), k.ID, err.Error())
+	}
+
+	return mac, nil
+}
+
+// VerifyMAC verifies the HMAC of the given payload using the key; the mac is compared
+// in constant time
+func (k *Key) VerifyMAC(payload, mac []byte) error {
+	if !k.isHMAC() {
+		return fmt.Errorf("failed to verify mac of %d-byte payload using key: %s; nil or invalid key spec", len(payload), k.ID)
+	}
+
+	if k.PrivateKey == nil {
+		return fmt.Errorf("failed to verify mac of %d-byte payload using key: %s; nil private key", len(payload), k.ID)
+	}
+
+	k.decryptFields()
+	defer k.encryptFields()
+
+	hmac := crypto.HMAC{
+		PrivateKey: *k.PrivateKey,
+		Hash:       k.hmacHash(),
+	}
+
+	return hmac.Verify(payload, mac)
+}
+
+// VerifyMACVersioned verifies the HMAC using the given version of the key or, when no
+// version is given, using the latest version followed by each previous non-destroyed
+// version; returns the version which verified the mac
+func (k *Key) VerifyMACVersioned(db *gorm.DB, payload, mac []byte, version *int) (int, error) {
+	return k.verifyVersioned(db, version, func(key *Key) error {
+		return key.VerifyMAC(payload, mac)
+	})
+}
@@ -0,0 +1,545 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	dbconf "github.com/kthomas/go-db-config"
+	uuid "github.com/kthomas/go.uuid"
+	provide "github.com/provideplatform/provide-go/api"
+	"github.com/provideplatform/vault/common"
+	"github.com/provideplatform/vault/crypto"
+)
+
+// masterKeyNamePrefix is the prefix of the name of each vault master key version (i.e., master0)
+const masterKeyNamePrefix = "master"
+
+// masterKeyRewrapBatchSize is the number of keys or secrets re-wrapped per transaction
+const masterKeyRewrapBatchSize = 100
+
+// masterKeyRewrapInterval is the interval at which pending master key re-wraps are resumed
+const masterKeyRewrapInterval = time.Minute
+
+// masterKeyRewrapsInProgress tracks the vaults being re-wrapped by this instance
+var masterKeyRewrapsInProgress sync.Map
+
+// MasterKeyVersion is a version of the master key of a vault; the key material of each key
+// and secret in the vault is wrapped by exactly one master key version, and only the active
+// version (i.e., the vault master key) is used to wrap new key material
+type MasterKeyVersion struct {
+	provide.Model
+	VaultID   *uuid.UUID `sql:"not null;type:uuid" json:"vault_id"`
+	KeyID     *uuid.UUID `sql:"not null;type:uuid" json:"-"`
+	Version   int        `sql:"not null" json:"version"`
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+}
+
+// masterKeyName returns the name of the given master key version
+func masterKeyName(version int) string {
+	return fmt.Sprintf("%s%d", masterKeyNamePrefix, version)
+}
+
+// createMasterKeyVersion creates the given master key version and marks it active
+func (v *Vault) createMasterKeyVersion(tx *gorm.DB, version int) (*MasterKeyVersion, error) {
+	masterKey := &Key{
+		VaultID:     &v.ID,
+		Type:        common.StringOrNil(KeyTypeSymmetric),
+		Usage:       common.StringOrNil(KeyUsageEncryptDecrypt),
+		Spec:        common.StringOrNil(KeySpecAES256GCM),
+		Name:        common.StringOrNil(masterKeyName(version)),
+		Description: common.StringOrNil(fmt.Sprintf("AES-256-GCM master key for vault %s", v.ID)),
+		isMaster:    true,
+	}
+
+	if !masterKey.createPersisted(tx) {
+		return nil, fmt.Errorf("failed to create master key for vault: %s; %s", v.ID, *masterKey.Errors[0].Message)
+	}
+
+	masterKeyVersion := &MasterKeyVersion{
+		VaultID: &v.ID,
+		KeyID:   &masterKey.ID,
+		Version: version,
+	}
+
+	result := tx.Create(&masterKeyVersion)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to create master key version %d for vault: %s; %s", version, v.ID, result.Error.Error())
+	}
+
+	v.MasterKey = masterKey
+	v.MasterKeyID = &masterKey.ID
+	result = tx.Save(&v)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to activate master key version %d for vault: %s; %s", version, v.ID, result.Error.Error())
+	}
+
+	common.Log.Debugf("created master key %s (version %d) for vault: %s", masterKey.ID, version, v.ID)
+	return masterKeyVersion, nil
+}
+
+// RotateMasterKey creates a new master key version for the vault and marks it active; key
+// material wrapped by previous versions remains decryptable and is re-wrapped using the
+// new version in the background, after which each previous version is retired
+func (v *Vault) RotateMasterKey(db *gorm.DB) (*MasterKeyVersion, error) {
+	if vaultIsSealed() {
+		return nil, fmt.Errorf("vault is sealed")
+	}
+
+	tx := db.Begin()
+	defer tx.RollbackUnlessCommitted()
+
+	// lock the vault row so concurrent rotations are serialized
+	vlt := &Vault{}
+	tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", v.ID).Find(&vlt)
+	if vlt == nil || vlt.ID == uuid.Nil {
+		return nil, fmt.Errorf("failed to rotate master key; vault not found: %s", v.ID)
+	}
+
+	current := &MasterKeyVersion{}
+	tx.Where("vault_id = ?", v.ID).Order("version DESC").Limit(1).Find(&current)
+	if current.ID == uuid.Nil {
+		return nil, fmt.Errorf("failed to rotate master key; no master key version found for vault: %s", v.ID)
+	}
+
+	masterKeyVersion, err := vlt.createMasterKeyVersion(tx, current.Version+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate master key; %s", err.Error())
+	}
+
+	result := tx.Commit()
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to rotate master key for vault: %s; %s", v.ID, result.Error.Error())
+	}
+
+	v.MasterKey = vlt.MasterKey
+	v.MasterKeyID = vlt.MasterKeyID
+
+	common.Log.Debugf("rotated master key for vault: %s; active version: %d", v.ID, masterKeyVersion.Version)
+
+	go func() {
+		err := rewrapVaultMasterKeyVersions(dbconf.DatabaseConnection(), v.ID)
+		if err != nil {
+			common.Log.Warningf("failed to re-wrap key material for vault: %s; %s", v.ID, err.Error())
+		}
+	}()
+
+	return masterKeyVersion, nil
+}
+
+// resolveMasterKeyVersion resolves the given master key version of the vault
+func (v *Vault) resolveMasterKeyVersion(db *gorm.DB, masterKeyID uuid.UUID) (*Key, error) {
+	if v.MasterKeyID != nil && *v.MasterKeyID == masterKeyID {
+		return v.resolveMasterKey(db)
+	}
+
+	masterKey := &Key{}
+	db.Where("keys.id = ? AND keys.vault_id = ?", masterKeyID, v.ID).Find(&masterKey)
+	if masterKey == nil || masterKey.ID == uuid.Nil {
+		return nil, fmt.Errorf("failed to resolve master key %s for vault: %s", masterKeyID, v.ID)
+	}
+	masterKey.setEncrypted(true)
+	masterKey.isMaster = true
+	masterKey.vault = v
+
+	return masterKey, nil
+}
+
+// isMasterKeyVersion returns true if the given key is a master key version of the vault
+func (v *Vault) isMasterKeyVersion(db *gorm.DB, keyID uuid.UUID) bool {
+	if v.MasterKeyID != nil && *v.MasterKeyID == keyID {
+		return true
+	}
+
+	count := 0
+	db.Model(&MasterKeyVersion{}).Where("vault_id = ? AND key_id = ?", v.ID, keyID).Count(&count)
+	return count > 0
+}
+
+// RunMasterKeyRewrap periodically resumes the re-wrap of key material wrapped by previous
+// master key versions of each vault, i.e., following a restart or while this instance was
+// sealed; it returns once the given context is done
+func RunMasterKeyRewrap(ctx context.Context) {
+	timer := time.NewTicker(masterKeyRewrapInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if vaultIsSealed() {
+				continue
+			}
+
+			db := dbconf.DatabaseConnection()
+
+			var vaultIDs []uuid.UUID
+			db.Table("master_key_versions").
+				Joins("inner join vaults on vaults.id = master_key_versions.vault_id").
+				Where("master_key_versions.retired_at IS NULL AND master_key_versions.key_id <> vaults.master_key_id").
+				Pluck("DISTINCT master_key_versions.vault_id", &vaultIDs)
+
+			for _, vaultID := range vaultIDs {
+				err := rewrapVaultMasterKeyVersions(db, vaultID)
+				if err != nil {
+					common.Log.Warningf("failed to re-wrap key material for vault: %s; %s", vaultID, err.Error())
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// rewrapVaultMasterKeyVersions re-wraps the key material of every key, secret and pending key
+// import in the vault which is wrapped by a previous master key version using the active master
+// key version; each previous version is retired once no key material remains wrapped by it
+func rewrapVaultMasterKeyVersions(db *gorm.DB, vaultID uuid.UUID) error {
+	if _, inProgress := masterKeyRewrapsInProgress.LoadOrStore(vaultID.String(), true); inProgress {
+		common.Log.Debugf("re-wrap of key material already in progress for vault: %s", vaultID)
+		return nil
+	}
+	defer masterKeyRewrapsInProgress.Delete(vaultID.String())
+
+	if vaultIsSealed() {
+		return fmt.Errorf("vault is sealed")
+	}
+
+	vlt := &Vault{}
+	db.Where("id = ?", vaultID).Find(&vlt)
+	if vlt == nil || vlt.ID == uuid.Nil {
+		return fmt.Errorf("vault not found: %s", vaultID)
+	}
+
+	activeMasterKey, err := vlt.resolveMasterKey(db)
+	if err != nil {
+		return err
+	}
+
+	var versions []*MasterKeyVersion
+	result := db.Where("vault_id = ? AND retired_at IS NULL AND key_id <> ?", vlt.ID, activeMasterKey.ID).Order("version ASC").Find(&versions)
+	if result.Error != nil {
+		return fmt.Errorf("failed to resolve master key versions to retire for vault: %s; %s", vlt.ID, result.Error.Error())
+	}
+
+	for _, version := range versions {
+		masterKey, err := vlt.resolveMasterKeyVersion(db, *version.KeyID)
+		if err != nil {
+			return err
+		}
+
+		keys, err := rewrapKeys(db, vlt, masterKey, activeMasterKey)
+		if err != nil {
+			return fmt.Errorf("failed to re-wrap keys wrapped by master key version %d; %s", version.Version, err.Error())
+		}
+
+		keyVersions, err := rewrapKeyVersions(db, vlt, masterKey, activeMasterKey)
+		if err != nil {
+			return fmt.Errorf("failed to re-wrap key versions wrapped by master key version %d; %s", version.Version, err.Error())
+		}
+
+		secrets, err := rewrapSecrets(db, vlt, masterKey, activeMasterKey)
+		if err != nil {
+			return fmt.Errorf("failed to re-wrap secrets wrapped by master key version %d; %s", version.Version, err.Error())
+		}
+
+		keyImports, err := rewrapKeyImports(db, vlt, masterKey, activeMasterKey)
+		if err != nil {
+			return fmt.Errorf("failed to re-wrap key import wrapping keys wrapped by master key version %d; %s", version.Version, err.Error())
+		}
+
+		common.Log.Debugf("re-wrapped %d key(s), %d key version(s), %d secret(s) and %d key import(s) from master key version %d for vault: %s", keys, keyVersions, secrets, keyImports, version.Version, vlt.ID)
+
+		// the master key version is only retired once it is known that it no longer wraps any
+		// key material; it is skipped, and revisited by the next re-wrap, if a count fails
+		remaining := 0
+		result := db.Model(&Key{}).Where("vault_id = ? AND master_key_id = ?", vlt.ID, masterKey.ID).Count(&remaining)
+		if result.Error == nil && remaining == 0 {
+			result = db.Model(&KeyVersion{}).Where("master_key_id = ?", masterKey.ID).Count(&remaining)
+		}
+		if result.Error == nil && remaining == 0 {
+			result = db.Model(&Secret{}).Where("vault_id = ? AND master_key_id = ?", vlt.ID, masterKey.ID).Count(&remaining)
+		}
+		if result.Error == nil && remaining == 0 {
+			result = db.Model(&KeyImport{}).Where(pendingKeyImportsQuery, vlt.ID, masterKey.ID, time.Now()).Count(&remaining)
+		}
+
+		if result.Error != nil {
+			common.Log.Warningf("not retiring master key version %d for vault: %s; failed to count remaining wrapped key material; %s", version.Version, vlt.ID, result.Error.Error())
+			continue
+		}
+
+		if remaining > 0 {
+			common.Log.Debugf("not retiring master key version %d for vault: %s; %d key(s), secret(s) or key import(s) remain wrapped", version.Version, vlt.ID, remaining)
+			continue
+		}
+
+		retiredAt := time.Now()
+		result = db.Model(version).Update("retired_at", retiredAt)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retire master key version %d; %s", version.Version, result.Error.Error())
+		}
+
+		common.Log.Debugf("retired master key version %d for vault: %s", version.Version, vlt.ID)
+	}
+
+	return nil
+}
+
+// rewrapKeys re-wraps the seed and private key of each key in the vault which is wrapped by
+// the given master key version using the active master key, in batches ordered by key id;
+// returns the number of keys which were re-wrapped
+func rewrapKeys(db *gorm.DB, vlt *Vault, masterKey, activeMasterKey *Key) (int, error) {
+	rewrapped := 0
+	lastID := uuid.Nil
+
+	for {
+		var keys []*Key
+		result := db.Select("keys.id, keys.seed, keys.private_key").
+			Where("keys.vault_id = ? AND keys.master_key_id = ? AND keys.id > ?", vlt.ID, masterKey.ID, lastID).
+			Order("keys.id ASC").
+			Limit(masterKeyRewrapBatchSize).
+			Find(&keys)
+		if result.Error != nil {
+			return rewrapped, fmt.Errorf("failed to resolve keys to re-wrap; %s", result.Error.Error())
+		}
+
+		if len(keys) == 0 {
+			break
+		}
+
+		tx := db.Begin()
+		for _, key := range keys {
+			updates := map[string]interface{}{
+				"master_key_id": activeMasterKey.ID,
+			}
+
+			if key.Seed != nil {
+				seed, err := rewrapKeyMaterial(*key.Seed, masterKey, activeMasterKey)
+				if err != nil {
+					tx.Rollback()
+					return rewrapped, fmt.Errorf("failed to re-wrap seed of key %s; %s", key.ID, err.Error())
+				}
+				updates["seed"] = seed
+			}
+
+			if key.PrivateKey != nil {
+				privateKey, err := rewrapKeyMaterial(*key.PrivateKey, masterKey, activeMasterKey)
+				if err != nil {
+					tx.Rollback()
+					return rewrapped, fmt.Errorf("failed to re-wrap private key of key %s; %s", key.ID, err.Error())
+				}
+				updates["private_key"] = privateKey
+			}
+
+			result := tx.Model(&Key{}).Where("id = ? AND master_key_id = ?", key.ID, masterKey.ID).Updates(updates)
+			if result.Error != nil {
+				tx.Rollback()
+				return rewrapped, fmt.Errorf("failed to persist re-wrapped key %s; %s", key.ID, result.Error.Error())
+			}
+			rewrapped += int(result.RowsAffected)
+
+			lastID = key.ID
+		}
+
+		result = tx.Commit()
+		if result.Error != nil {
+			return rewrapped, fmt.Errorf("failed to commit re-wrapped keys; %s", result.Error.Error())
+		}
+	}
+
+	return rewrapped, nil
+}
+
+// rewrapKeyVersions re-wraps the seed and private key of each previous key version in the
+// vault which is wrapped by the given master key version using the active master key, in
+// batches ordered by key version id; returns the number of key versions which were re-wrapped
+func rewrapKeyVersions(db *gorm.DB, vlt *Vault, masterKey, activeMasterKey *Key) (int, error) {
+	rewrapped := 0
+	lastID := uuid.Nil
+
+	for {
+		var keyVersions []*KeyVersion
+		result := db.Select("key_versions.id, key_versions.seed, key_versions.private_key").
+			Where("key_versions.master_key_id = ? AND key_versions.id > ?", masterKey.ID, lastID).
+			Order("key_versions.id ASC").
+			Limit(masterKeyRewrapBatchSize).
+			Find(&keyVersions)
+		if result.Error != nil {
+			return rewrapped, fmt.Errorf("failed to resolve key versions to re-wrap; %s", result.Error.Error())
+		}
+
+		if len(keyVersions) == 0 {
+			break
+		}
+
+		tx := db.Begin()
+		for _, keyVersion := range keyVersions {
+			updates := map[string]interface{}{
+				"master_key_id": activeMasterKey.ID,
+			}
+
+			if keyVersion.Seed != nil {
+				seed, err := rewrapKeyMaterial(*keyVersion.Seed, masterKey, activeMasterKey)
+				if err != nil {
+					tx.Rollback()
+					return rewrapped, fmt.Errorf("failed to re-wrap seed of key version %s; %s", keyVersion.ID, err.Error())
+				}
+				updates["seed"] = seed
+			}
+
+			if keyVersion.PrivateKey != nil {
+				privateKey, err := rewrapKeyMaterial(*keyVersion.PrivateKey, masterKey, activeMasterKey)
+				if err != nil {
+					tx.Rollback()
+					return rewrapped, fmt.Errorf("failed to re-wrap private key of key version %s; %s", keyVersion.ID, err.Error())
+				}
+				updates["private_key"] = privateKey
+			}
+
+			result := tx.Model(&KeyVersion{}).Where("id = ? AND master_key_id = ?", keyVersion.ID, masterKey.ID).Updates(updates)
+			if result.Error != nil {
+				tx.Rollback()
+				return rewrapped, fmt.Errorf("failed to persist re-wrapped key version %s; %s", keyVersion.ID, result.Error.Error())
+			}
+			rewrapped += int(result.RowsAffected)
+
+			lastID = keyVersion.ID
+		}
+
+		result = tx.Commit()
+		if result.Error != nil {
+			return rewrapped, fmt.Errorf("failed to commit re-wrapped key versions; %s", result.Error.Error())
+		}
+	}
+
+	return rewrapped, nil
+}
+
+// rewrapSecrets re-wraps the value of each secret in the vault which is wrapped by the given
+// master key version using the active master key, in batches ordered by secret id; returns
+// the number of secrets which were re-wrapped
+func rewrapSecrets(db *gorm.DB, vlt *Vault, masterKey, activeMasterKey *Key) (int, error) {
+	rewrapped := 0
+	lastID := uuid.Nil
+
+	for {
+		var secrets []*Secret
+		result := db.Select("secrets.id, secrets.value").
+			Where("secrets.vault_id = ? AND secrets.master_key_id = ? AND secrets.id > ?", vlt.ID, masterKey.ID, lastID).
+			Order("secrets.id ASC").
+			Limit(masterKeyRewrapBatchSize).
+			Find(&secrets)
+		if result.Error != nil {
+			return rewrapped, fmt.Errorf("failed to resolve secrets to re-wrap; %s", result.Error.Error())
+		}
+
+		if len(secrets) == 0 {
+			break
+		}
+
+		tx := db.Begin()
+		for _, secret := range secrets {
+			updates := map[string]interface{}{
+				"master_key_id": activeMasterKey.ID,
+			}
+
+			if secret.Value != nil {
+				value, err := rewrapKeyMaterial(*secret.Value, masterKey, activeMasterKey)
+				if err != nil {
+					tx.Rollback()
+					return rewrapped, fmt.Errorf("failed to re-wrap secret %s; %s", secret.ID, err.Error())
+				}
+				updates["value"] = value
+			}
+
+			result := tx.Model(&Secret{}).Where("id = ? AND master_key_id = ?", secret.ID, masterKey.ID).Updates(updates)
+			if result.Error != nil {
+				tx.Rollback()
+				return rewrapped, fmt.Errorf("failed to persist re-wrapped secret %s; %s", secret.ID, result.Error.Error())
+			}
+			rewrapped += int(result.RowsAffected)
+
+			lastID = secret.ID
+		}
+
+		result = tx.Commit()
+		if result.Error != nil {
+			return rewrapped, fmt.Errorf("failed to commit re-wrapped secrets; %s", result.Error.Error())
+		}
+	}
+
+	return rewrapped, nil
+}
+
+// pendingKeyImportsQuery selects the key imports of a vault which are wrapped by the given master key
+// version and may still be used, i.e., which have neither expired nor been used to import key material
+const pendingKeyImportsQuery = "key_imports.vault_id = ? AND key_imports.master_key_id = ? AND key_imports.imported_at IS NULL AND key_imports.private_key IS NOT NULL AND key_imports.expires_at > ?"
+
+// rewrapKeyImports re-wraps the private key of the wrapping key of each pending key import in
+// the vault which is wrapped by the given master key version using the active master key, in
+// batches ordered by key import id; returns the number of key imports which were re-wrapped
+func rewrapKeyImports(db *gorm.DB, vlt *Vault, masterKey, activeMasterKey *Key) (int, error) {
+	rewrapped := 0
+	lastID := uuid.Nil
+
+	for {
+		var keyImports []*KeyImport
+		result := db.Select("key_imports.id, key_imports.private_key").
+			Where(pendingKeyImportsQuery+" AND key_imports.id > ?", vlt.ID, masterKey.ID, time.Now(), lastID).
+			Order("key_imports.id ASC").
+			Limit(masterKeyRewrapBatchSize).
+			Find(&keyImports)
+		if result.Error != nil {
+			return rewrapped, fmt.Errorf("failed to resolve key imports to re-wrap; %s", result.Error.Error())
+		}
+
+		if len(keyImports) == 0 {
+			break
+		}
+
+		tx := db.Begin()
+		for _, keyImport := range keyImports {
+			privateKey, err := rewrapKeyMaterial(*keyImport.PrivateKey, masterKey, activeMasterKey)
+			if err != nil {
+				tx.Rollback()
+				return rewrapped, fmt.Errorf("failed to re-wrap wrapping key of key import %s; %s", keyImport.ID, err.Error())
+			}
+
+			// the key import is only re-wrapped if it has not been used concurrently
+			result := tx.Model(&KeyImport{}).Where("id = ? AND master_key_id = ? AND imported_at IS NULL", keyImport.ID, masterKey.ID).Updates(map[string]interface{}{
+				"master_key_id": activeMasterKey.ID,
+				"private_key":   privateKey,
+			})
+			if result.Error != nil {
+				tx.Rollback()
+				return rewrapped, fmt.Errorf("failed to persist re-wrapped key import %s; %s", keyImport.ID, result.Error.Error())
+			}
+			rewrapped += int(result.RowsAffected)
+
+			lastID = keyImport.ID
+		}
+
+		result = tx.Commit()
+		if result.Error != nil {
+			return rewrapped, fmt.Errorf("failed to commit re-wrapped key imports; %s", result.Error.Error())
+		}
+	}
+
+	return rewrapped, nil
+}
+
+// rewrapKeyMaterial decrypts the given material using the given master key version
+// and encrypts it using the active master key
+func rewrapKeyMaterial(wrapped []byte, masterKey, activeMasterKey *Key) ([]byte, error) {
+	plaintext, err := masterKey.Decrypt(wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	// wipe the plaintext in memory before garbage collection
+	defer crypto.WipeBytes(plaintext)
+
+	return activeMasterKey.Encrypt(plaintext, nil)
+}
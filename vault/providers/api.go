@@ -14,13 +14,38 @@ const SealUnsealKeyProviderAzureKeyVault = "azure_key_vault"
 // SealUnsealKeyProviderDocker docker unseal provider
 const SealUnsealKeyProviderDocker = "docker"
 
+// SealUnsealKeyProviderKeyfile passphrase-protected keyfile unseal provider
+const SealUnsealKeyProviderKeyfile = "keyfile"
+
+// SealUnsealKeyProviderPKCS11 PKCS#11 (i.e., HSM or SoftHSM) unseal provider
+const SealUnsealKeyProviderPKCS11 = "pkcs11"
+
+// SealUnsealKeyProviderTransit transit secrets engine (i.e., HashiCorp Vault) unseal provider
+const SealUnsealKeyProviderTransit = "transit"
+
 // SealUnsealKeyProviderEnvironment environment variable unseal provider
 const SealUnsealKeyProviderEnvironment = "environment"
 
+// ErrRekeyNotSupported is returned by seal/unseal providers which cannot persist a rekeyed
+// unsealer key, i.e., because the unsealer key is read from the environment of the process
+var ErrRekeyNotSupported = errors.New("rekey not supported by seal/unseal provider; the unsealer key cannot be persisted")
+
 // SealUnsealKeyProvider interface
 type SealUnsealKeyProvider interface {
 	Seed() (*string, error)
 	ValidationHash() (*string, error)
+	Rekey(seed, validationHash string) error
+}
+
+// RekeySupported returns true if the named seal/unseal provider persists a rekeyed unsealer
+// key; the environment and docker providers read the unsealer key from the environment of
+// the process, which cannot be updated such that it survives a restart
+func RekeySupported(provider string) bool {
+	switch provider {
+	case SealUnsealKeyProviderEnvironment, SealUnsealKeyProviderDocker:
+		return false
+	}
+	return true
 }
 
 // InitUnsealProvider initializes a seal/unseal provider
@@ -43,6 +68,21 @@ func InitSealUnsealProvider(provider string, params map[string]interface{}) (Sea
 		if sealUnseal == nil {
 			return nil, errors.New("failed to initialize docker seal/unseal provider")
 		}
+	case SealUnsealKeyProviderKeyfile:
+		sealUnseal = InitKeyfileSealUnsealProvider(params)
+		if sealUnseal == nil {
+			return nil, errors.New("failed to initialize keyfile seal/unseal provider")
+		}
+	case SealUnsealKeyProviderPKCS11:
+		sealUnseal = InitPKCS11SealUnsealProvider(params)
+		if sealUnseal == nil {
+			return nil, errors.New("failed to initialize PKCS#11 seal/unseal provider")
+		}
+	case SealUnsealKeyProviderTransit:
+		sealUnseal = InitTransitSealUnsealProvider(params)
+		if sealUnseal == nil {
+			return nil, errors.New("failed to initialize transit seal/unseal provider")
+		}
 	case SealUnsealKeyProviderEnvironment:
 		sealUnseal = InitEnvironmentSealUnsealProvider(params)
 		if sealUnseal == nil {
@@ -5,43 +5,130 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"os"
 
+	"github.com/aws/aws-sdk-go/aws"
+	awscredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
 	provide "github.com/provideplatform/provide-go/api/c2"
 	"github.com/provideplatform/vault/common"
+	vaultcrypto "github.com/provideplatform/vault/crypto"
 )
 
-// AWSSealUnsealProvider implements the Unsealer interface
+// AWSSealUnsealProvider implements the Unsealer interface; the unsealer key is stored
+// as a ciphertext blob encrypted under the configured AWS KMS key and is decrypted
+// using AWS KMS when the seed is requested
 type AWSSealUnsealProvider struct {
 	region          string
 	accessKeyID     string
 	secretAccessKey string
+	endpoint        string
+	kmsKeyID        string
 
-	unsealKeySecretName string
+	unsealKeyCiphertext     string
+	unsealKeyCiphertextPath string
 }
 
 // InitAWSSealUnsealProvider initializes and returns the AWS KMS Unseal provider
 func InitAWSSealUnsealProvider(params map[string]interface{}) *AWSSealUnsealProvider {
-	// credentials, credentialsOk := params["credentials"].(map[string]interface{})
+	credentials, credentialsOk := params["credentials"].(map[string]interface{})
+	if !credentialsOk && os.Getenv("SEAL_UNSEAL_AWS_ACCESS_KEY_ID") != "" && os.Getenv("SEAL_UNSEAL_AWS_SECRET_ACCESS_KEY") != "" {
+		credentials = map[string]interface{}{
+			"aws_access_key_id":     os.Getenv("SEAL_UNSEAL_AWS_ACCESS_KEY_ID"),
+			"aws_secret_access_key": os.Getenv("SEAL_UNSEAL_AWS_SECRET_ACCESS_KEY"),
+		}
+	}
+
 	region, regionOk := params["region"].(string)
+	if !regionOk && os.Getenv("SEAL_UNSEAL_VAULT_REGION") != "" {
+		region = os.Getenv("SEAL_UNSEAL_VAULT_REGION")
+		regionOk = true
+	}
+
 	if !regionOk {
-		common.Log.Warning("failed to initialize AWS provider; credentials and region are required")
+		common.Log.Warning("failed to initialize AWS provider; region is required")
 		return nil
 	}
 
-	// TODO-- determine how to best handle privileged execution setup
+	// when static credentials are not provided, the default AWS credential chain
+	// (i.e., environment, shared credentials or instance/task role) is used
+	accessKeyID, _ := credentials["aws_access_key_id"].(string)
+	secretAccessKey, _ := credentials["aws_secret_access_key"].(string)
+
+	endpoint, endpointOk := params["endpoint"].(string)
+	if !endpointOk {
+		endpoint = os.Getenv("SEAL_UNSEAL_AWS_ENDPOINT")
+	}
+
+	kmsKeyID, kmsKeyIDOk := params["kms_key_id"].(string)
+	if !kmsKeyIDOk {
+		kmsKeyID = os.Getenv("SEAL_UNSEAL_AWS_KMS_KEY_ID")
+	}
+
+	unsealKeyCiphertext := os.Getenv("SEAL_UNSEAL_KEY_CIPHERTEXT")
+	unsealKeyCiphertextPath := os.Getenv("SEAL_UNSEAL_KEY_CIPHERTEXT_PATH")
+
+	if unsealKeyCiphertext == "" && (kmsKeyID == "" || unsealKeyCiphertextPath == "") {
+		common.Log.Warning("failed to initialize AWS provider; SEAL_UNSEAL_KEY_CIPHERTEXT or SEAL_UNSEAL_AWS_KMS_KEY_ID and SEAL_UNSEAL_KEY_CIPHERTEXT_PATH are required")
+		return nil
+	}
 
 	return &AWSSealUnsealProvider{
-		region: region,
+		region:                  region,
+		accessKeyID:             accessKeyID,
+		secretAccessKey:         secretAccessKey,
+		endpoint:                endpoint,
+		kmsKeyID:                kmsKeyID,
+		unsealKeyCiphertext:     unsealKeyCiphertext,
+		unsealKeyCiphertextPath: unsealKeyCiphertextPath,
 	}
 }
 
 func (p *AWSSealUnsealProvider) targetCredentials() *provide.TargetCredentials {
-	// TODO
-	return nil
+	if p.accessKeyID == "" || p.secretAccessKey == "" {
+		return nil
+	}
+
+	return &provide.TargetCredentials{
+		AWSAccessKeyID:     common.StringOrNil(p.accessKeyID),
+		AWSSecretAccessKey: common.StringOrNil(p.secretAccessKey),
+	}
 }
 
 func (p *AWSSealUnsealProvider) Seed() (*string, error) {
-	return nil, errors.New("AWS KMS provider not implemented")
+	ciphertext, err := p.fetchCiphertext()
+	if err != nil {
+		// a new vault seed is only created when no ciphertext exists; any other error
+		// is returned, as the existing ciphertext may be the only sealed copy of the
+		// unsealer key
+		if err != errSealedSeedNotFound {
+			return nil, fmt.Errorf("failed to resolve vault seed ciphertext for configured AWS KMS key; %s", err.Error())
+		}
+
+		common.Log.Debugf("vault seed ciphertext not found: %s; generating vault seed using configured AWS KMS key", p.unsealKeyCiphertextPath)
+		ciphertext, err = p.createCiphertext()
+		if err != nil {
+			common.Log.Warningf("failed to create vault seed using configured AWS KMS key; %s", err.Error())
+			return nil, err
+		}
+	}
+
+	client, err := p.kmsClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Decrypt(&kms.DecryptInput{
+		CiphertextBlob: ciphertext,
+		KeyId:          p.keyID(),
+	})
+	if err != nil {
+		common.Log.Warningf("failed to decrypt vault seed using configured AWS KMS key; %s", err.Error())
+		return nil, err
+	}
+
+	return common.StringOrNil(string(resp.Plaintext)), nil
 }
 
 func (p *AWSSealUnsealProvider) ValidationHash() (*string, error) {
@@ -58,3 +145,103 @@ func (p *AWSSealUnsealProvider) ValidationHash() (*string, error) {
 
 	return common.StringOrNil(fmt.Sprintf("0x%s", hex.EncodeToString(hash.Sum(nil)))), nil
 }
+
+func (p *AWSSealUnsealProvider) Rekey(seed, validationHash string) error {
+	_, err := p.setCiphertext(seed, true)
+	if err != nil {
+		return fmt.Errorf("failed to rekey seal/unseal provider using configured AWS KMS instance; %s", err.Error())
+	}
+
+	if p.unsealKeyCiphertextPath == "" {
+		common.Log.Warning("rekeyed AWS KMS seal/unseal provider for the running process; SEAL_UNSEAL_KEY_CIPHERTEXT must be updated in the environment of every vault instance")
+	}
+
+	return nil
+}
+
+// createCiphertext generates a new vault seed and persists it encrypted under the configured AWS KMS key
+func (p *AWSSealUnsealProvider) createCiphertext() ([]byte, error) {
+	if p.kmsKeyID == "" || p.unsealKeyCiphertextPath == "" {
+		return nil, errors.New("SEAL_UNSEAL_AWS_KMS_KEY_ID and SEAL_UNSEAL_KEY_CIPHERTEXT_PATH are required to create vault seed")
+	}
+
+	key, err := vaultcrypto.CreateHDWalletWithEntropy(vaultcrypto.DefaultHDWalletSeedEntropy)
+	if err != nil {
+		common.Log.Warning(fmt.Sprintf("failed to generate hd wallet seed; %s", err.Error()))
+		return nil, err
+	}
+
+	return p.setCiphertext(string(key.Seed), false)
+}
+
+// setCiphertext encrypts the given seed under the configured AWS KMS key and persists
+// the resulting ciphertext blob; replace must be true to replace an existing ciphertext
+func (p *AWSSealUnsealProvider) setCiphertext(seed string, replace bool) ([]byte, error) {
+	if p.kmsKeyID == "" {
+		return nil, errors.New("SEAL_UNSEAL_AWS_KMS_KEY_ID is required to encrypt vault seed")
+	}
+
+	client, err := p.kmsClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Encrypt(&kms.EncryptInput{
+		KeyId:     aws.String(p.kmsKeyID),
+		Plaintext: []byte(seed),
+	})
+	if err != nil {
+		common.Log.Warning(fmt.Sprintf("failed to encrypt vault seed using AWS KMS; %s", err.Error()))
+		return nil, err
+	}
+
+	persist := persistSealedSeed
+	if replace {
+		persist = replaceSealedSeed
+	}
+
+	encodedCiphertext, err := persist(resp.CiphertextBlob, p.unsealKeyCiphertextPath)
+	if err != nil {
+		return nil, err
+	}
+	p.unsealKeyCiphertext = encodedCiphertext
+
+	return resp.CiphertextBlob, nil
+}
+
+// fetchCiphertext resolves the ciphertext blob from the environment or, if not present,
+// from the configured ciphertext path
+func (p *AWSSealUnsealProvider) fetchCiphertext() ([]byte, error) {
+	return fetchSealedSeed(p.unsealKeyCiphertext, p.unsealKeyCiphertextPath)
+}
+
+// keyID returns the configured AWS KMS key id, if any; the key id is optional
+// for decryption of symmetric ciphertext
+func (p *AWSSealUnsealProvider) keyID() *string {
+	if p.kmsKeyID == "" {
+		return nil
+	}
+	return aws.String(p.kmsKeyID)
+}
+
+// kmsClient returns an AWS KMS client for the configured region, credentials and endpoint;
+// the endpoint may be set to target a local KMS-compatible emulator
+func (p *AWSSealUnsealProvider) kmsClient() (*kms.KMS, error) {
+	cfg := aws.NewConfig().WithRegion(p.region)
+
+	if creds := p.targetCredentials(); creds != nil && creds.IsValidAWSCredentials() {
+		cfg = cfg.WithCredentials(awscredentials.NewStaticCredentials(*creds.AWSAccessKeyID, *creds.AWSSecretAccessKey, ""))
+	}
+
+	if p.endpoint != "" {
+		cfg = cfg.WithEndpoint(p.endpoint)
+	}
+
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		common.Log.Warning(fmt.Sprintf("failed to resolve AWS session; %s", err.Error()))
+		return nil, err
+	}
+
+	return kms.New(sess), nil
+}
@@ -120,20 +120,32 @@ func (p *AzureSealUnsealProvider) ValidationHash() (*string, error) {
 	return common.StringOrNil(fmt.Sprintf("0x%s", hex.EncodeToString(hash.Sum(nil)))), nil
 }
 
-func (p *AzureSealUnsealProvider) createSecretBundle() (*keyvault.SecretBundle, error) {
-	client, err := azurewrapper.NewKeyVaultClient(p.targetCredentials())
+func (p *AzureSealUnsealProvider) Rekey(seed, validationHash string) error {
+	_, err := p.setSecretBundle(seed)
 	if err != nil {
-		common.Log.Warning(fmt.Sprintf("failed to resolve Azure key vault client; %s", err.Error()))
-		return nil, err
+		return fmt.Errorf("failed to rekey seal/unseal provider using configured Azure key vault; %s", err.Error())
 	}
 
+	return nil
+}
+
+func (p *AzureSealUnsealProvider) createSecretBundle() (*keyvault.SecretBundle, error) {
 	key, err := vaultcrypto.CreateHDWalletWithEntropy(vaultcrypto.DefaultHDWalletSeedEntropy)
 	if err != nil {
 		common.Log.Warning(fmt.Sprintf("failed to generate hd wallet seed; %s", err.Error()))
 		return nil, err
 	}
 
-	seed := string(key.Seed)
+	return p.setSecretBundle(string(key.Seed))
+}
+
+func (p *AzureSealUnsealProvider) setSecretBundle(seed string) (*keyvault.SecretBundle, error) {
+	client, err := azurewrapper.NewKeyVaultClient(p.targetCredentials())
+	if err != nil {
+		common.Log.Warning(fmt.Sprintf("failed to resolve Azure key vault client; %s", err.Error()))
+		return nil, err
+	}
+
 	secret, err := client.SetSecret(context.TODO(), p.vaultBaseURL(), p.unsealKeySecretName, keyvault.SecretSetParameters{
 		Value: &seed,
 	})
@@ -0,0 +1,131 @@
+package providers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/provideplatform/vault/common"
+)
+
+// errSealedSeedNotFound is returned by fetchSealedSeed when no sealed seed has been
+// configured and the given path does not exist, i.e., a new vault seed may be created
+var errSealedSeedNotFound = errors.New("vault seed ciphertext not found")
+
+// fetchSealedSeed resolves the given base64-encoded sealed seed or, if not present,
+// reads it from the given path; errSealedSeedNotFound is returned only if no sealed
+// seed is given and the path does not exist, such that an absent sealed seed can be
+// distinguished from one which cannot be read or decoded
+func fetchSealedSeed(encodedCiphertext, path string) ([]byte, error) {
+	if encodedCiphertext == "" {
+		if path == "" {
+			return nil, errors.New("no vault seed ciphertext configured")
+		}
+
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, errSealedSeedNotFound
+			}
+			return nil, fmt.Errorf("failed to read vault seed ciphertext: %s; %s", path, err.Error())
+		}
+		encodedCiphertext = string(raw)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encodedCiphertext))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode vault seed ciphertext; %s", err.Error())
+	}
+
+	return ciphertext, nil
+}
+
+// persistSealedSeed writes the base64 encoding of the given sealed seed to the given
+// path, if any, and to the SEAL_UNSEAL_KEY_CIPHERTEXT variable of the running process;
+// an existing file is never overwritten, as it may hold the only sealed copy of the
+// unsealer key; returns the base64-encoded sealed seed
+func persistSealedSeed(ciphertext []byte, path string) (string, error) {
+	encodedCiphertext := base64.StdEncoding.EncodeToString(ciphertext)
+
+	if path != "" {
+		err := writeSealedSeedExclusive(path, []byte(encodedCiphertext))
+		if err != nil {
+			common.Log.Warning(fmt.Sprintf("failed to write vault seed ciphertext to %s; %s", path, err.Error()))
+			return "", err
+		}
+	}
+
+	os.Setenv("SEAL_UNSEAL_KEY_CIPHERTEXT", encodedCiphertext)
+	return encodedCiphertext, nil
+}
+
+// replaceSealedSeed atomically replaces the sealed seed at the given path, if any, with
+// the base64 encoding of the given sealed seed, i.e., when the unsealer key is rekeyed,
+// and sets the SEAL_UNSEAL_KEY_CIPHERTEXT variable of the running process; returns the
+// base64-encoded sealed seed
+func replaceSealedSeed(ciphertext []byte, path string) (string, error) {
+	encodedCiphertext := base64.StdEncoding.EncodeToString(ciphertext)
+
+	if path != "" {
+		err := writeSealedSeedAtomic(path, []byte(encodedCiphertext))
+		if err != nil {
+			common.Log.Warning(fmt.Sprintf("failed to write vault seed ciphertext to %s; %s", path, err.Error()))
+			return "", err
+		}
+	}
+
+	os.Setenv("SEAL_UNSEAL_KEY_CIPHERTEXT", encodedCiphertext)
+	return encodedCiphertext, nil
+}
+
+// writeSealedSeedExclusive writes the given encoded sealed seed to a new file at the given
+// path; fails if the file already exists
+func writeSealedSeedExclusive(path string, encodedCiphertext []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(encodedCiphertext)
+	if err == nil {
+		err = f.Sync()
+	}
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	return nil
+}
+
+// writeSealedSeedAtomic writes the given encoded sealed seed to a temporary file which
+// then atomically replaces the file at the given path
+func writeSealedSeedAtomic(path string, encodedCiphertext []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), fmt.Sprintf(".%s.", filepath.Base(path)))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(encodedCiphertext)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
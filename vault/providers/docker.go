@@ -36,6 +36,10 @@ func (p *DockerSealUnsealProvider) ValidationHash() (*string, error) {
 	return hash, nil
 }
 
+func (p *DockerSealUnsealProvider) Rekey(seed, validationHash string) error {
+	return ErrRekeyNotSupported
+}
+
 // getEnv gets environment data, including from docker secrets in-memory file system
 func getEnv(s string) (*string, error) {
 	// first check if it exists
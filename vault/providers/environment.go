@@ -31,3 +31,7 @@ func (p *EnvironmentSealUnsealProvider) ValidationHash() (*string, error) {
 
 	return &hash, nil
 }
+
+func (p *EnvironmentSealUnsealProvider) Rekey(seed, validationHash string) error {
+	return ErrRekeyNotSupported
+}
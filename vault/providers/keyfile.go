@@ -0,0 +1,439 @@
+package providers
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/provideplatform/vault/common"
+	vaultcrypto "github.com/provideplatform/vault/crypto"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// KeyfileKDFArgon2id argon2id keyfile key derivation function
+const KeyfileKDFArgon2id = "argon2id"
+
+// KeyfileKDFScrypt scrypt keyfile key derivation function
+const KeyfileKDFScrypt = "scrypt"
+
+const defaultKeyfilePassphraseCredential = "vault-keyfile-passphrase"
+
+const keyfileVersion = 1
+const keyfileSaltSize = 32
+
+// argon2id parameters used when writing a keyfile; per RFC 9106 second recommended option
+const keyfileArgon2idTime = 3
+const keyfileArgon2idMemory = 64 * 1024
+const keyfileArgon2idThreads = 4
+
+// scrypt parameters used when writing a keyfile
+const keyfileScryptN = 32768
+const keyfileScryptR = 8
+const keyfileScryptP = 1
+
+// keyfile is the on-disk representation of the passphrase-protected unsealer key
+type keyfile struct {
+	Version    int           `json:"version"`
+	KDF        string        `json:"kdf"`
+	KDFParams  keyfileParams `json:"kdf_params"`
+	Salt       []byte        `json:"salt"`
+	Ciphertext []byte        `json:"ciphertext"`
+}
+
+// keyfileParams are the key derivation function parameters used to derive the keyfile encryption key
+type keyfileParams struct {
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+	N       int    `json:"n,omitempty"`
+	R       int    `json:"r,omitempty"`
+	P       int    `json:"p,omitempty"`
+}
+
+// KeyfileSealUnsealProvider implements the Unsealer interface; the unsealer key is stored
+// in a file, encrypted using AES-256-GCM under a key derived from a passphrase using
+// argon2id or scrypt. The passphrase is read from a file descriptor, a systemd credential
+// or, if neither is configured, prompted for on the controlling terminal
+type KeyfileSealUnsealProvider struct {
+	path                 string
+	kdf                  string
+	passphraseFD         *int
+	passphraseCredential string
+
+	passphrase      []byte
+	passphraseMutex sync.Mutex
+}
+
+// InitKeyfileSealUnsealProvider initializes and returns the keyfile Unseal provider
+func InitKeyfileSealUnsealProvider(params map[string]interface{}) *KeyfileSealUnsealProvider {
+	path, pathOk := params["path"].(string)
+	if !pathOk {
+		path = os.Getenv("SEAL_UNSEAL_KEYFILE_PATH")
+	}
+
+	if path == "" {
+		common.Log.Warning("failed to initialize keyfile provider; SEAL_UNSEAL_KEYFILE_PATH is required")
+		return nil
+	}
+
+	kdf, kdfOk := params["kdf"].(string)
+	if !kdfOk {
+		kdf = os.Getenv("SEAL_UNSEAL_KEYFILE_KDF")
+	}
+
+	if kdf == "" {
+		kdf = KeyfileKDFArgon2id
+	}
+
+	if kdf != KeyfileKDFArgon2id && kdf != KeyfileKDFScrypt {
+		common.Log.Warningf("failed to initialize keyfile provider; unsupported key derivation function: %s", kdf)
+		return nil
+	}
+
+	var passphraseFD *int
+	if os.Getenv("SEAL_UNSEAL_KEYFILE_PASSPHRASE_FD") != "" {
+		fd, err := strconv.Atoi(os.Getenv("SEAL_UNSEAL_KEYFILE_PASSPHRASE_FD"))
+		if err != nil || fd < 0 {
+			common.Log.Warning("failed to initialize keyfile provider; invalid SEAL_UNSEAL_KEYFILE_PASSPHRASE_FD")
+			return nil
+		}
+		passphraseFD = &fd
+	}
+
+	passphraseCredential := defaultKeyfilePassphraseCredential
+	if os.Getenv("SEAL_UNSEAL_KEYFILE_PASSPHRASE_CREDENTIAL") != "" {
+		passphraseCredential = os.Getenv("SEAL_UNSEAL_KEYFILE_PASSPHRASE_CREDENTIAL")
+	}
+
+	provider := &KeyfileSealUnsealProvider{
+		path:                 path,
+		kdf:                  kdf,
+		passphraseFD:         passphraseFD,
+		passphraseCredential: passphraseCredential,
+	}
+
+	if passphrase, passphraseOk := params["passphrase"].(string); passphraseOk {
+		provider.passphrase = []byte(passphrase)
+	}
+
+	return provider
+}
+
+func (p *KeyfileSealUnsealProvider) Seed() (*string, error) {
+	raw, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read keyfile: %s; %s", p.path, err.Error())
+		}
+
+		common.Log.Debugf("keyfile not found: %s; generating vault seed", p.path)
+		seed, err := p.createSeed()
+		if err != nil {
+			common.Log.Warningf("failed to create vault seed in keyfile: %s; %s", p.path, err.Error())
+			return nil, err
+		}
+
+		return common.StringOrNil(string(seed)), nil
+	}
+
+	kf := &keyfile{}
+	err = json.Unmarshal(raw, &kf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse keyfile: %s; %s", p.path, err.Error())
+	}
+
+	passphrase, err := p.resolvePassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := kf.decrypt(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt keyfile: %s; %s", p.path, err.Error())
+	}
+
+	return common.StringOrNil(string(seed)), nil
+}
+
+func (p *KeyfileSealUnsealProvider) ValidationHash() (*string, error) {
+	seed, err := p.Seed()
+	if err != nil {
+		return nil, fmt.Errorf("validation hash not calculated by seal/unseal provider using configured keyfile; %s", err.Error())
+	}
+
+	hash := crypto.SHA256.New()
+	_, err = hash.Write([]byte(*seed))
+	if err != nil {
+		return nil, fmt.Errorf("validation hash not calculated by seal/unseal provider using configured keyfile; %s", err.Error())
+	}
+
+	return common.StringOrNil(fmt.Sprintf("0x%s", hex.EncodeToString(hash.Sum(nil)))), nil
+}
+
+func (p *KeyfileSealUnsealProvider) Rekey(seed, validationHash string) error {
+	passphrase, err := p.resolvePassphrase(false)
+	if err != nil {
+		return fmt.Errorf("failed to rekey seal/unseal provider using configured keyfile; %s", err.Error())
+	}
+
+	err = p.writeKeyfile([]byte(seed), passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to rekey seal/unseal provider using configured keyfile; %s", err.Error())
+	}
+
+	return nil
+}
+
+// createSeed generates a new vault seed and writes it to a new keyfile
+func (p *KeyfileSealUnsealProvider) createSeed() ([]byte, error) {
+	key, err := vaultcrypto.CreateHDWalletWithEntropy(vaultcrypto.DefaultHDWalletSeedEntropy)
+	if err != nil {
+		common.Log.Warning(fmt.Sprintf("failed to generate hd wallet seed; %s", err.Error()))
+		return nil, err
+	}
+
+	passphrase, err := p.resolvePassphrase(true)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.writeKeyfile(key.Seed, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.Seed, nil
+}
+
+// writeKeyfile encrypts the given seed under the given passphrase using the configured
+// key derivation function and atomically replaces the keyfile
+func (p *KeyfileSealUnsealProvider) writeKeyfile(seed, passphrase []byte) error {
+	kf, err := encryptKeyfile(seed, passphrase, p.kdf)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(kf)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(p.path), fmt.Sprintf(".%s.", filepath.Base(p.path)))
+	if err != nil {
+		return fmt.Errorf("failed to write keyfile: %s; %s", p.path, err.Error())
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(raw)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write keyfile: %s; %s", p.path, err.Error())
+	}
+
+	err = os.Rename(tmp.Name(), p.path)
+	if err != nil {
+		return fmt.Errorf("failed to write keyfile: %s; %s", p.path, err.Error())
+	}
+
+	return nil
+}
+
+// resolvePassphrase resolves the keyfile passphrase, which is read once and retained for
+// the lifetime of the provider; confirm requires the passphrase to be entered twice when
+// it is prompted for on the controlling terminal
+func (p *KeyfileSealUnsealProvider) resolvePassphrase(confirm bool) ([]byte, error) {
+	p.passphraseMutex.Lock()
+	defer p.passphraseMutex.Unlock()
+
+	if p.passphrase != nil {
+		return p.passphrase, nil
+	}
+
+	var passphrase []byte
+	var err error
+
+	if p.passphraseFD != nil {
+		passphrase, err = readPassphraseFD(*p.passphraseFD)
+	} else if credentialsDir := os.Getenv("CREDENTIALS_DIRECTORY"); credentialsDir != "" {
+		passphrase, err = ioutil.ReadFile(filepath.Join(credentialsDir, p.passphraseCredential))
+		if err != nil {
+			err = fmt.Errorf("failed to read systemd credential: %s; %s", p.passphraseCredential, err.Error())
+		}
+		passphrase = bytes.TrimRight(passphrase, "\r\n")
+	} else {
+		passphrase, err = promptPassphrase(p.path, confirm)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty keyfile passphrase")
+	}
+
+	p.passphrase = passphrase
+	return p.passphrase, nil
+}
+
+// readPassphraseFD reads the passphrase from the given file descriptor until EOF or newline
+func readPassphraseFD(fd int) ([]byte, error) {
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+	if f == nil {
+		return nil, fmt.Errorf("invalid keyfile passphrase file descriptor: %d", fd)
+	}
+	defer f.Close()
+
+	raw, err := ioutil.ReadAll(io.LimitReader(f, 4096))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyfile passphrase from file descriptor: %d; %s", fd, err.Error())
+	}
+
+	if i := bytes.IndexAny(raw, "\r\n"); i != -1 {
+		raw = raw[:i]
+	}
+
+	return raw, nil
+}
+
+// promptPassphrase prompts for the passphrase on the controlling terminal
+func promptPassphrase(path string, confirm bool) ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no keyfile passphrase source configured and no terminal available; %s", err.Error())
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty, "Enter passphrase for vault keyfile %s: ", path)
+	passphrase, err := terminal.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyfile passphrase from terminal; %s", err.Error())
+	}
+
+	if confirm {
+		fmt.Fprint(tty, "Confirm passphrase: ")
+		confirmation, err := terminal.ReadPassword(int(tty.Fd()))
+		fmt.Fprintln(tty)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keyfile passphrase from terminal; %s", err.Error())
+		}
+
+		if !bytes.Equal(passphrase, confirmation) {
+			return nil, errors.New("keyfile passphrases do not match")
+		}
+	}
+
+	return passphrase, nil
+}
+
+// encryptKeyfile encrypts the given seed under a key derived from the given passphrase
+func encryptKeyfile(seed, passphrase []byte, kdf string) (*keyfile, error) {
+	kf := &keyfile{
+		Version: keyfileVersion,
+		KDF:     kdf,
+		Salt:    make([]byte, keyfileSaltSize),
+	}
+
+	switch kdf {
+	case KeyfileKDFArgon2id:
+		kf.KDFParams = keyfileParams{
+			Time:    keyfileArgon2idTime,
+			Memory:  keyfileArgon2idMemory,
+			Threads: keyfileArgon2idThreads,
+		}
+	case KeyfileKDFScrypt:
+		kf.KDFParams = keyfileParams{
+			N: keyfileScryptN,
+			R: keyfileScryptR,
+			P: keyfileScryptP,
+		}
+	default:
+		return nil, fmt.Errorf("unsupported keyfile key derivation function: %s", kdf)
+	}
+
+	_, err := rand.Read(kf.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := kf.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Wipe()
+
+	kf.Ciphertext, err = key.Encrypt(seed, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return kf, nil
+}
+
+// decrypt decrypts the seed using a key derived from the given passphrase
+func (kf *keyfile) decrypt(passphrase []byte) ([]byte, error) {
+	if kf.Version != keyfileVersion {
+		return nil, fmt.Errorf("unsupported keyfile version: %d", kf.Version)
+	}
+
+	if len(kf.Ciphertext) <= vaultcrypto.NonceSizeAES256GCM {
+		return nil, errors.New("invalid keyfile ciphertext")
+	}
+
+	key, err := kf.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Wipe()
+
+	return key.Decrypt(kf.Ciphertext[vaultcrypto.NonceSizeAES256GCM:], kf.Ciphertext[0:vaultcrypto.NonceSizeAES256GCM])
+}
+
+// deriveKey derives the keyfile encryption key from the given passphrase
+func (kf *keyfile) deriveKey(passphrase []byte) (*vaultcrypto.AES256GCM, error) {
+	if len(kf.Salt) == 0 {
+		return nil, errors.New("invalid keyfile salt")
+	}
+
+	var key []byte
+	var err error
+
+	switch strings.ToLower(kf.KDF) {
+	case KeyfileKDFArgon2id:
+		if kf.KDFParams.Time == 0 || kf.KDFParams.Memory == 0 || kf.KDFParams.Threads == 0 {
+			return nil, errors.New("invalid argon2id keyfile parameters")
+		}
+		key = argon2.IDKey(passphrase, kf.Salt, kf.KDFParams.Time, kf.KDFParams.Memory, kf.KDFParams.Threads, vaultcrypto.AES256GCMSeedSize)
+	case KeyfileKDFScrypt:
+		key, err = scrypt.Key(passphrase, kf.Salt, kf.KDFParams.N, kf.KDFParams.R, kf.KDFParams.P, vaultcrypto.AES256GCMSeedSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scrypt keyfile parameters; %s", err.Error())
+		}
+	default:
+		return nil, fmt.Errorf("unsupported keyfile key derivation function: %s", kf.KDF)
+	}
+
+	return &vaultcrypto.AES256GCM{
+		PrivateKey: key,
+	}, nil
+}
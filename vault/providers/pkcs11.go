@@ -0,0 +1,371 @@
+package providers
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/miekg/pkcs11"
+	"github.com/provideplatform/vault/common"
+	vaultcrypto "github.com/provideplatform/vault/crypto"
+)
+
+const defaultPKCS11WrappingKeyLabel = "vault-unsealer-wrapping-key"
+
+// pkcs11GCMNonceSize is the size of the AES-GCM nonce prepended to the wrapped seed
+const pkcs11GCMNonceSize = 12
+
+// pkcs11GCMTagBits is the size of the AES-GCM authentication tag, in bits
+const pkcs11GCMTagBits = 128
+
+// PKCS11SealUnsealProvider implements the Unsealer interface; the unsealer key is stored
+// as ciphertext wrapped by a non-extractable AES-256 key which never leaves the PKCS#11
+// token (i.e., an HSM or SoftHSM), and is unwrapped by the token when the seed is requested
+type PKCS11SealUnsealProvider struct {
+	module           string
+	slot             *uint
+	tokenLabel       string
+	pin              string
+	wrappingKeyLabel string
+
+	unsealKeyCiphertext     string
+	unsealKeyCiphertextPath string
+}
+
+// InitPKCS11SealUnsealProvider initializes and returns the PKCS#11 Unseal provider
+func InitPKCS11SealUnsealProvider(params map[string]interface{}) *PKCS11SealUnsealProvider {
+	module, moduleOk := params["module"].(string)
+	if !moduleOk {
+		module = os.Getenv("SEAL_UNSEAL_PKCS11_MODULE")
+	}
+
+	pin, pinOk := params["pin"].(string)
+	if !pinOk {
+		pin = os.Getenv("SEAL_UNSEAL_PKCS11_PIN")
+	}
+
+	if module == "" || pin == "" {
+		common.Log.Warning("failed to initialize PKCS#11 provider; module and pin are required")
+		return nil
+	}
+
+	tokenLabel, tokenLabelOk := params["token_label"].(string)
+	if !tokenLabelOk {
+		tokenLabel = os.Getenv("SEAL_UNSEAL_PKCS11_TOKEN_LABEL")
+	}
+
+	var slot *uint
+	if os.Getenv("SEAL_UNSEAL_PKCS11_SLOT") != "" {
+		slotID, err := strconv.ParseUint(os.Getenv("SEAL_UNSEAL_PKCS11_SLOT"), 10, 64)
+		if err != nil {
+			common.Log.Warningf("failed to initialize PKCS#11 provider; invalid SEAL_UNSEAL_PKCS11_SLOT; %s", err.Error())
+			return nil
+		}
+		slotUint := uint(slotID)
+		slot = &slotUint
+	}
+
+	wrappingKeyLabel := defaultPKCS11WrappingKeyLabel
+	if os.Getenv("SEAL_UNSEAL_PKCS11_KEY_LABEL") != "" {
+		wrappingKeyLabel = os.Getenv("SEAL_UNSEAL_PKCS11_KEY_LABEL")
+	}
+
+	unsealKeyCiphertext := os.Getenv("SEAL_UNSEAL_KEY_CIPHERTEXT")
+	unsealKeyCiphertextPath := os.Getenv("SEAL_UNSEAL_KEY_CIPHERTEXT_PATH")
+
+	if unsealKeyCiphertext == "" && unsealKeyCiphertextPath == "" {
+		common.Log.Warning("failed to initialize PKCS#11 provider; SEAL_UNSEAL_KEY_CIPHERTEXT or SEAL_UNSEAL_KEY_CIPHERTEXT_PATH is required")
+		return nil
+	}
+
+	return &PKCS11SealUnsealProvider{
+		module:                  module,
+		slot:                    slot,
+		tokenLabel:              tokenLabel,
+		pin:                     pin,
+		wrappingKeyLabel:        wrappingKeyLabel,
+		unsealKeyCiphertext:     unsealKeyCiphertext,
+		unsealKeyCiphertextPath: unsealKeyCiphertextPath,
+	}
+}
+
+func (p *PKCS11SealUnsealProvider) Seed() (*string, error) {
+	var seed []byte
+
+	ciphertext, err := fetchSealedSeed(p.unsealKeyCiphertext, p.unsealKeyCiphertextPath)
+	if err != nil {
+		// a new vault seed is only created when no ciphertext exists; any other error
+		// is returned, as the existing ciphertext may be the only sealed copy of the
+		// unsealer key
+		if err != errSealedSeedNotFound {
+			return nil, fmt.Errorf("failed to resolve vault seed ciphertext for configured PKCS#11 token; %s", err.Error())
+		}
+
+		common.Log.Debugf("vault seed ciphertext not found: %s; generating vault seed using configured PKCS#11 token", p.unsealKeyCiphertextPath)
+		seed, err = p.createSeed()
+		if err != nil {
+			common.Log.Warningf("failed to create vault seed using configured PKCS#11 token; %s", err.Error())
+			return nil, err
+		}
+
+		return common.StringOrNil(string(seed)), nil
+	}
+
+	err = p.withSession(func(ctx *pkcs11.Ctx, session pkcs11.SessionHandle) error {
+		key, err := p.findWrappingKey(ctx, session)
+		if err != nil {
+			return err
+		}
+
+		seed, err = p.unwrap(ctx, session, key, ciphertext)
+		return err
+	})
+	if err != nil {
+		common.Log.Warningf("failed to unwrap vault seed using configured PKCS#11 token; %s", err.Error())
+		return nil, err
+	}
+
+	return common.StringOrNil(string(seed)), nil
+}
+
+func (p *PKCS11SealUnsealProvider) ValidationHash() (*string, error) {
+	seed, err := p.Seed()
+	if err != nil {
+		return nil, fmt.Errorf("validation hash not calculated by seal/unseal provider using configured PKCS#11 token; %s", err.Error())
+	}
+
+	hash := crypto.SHA256.New()
+	_, err = hash.Write([]byte(*seed))
+	if err != nil {
+		return nil, fmt.Errorf("validation hash not calculated by seal/unseal provider using configured PKCS#11 token; %s", err.Error())
+	}
+
+	return common.StringOrNil(fmt.Sprintf("0x%s", hex.EncodeToString(hash.Sum(nil)))), nil
+}
+
+func (p *PKCS11SealUnsealProvider) Rekey(seed, validationHash string) error {
+	err := p.setSeed([]byte(seed), true)
+	if err != nil {
+		return fmt.Errorf("failed to rekey seal/unseal provider using configured PKCS#11 token; %s", err.Error())
+	}
+
+	if p.unsealKeyCiphertextPath == "" {
+		common.Log.Warning("rekeyed PKCS#11 seal/unseal provider for the running process; SEAL_UNSEAL_KEY_CIPHERTEXT must be updated in the environment of every vault instance")
+	}
+
+	return nil
+}
+
+// createSeed generates a new vault seed and persists it wrapped by the wrapping key
+func (p *PKCS11SealUnsealProvider) createSeed() ([]byte, error) {
+	key, err := vaultcrypto.CreateHDWalletWithEntropy(vaultcrypto.DefaultHDWalletSeedEntropy)
+	if err != nil {
+		common.Log.Warning(fmt.Sprintf("failed to generate hd wallet seed; %s", err.Error()))
+		return nil, err
+	}
+
+	err = p.setSeed(key.Seed, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.Seed, nil
+}
+
+// setSeed wraps the given seed using the wrapping key, which is generated within the
+// token if it does not yet exist, and persists the wrapped seed; replace must be true to
+// replace an existing wrapped seed
+func (p *PKCS11SealUnsealProvider) setSeed(seed []byte, replace bool) error {
+	var ciphertext []byte
+
+	err := p.withSession(func(ctx *pkcs11.Ctx, session pkcs11.SessionHandle) error {
+		key, err := p.findWrappingKey(ctx, session)
+		if err != nil {
+			common.Log.Debugf("generating PKCS#11 wrapping key: %s; %s", p.wrappingKeyLabel, err.Error())
+			key, err = p.generateWrappingKey(ctx, session)
+			if err != nil {
+				return err
+			}
+		}
+
+		ciphertext, err = p.wrap(ctx, session, key, seed)
+		return err
+	})
+	if err != nil {
+		common.Log.Warning(fmt.Sprintf("failed to wrap vault seed using PKCS#11 token; %s", err.Error()))
+		return err
+	}
+
+	persist := persistSealedSeed
+	if replace {
+		persist = replaceSealedSeed
+	}
+
+	encodedCiphertext, err := persist(ciphertext, p.unsealKeyCiphertextPath)
+	if err != nil {
+		return err
+	}
+	p.unsealKeyCiphertext = encodedCiphertext
+
+	return nil
+}
+
+// wrap encrypts the given plaintext using AES-GCM within the token; the nonce is
+// prepended to the returned ciphertext
+func (p *PKCS11SealUnsealProvider) wrap(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, key pkcs11.ObjectHandle, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, pkcs11GCMNonceSize)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	params := pkcs11.NewGCMParams(nonce, nil, pkcs11GCMTagBits)
+	defer params.Free()
+
+	err = ctx.EncryptInit(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params)}, key)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := ctx.Encrypt(session, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(nonce, ciphertext...), nil
+}
+
+// unwrap decrypts the given nonce-prefixed ciphertext using AES-GCM within the token
+func (p *PKCS11SealUnsealProvider) unwrap(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, key pkcs11.ObjectHandle, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) <= pkcs11GCMNonceSize {
+		return nil, fmt.Errorf("invalid %d-byte vault seed ciphertext", len(ciphertext))
+	}
+
+	params := pkcs11.NewGCMParams(ciphertext[0:pkcs11GCMNonceSize], nil, pkcs11GCMTagBits)
+	defer params.Free()
+
+	err := ctx.DecryptInit(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params)}, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.Decrypt(session, ciphertext[pkcs11GCMNonceSize:])
+}
+
+// findWrappingKey resolves the handle of the wrapping key by its label
+func (p *PKCS11SealUnsealProvider) findWrappingKey(ctx *pkcs11.Ctx, session pkcs11.SessionHandle) (pkcs11.ObjectHandle, error) {
+	err := ctx.FindObjectsInit(session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, p.wrappingKeyLabel),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	handles, _, err := ctx.FindObjects(session, 1)
+	finalErr := ctx.FindObjectsFinal(session)
+	if err != nil {
+		return 0, err
+	}
+	if finalErr != nil {
+		return 0, finalErr
+	}
+
+	if len(handles) == 0 {
+		return 0, fmt.Errorf("wrapping key not found: %s", p.wrappingKeyLabel)
+	}
+
+	return handles[0], nil
+}
+
+// generateWrappingKey generates a persistent, sensitive and non-extractable AES-256 key within the token
+func (p *PKCS11SealUnsealProvider) generateWrappingKey(ctx *pkcs11.Ctx, session pkcs11.SessionHandle) (pkcs11.ObjectHandle, error) {
+	return ctx.GenerateKey(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_KEY_GEN, nil)}, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, p.wrappingKeyLabel),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, 32),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, true),
+		pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
+	})
+}
+
+// withSession loads the configured PKCS#11 module, opens an authenticated session
+// with the configured token and invokes the given function; the session is closed
+// and the module unloaded upon return
+func (p *PKCS11SealUnsealProvider) withSession(fn func(ctx *pkcs11.Ctx, session pkcs11.SessionHandle) error) error {
+	ctx := pkcs11.New(p.module)
+	if ctx == nil {
+		return fmt.Errorf("failed to load PKCS#11 module: %s", p.module)
+	}
+	defer ctx.Destroy()
+
+	err := ctx.Initialize()
+	if err != nil {
+		return fmt.Errorf("failed to initialize PKCS#11 module: %s; %s", p.module, err.Error())
+	}
+	defer ctx.Finalize()
+
+	slot, err := p.resolveSlot(ctx)
+	if err != nil {
+		return err
+	}
+
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return fmt.Errorf("failed to open PKCS#11 session; %s", err.Error())
+	}
+	defer ctx.CloseSession(session)
+
+	err = ctx.Login(session, pkcs11.CKU_USER, p.pin)
+	if err != nil {
+		return fmt.Errorf("failed to login to PKCS#11 token; %s", err.Error())
+	}
+	defer ctx.Logout(session)
+
+	return fn(ctx, session)
+}
+
+// resolveSlot resolves the slot of the configured token; the configured slot is used
+// if present, otherwise the slot is resolved by token label, falling back to the first
+// slot with a token present
+func (p *PKCS11SealUnsealProvider) resolveSlot(ctx *pkcs11.Ctx) (uint, error) {
+	if p.slot != nil {
+		return *p.slot, nil
+	}
+
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list PKCS#11 slots; %s", err.Error())
+	}
+
+	if len(slots) == 0 {
+		return 0, errors.New("no PKCS#11 token present")
+	}
+
+	if p.tokenLabel == "" {
+		return slots[0], nil
+	}
+
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			continue
+		}
+
+		if info.Label == p.tokenLabel {
+			return slot, nil
+		}
+	}
+
+	return 0, fmt.Errorf("PKCS#11 token not found: %s", p.tokenLabel)
+}
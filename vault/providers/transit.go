@@ -0,0 +1,266 @@
+package providers
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/provideplatform/vault/common"
+	vaultcrypto "github.com/provideplatform/vault/crypto"
+)
+
+const defaultTransitMountPath = "transit"
+const defaultTransitRequestTimeout = time.Second * 10
+
+// TransitSealUnsealProvider implements the Unsealer interface; the unsealer key is stored
+// as ciphertext wrapped by a named key of a remote transit secrets engine (i.e., HashiCorp
+// Vault) and is decrypted over HTTP when the seed is requested
+type TransitSealUnsealProvider struct {
+	address   string
+	token     string
+	namespace string
+	mountPath string
+	keyName   string
+	client    *http.Client
+
+	unsealKeyCiphertext     string
+	unsealKeyCiphertextPath string
+}
+
+// transitRequestResponse is the request and response body of the transit encrypt and decrypt APIs
+type transitRequestResponse struct {
+	Data struct {
+		Ciphertext string `json:"ciphertext,omitempty"`
+		Plaintext  string `json:"plaintext,omitempty"`
+	} `json:"data"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// InitTransitSealUnsealProvider initializes and returns the transit Unseal provider
+func InitTransitSealUnsealProvider(params map[string]interface{}) *TransitSealUnsealProvider {
+	address, addressOk := params["address"].(string)
+	if !addressOk {
+		address = os.Getenv("SEAL_UNSEAL_TRANSIT_ADDR")
+	}
+
+	token, tokenOk := params["token"].(string)
+	if !tokenOk {
+		token = os.Getenv("SEAL_UNSEAL_TRANSIT_TOKEN")
+	}
+
+	keyName, keyNameOk := params["key_name"].(string)
+	if !keyNameOk {
+		keyName = os.Getenv("SEAL_UNSEAL_TRANSIT_KEY_NAME")
+	}
+
+	if address == "" || token == "" || keyName == "" {
+		common.Log.Warning("failed to initialize transit provider; address, token and key name are required")
+		return nil
+	}
+
+	mountPath := defaultTransitMountPath
+	if os.Getenv("SEAL_UNSEAL_TRANSIT_MOUNT_PATH") != "" {
+		mountPath = os.Getenv("SEAL_UNSEAL_TRANSIT_MOUNT_PATH")
+	}
+
+	tlsConfig := &tls.Config{}
+	if os.Getenv("SEAL_UNSEAL_TRANSIT_CA_CERT") != "" {
+		caCert, err := ioutil.ReadFile(os.Getenv("SEAL_UNSEAL_TRANSIT_CA_CERT"))
+		if err != nil {
+			common.Log.Warningf("failed to initialize transit provider; failed to read SEAL_UNSEAL_TRANSIT_CA_CERT; %s", err.Error())
+			return nil
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			common.Log.Warning("failed to initialize transit provider; no certificates parsed from SEAL_UNSEAL_TRANSIT_CA_CERT")
+			return nil
+		}
+	}
+
+	return &TransitSealUnsealProvider{
+		address:   strings.TrimRight(address, "/"),
+		token:     token,
+		namespace: os.Getenv("SEAL_UNSEAL_TRANSIT_NAMESPACE"),
+		mountPath: strings.Trim(mountPath, "/"),
+		keyName:   keyName,
+		client: &http.Client{
+			Timeout: defaultTransitRequestTimeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		},
+		unsealKeyCiphertext:     os.Getenv("SEAL_UNSEAL_KEY_CIPHERTEXT"),
+		unsealKeyCiphertextPath: os.Getenv("SEAL_UNSEAL_KEY_CIPHERTEXT_PATH"),
+	}
+}
+
+func (p *TransitSealUnsealProvider) Seed() (*string, error) {
+	ciphertext, err := fetchSealedSeed(p.unsealKeyCiphertext, p.unsealKeyCiphertextPath)
+	if err != nil {
+		// a new vault seed is only created when no ciphertext exists; any other error
+		// is returned, as the existing ciphertext may be the only sealed copy of the
+		// unsealer key
+		if err != errSealedSeedNotFound {
+			return nil, fmt.Errorf("failed to resolve vault seed ciphertext for configured transit key; %s", err.Error())
+		}
+
+		common.Log.Debugf("vault seed ciphertext not found: %s; generating vault seed using configured transit key", p.unsealKeyCiphertextPath)
+		seed, err := p.createSeed()
+		if err != nil {
+			common.Log.Warningf("failed to create vault seed using configured transit key; %s", err.Error())
+			return nil, err
+		}
+
+		return common.StringOrNil(string(seed)), nil
+	}
+
+	req := &transitRequestResponse{}
+	req.Data.Ciphertext = string(ciphertext)
+
+	resp, err := p.request("decrypt", req)
+	if err != nil {
+		common.Log.Warningf("failed to decrypt vault seed using configured transit key; %s", err.Error())
+		return nil, err
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode vault seed decrypted using configured transit key; %s", err.Error())
+	}
+
+	return common.StringOrNil(string(seed)), nil
+}
+
+func (p *TransitSealUnsealProvider) ValidationHash() (*string, error) {
+	seed, err := p.Seed()
+	if err != nil {
+		return nil, fmt.Errorf("validation hash not calculated by seal/unseal provider using configured transit key; %s", err.Error())
+	}
+
+	hash := crypto.SHA256.New()
+	_, err = hash.Write([]byte(*seed))
+	if err != nil {
+		return nil, fmt.Errorf("validation hash not calculated by seal/unseal provider using configured transit key; %s", err.Error())
+	}
+
+	return common.StringOrNil(fmt.Sprintf("0x%s", hex.EncodeToString(hash.Sum(nil)))), nil
+}
+
+func (p *TransitSealUnsealProvider) Rekey(seed, validationHash string) error {
+	err := p.setSeed([]byte(seed), true)
+	if err != nil {
+		return fmt.Errorf("failed to rekey seal/unseal provider using configured transit key; %s", err.Error())
+	}
+
+	if p.unsealKeyCiphertextPath == "" {
+		common.Log.Warning("rekeyed transit seal/unseal provider for the running process; SEAL_UNSEAL_KEY_CIPHERTEXT must be updated in the environment of every vault instance")
+	}
+
+	return nil
+}
+
+// createSeed generates a new vault seed and persists it wrapped by the transit key
+func (p *TransitSealUnsealProvider) createSeed() ([]byte, error) {
+	if p.unsealKeyCiphertextPath == "" {
+		return nil, errors.New("SEAL_UNSEAL_KEY_CIPHERTEXT_PATH is required to create vault seed")
+	}
+
+	key, err := vaultcrypto.CreateHDWalletWithEntropy(vaultcrypto.DefaultHDWalletSeedEntropy)
+	if err != nil {
+		common.Log.Warning(fmt.Sprintf("failed to generate hd wallet seed; %s", err.Error()))
+		return nil, err
+	}
+
+	err = p.setSeed(key.Seed, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.Seed, nil
+}
+
+// setSeed encrypts the given seed using the transit key and persists the resulting ciphertext;
+// replace must be true to replace an existing ciphertext
+func (p *TransitSealUnsealProvider) setSeed(seed []byte, replace bool) error {
+	req := &transitRequestResponse{}
+	req.Data.Plaintext = base64.StdEncoding.EncodeToString(seed)
+
+	resp, err := p.request("encrypt", req)
+	if err != nil {
+		common.Log.Warning(fmt.Sprintf("failed to encrypt vault seed using transit key; %s", err.Error()))
+		return err
+	}
+
+	if resp.Data.Ciphertext == "" {
+		return errors.New("no ciphertext returned by transit encrypt")
+	}
+
+	persist := persistSealedSeed
+	if replace {
+		persist = replaceSealedSeed
+	}
+
+	encodedCiphertext, err := persist([]byte(resp.Data.Ciphertext), p.unsealKeyCiphertextPath)
+	if err != nil {
+		return err
+	}
+	p.unsealKeyCiphertext = encodedCiphertext
+
+	return nil
+}
+
+// request invokes the given transit operation (i.e., encrypt or decrypt) using the configured key
+func (p *TransitSealUnsealProvider) request(operation string, params *transitRequestResponse) (*transitRequestResponse, error) {
+	body, err := json.Marshal(params.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/%s/%s", p.address, p.mountPath, operation, p.keyName)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("transit %s request failed; %s", operation, err.Error())
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transit %s response; %s", operation, err.Error())
+	}
+
+	result := &transitRequestResponse{}
+	json.Unmarshal(raw, &result)
+
+	if resp.StatusCode >= 300 {
+		if len(result.Errors) > 0 {
+			return nil, fmt.Errorf("transit %s request failed with status %d; %s", operation, resp.StatusCode, strings.Join(result.Errors, "; "))
+		}
+		return nil, fmt.Errorf("transit %s request failed with status %d", operation, resp.StatusCode)
+	}
+
+	return result, nil
+}
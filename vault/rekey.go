@@ -0,0 +1,255 @@
+package vault
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jinzhu/gorm"
+	dbconf "github.com/kthomas/go-db-config"
+	uuid "github.com/kthomas/go.uuid"
+	"github.com/provideplatform/vault/common"
+	vaultcrypto "github.com/provideplatform/vault/crypto"
+	"github.com/provideplatform/vault/vault/providers"
+)
+
+// rekeyBatchSize is the number of master keys re-wrapped within a single transaction
+const rekeyBatchSize = 100
+
+// rekeyMutex ensures only one rekey is in progress at a time
+var rekeyMutex sync.Mutex
+
+// Rekey rotates the unsealer key; every vault master key is re-wrapped under the
+// new unsealer key in batched transactions, after which the configured seal/unseal
+// provider is switched to the new unsealer key. The vault is sealed for the duration
+// of the rekey and unsealed using the new unsealer key once it has completed; it
+// remains sealed if the rekey fails.
+// The configured seal/unseal provider must be able to persist the new unsealer key; the
+// rekey is rejected before the vault is sealed when it cannot.
+// A rekey which fails part of the way through is resumed by invoking it again with
+// the same current and new unsealer keys; master keys which were already re-wrapped
+// are detected and skipped.
+func Rekey(currentUnsealerKey, newUnsealerKey string) (*SealUnsealRequestResponse, error) {
+	if currentUnsealerKey == "" || newUnsealerKey == "" {
+		return nil, fmt.Errorf("error rekeying vault; current and new unsealer keys are required")
+	}
+
+	// the master keys must not be re-wrapped unless the provider can persist the new unsealer key
+	if !providers.RekeySupported(providerName) {
+		return nil, fmt.Errorf("error rekeying vault; %s: %s", providers.ErrRekeyNotSupported.Error(), providerName)
+	}
+
+	if currentUnsealerKey == newUnsealerKey {
+		return nil, fmt.Errorf("error rekeying vault; new unsealer key must differ from the current unsealer key")
+	}
+
+	err := validateUnsealerKey(currentUnsealerKey)
+	if err != nil {
+		return nil, fmt.Errorf("error rekeying vault; %s", err.Error())
+	}
+
+	currentSealerKey, err := unsealerKeyEntropy(currentUnsealerKey)
+	if err != nil {
+		return nil, fmt.Errorf("error rekeying vault; %s", err.Error())
+	}
+	defer currentSealerKey.Destroy()
+
+	newSealerKey, err := unsealerKeyEntropy(newUnsealerKey)
+	if err != nil {
+		return nil, fmt.Errorf("error rekeying vault; new unsealer key is invalid; %s", err.Error())
+	}
+	defer newSealerKey.Destroy()
+
+	validationHash := crypto.SHA256.New()
+	_, err = validationHash.Write([]byte(newUnsealerKey))
+	if err != nil {
+		return nil, fmt.Errorf("error rekeying vault; error hashing new unsealer key")
+	}
+	newValidationHash := fmt.Sprintf("0x%s", hex.EncodeToString(validationHash.Sum(nil)))
+
+	rekeyMutex.Lock()
+	defer rekeyMutex.Unlock()
+
+	// seal every vault instance so no material is sealed under the current unsealer key during the rekey
+	sealVault()
+	publishSealEvent()
+
+	rekeyed, err := rewrapMasterKeys(dbconf.DatabaseConnection(), currentSealerKey.Bytes(), newSealerKey.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error rekeying vault; %s", err.Error())
+	}
+	common.Log.Debugf("re-wrapped %d vault master key(s) under new unsealer key", rekeyed)
+
+	err = provider.Rekey(newUnsealerKey, newValidationHash)
+	if err != nil {
+		return nil, fmt.Errorf("error rekeying vault; master keys were re-wrapped but the seal/unseal provider was not rekeyed; %s", err.Error())
+	}
+
+	err = SetUnsealerKey(newUnsealerKey)
+	if err != nil {
+		return nil, fmt.Errorf("vault rekeyed but failed to unseal using new unsealer key; %s", err.Error())
+	}
+
+	common.Log.Debug("vault rekeyed")
+	return &SealUnsealRequestResponse{
+		UnsealerKey:    common.StringOrNil(newUnsealerKey),
+		ValidationHash: common.StringOrNil(newValidationHash),
+	}, nil
+}
+
+// rewrapMasterKeys re-encrypts the seed and private key of every vault master key version
+// from the current sealer key to the new sealer key, in batches ordered by key id;
+// returns the number of master keys which were re-wrapped
+func rewrapMasterKeys(db *gorm.DB, currentSealerKey, newSealerKey []byte) (int, error) {
+	rekeyed := 0
+	lastID := uuid.Nil
+
+	for {
+		var keys []*Key
+		result := db.Select("keys.id, keys.seed, keys.private_key").
+			Joins("inner join master_key_versions on master_key_versions.key_id = keys.id").
+			Where("keys.id > ?", lastID).
+			Order("keys.id ASC").
+			Limit(rekeyBatchSize).
+			Find(&keys)
+		if result.Error != nil {
+			return rekeyed, fmt.Errorf("failed to resolve master keys to re-wrap; %s", result.Error.Error())
+		}
+
+		if len(keys) == 0 {
+			break
+		}
+
+		tx := db.Begin()
+		if tx.Error != nil {
+			return rekeyed, fmt.Errorf("failed to begin master key re-wrap transaction; %s", tx.Error.Error())
+		}
+
+		for _, key := range keys {
+			updates := map[string]interface{}{}
+
+			if key.Seed != nil {
+				seed, rewrapped, err := rewrapSealedKey(*key.Seed, currentSealerKey, newSealerKey)
+				if err != nil {
+					tx.Rollback()
+					return rekeyed, fmt.Errorf("failed to re-wrap seed of master key %s; %s", key.ID, err.Error())
+				}
+				if rewrapped {
+					updates["seed"] = seed
+				}
+			}
+
+			if key.PrivateKey != nil {
+				privateKey, rewrapped, err := rewrapSealedKey(*key.PrivateKey, currentSealerKey, newSealerKey)
+				if err != nil {
+					tx.Rollback()
+					return rekeyed, fmt.Errorf("failed to re-wrap private key of master key %s; %s", key.ID, err.Error())
+				}
+				if rewrapped {
+					updates["private_key"] = privateKey
+				}
+			}
+
+			if len(updates) > 0 {
+				result := tx.Model(&Key{}).Where("id = ?", key.ID).Updates(updates)
+				if result.Error != nil {
+					tx.Rollback()
+					return rekeyed, fmt.Errorf("failed to persist re-wrapped master key %s; %s", key.ID, result.Error.Error())
+				}
+				rekeyed++
+			}
+
+			lastID = key.ID
+		}
+
+		result = tx.Commit()
+		if result.Error != nil {
+			return rekeyed, fmt.Errorf("failed to commit re-wrapped master keys; %s", result.Error.Error())
+		}
+
+		common.Log.Debugf("committed batch of %d re-wrapped vault master key(s)", len(keys))
+	}
+
+	return rekeyed, nil
+}
+
+// rewrapSealedKey decrypts the given sealed material using the current sealer key and
+// encrypts it using the new sealer key; material which is already sealed under the new
+// sealer key is left as-is, and false is returned
+func rewrapSealedKey(sealedKey, currentSealerKey, newSealerKey []byte) ([]byte, bool, error) {
+	if len(sealedKey) <= NonceSizeSymmetric {
+		return nil, false, fmt.Errorf("invalid %d-byte sealed key", len(sealedKey))
+	}
+
+	newKey := vaultcrypto.AES256GCM{
+		PrivateKey: newSealerKey,
+	}
+
+	_, err := newKey.Decrypt(sealedKey[NonceSizeSymmetric:], sealedKey[0:NonceSizeSymmetric])
+	if err == nil {
+		// already re-wrapped by a previous attempt
+		return sealedKey, false, nil
+	}
+
+	currentKey := vaultcrypto.AES256GCM{
+		PrivateKey: currentSealerKey,
+	}
+
+	unsealedKey, err := currentKey.Decrypt(sealedKey[NonceSizeSymmetric:], sealedKey[0:NonceSizeSymmetric])
+	if err != nil {
+		return nil, false, err
+	}
+
+	rewrappedKey, err := newKey.Encrypt(unsealedKey, nil)
+
+	// wipe the unsealed key in memory before garbage collection
+	vaultcrypto.WipeBytes(unsealedKey)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return rewrappedKey, true, nil
+}
+
+// unsealerKeyEntropy returns the 32-byte entropy of the given BIP39 unsealer key,
+// which is used as the AES-256-GCM sealer key, in guarded memory; the caller must
+// destroy the returned buffer
+func unsealerKeyEntropy(passphrase string) (*vaultcrypto.SecureBuffer, error) {
+	entropy, err := vaultcrypto.GetEntropyFromMnemonic(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("recovering entropy from BIP39 passphrase failed")
+	}
+
+	if len(entropy) != common.UnsealerKeyRequiredBytes {
+		vaultcrypto.WipeBytes(entropy)
+		return nil, fmt.Errorf("32-byte entropy required for AES encryption and is minimum required for vault security")
+	}
+
+	return vaultcrypto.NewSecureBuffer(entropy), nil
+}
+
+// validateUnsealerKey validates the given unsealer key against the validation hash
+// of the configured seal/unseal provider
+func validateUnsealerKey(passphrase string) error {
+	incomingKeyHash := crypto.SHA256.New()
+	_, err := incomingKeyHash.Write([]byte(passphrase))
+	if err != nil {
+		return fmt.Errorf("error hashing incoming key")
+	}
+
+	validationHash, err := provider.ValidationHash()
+	if err != nil || validationHash == nil || *validationHash == "" {
+		return fmt.Errorf("no seal/unseal validation hash present")
+	}
+
+	validator, _ := hex.DecodeString(strings.TrimPrefix(*validationHash, "0x"))
+	if !bytes.Equal(incomingKeyHash.Sum(nil), validator) {
+		return fmt.Errorf("unsealer key provided doesn't match validation hash")
+	}
+
+	return nil
+}
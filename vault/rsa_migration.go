@@ -0,0 +1,143 @@
+package vault
+
+import (
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+	uuid "github.com/kthomas/go.uuid"
+	"github.com/provideplatform/vault/common"
+	"github.com/provideplatform/vault/crypto"
+)
+
+// rsaMigrationBatchSize is the number of RSA keys migrated within a single transaction
+const rsaMigrationBatchSize = 100
+
+// rsaKeySpecs are the specs of keys which hold RSA key material
+var rsaKeySpecs = []string{KeySpecRSA2048, KeySpecRSA3072, KeySpecRSA4096}
+
+// MigrateRSAKeyMaterial re-encodes the key material of every RSA key, and each of its
+// non-destroyed previous versions, which is stored in the legacy JSON encoding as DER-encoded
+// PKCS#8 private and PKIX public keys; the private key is decrypted, re-encoded and wrapped
+// again by the master key which wrapped it, such that the plaintext key material is never
+// persisted. The vault must be unsealed. Keys which were already migrated are skipped, so an
+// interrupted migration is resumed by invoking it again; returns the number of keys and key
+// versions which were migrated
+func MigrateRSAKeyMaterial(db *gorm.DB) (int, error) {
+	if vaultIsSealed() {
+		return 0, fmt.Errorf("failed to migrate RSA key material; vault is sealed")
+	}
+
+	migrated := 0
+	lastID := uuid.Nil
+
+	for {
+		var keys []*Key
+		result := db.Select("keys.id, keys.vault_id, keys.type, keys.spec, keys.version, keys.public_key, keys.private_key, keys.master_key_id").
+			Where("keys.spec IN (?) AND keys.id > ?", rsaKeySpecs, lastID).
+			Order("keys.id ASC").
+			Limit(rsaMigrationBatchSize).
+			Find(&keys)
+		if result.Error != nil {
+			return migrated, fmt.Errorf("failed to resolve RSA keys to migrate; %s", result.Error.Error())
+		}
+
+		if len(keys) == 0 {
+			break
+		}
+
+		for _, key := range keys {
+			tx := db.Begin()
+			if tx.Error != nil {
+				return migrated, fmt.Errorf("failed to begin migration of RSA key material of key %s; %s", key.ID, tx.Error.Error())
+			}
+
+			// key material is only counted as migrated once the transaction is committed
+			keyMigrated := 0
+
+			ok, err := migrateRSAKey(tx, &Key{}, key.ID, key)
+			if err != nil {
+				tx.Rollback()
+				return migrated, fmt.Errorf("failed to migrate RSA key material of key %s; %s", key.ID, err.Error())
+			}
+			if ok {
+				keyMigrated++
+			}
+
+			var keyVersions []*KeyVersion
+			result = tx.Where("key_id = ? AND destroyed_at IS NULL", key.ID).Order("version ASC").Find(&keyVersions)
+			if result.Error != nil {
+				tx.Rollback()
+				return migrated, fmt.Errorf("failed to resolve versions of RSA key %s to migrate; %s", key.ID, result.Error.Error())
+			}
+
+			for _, keyVersion := range keyVersions {
+				ok, err := migrateRSAKey(tx, &KeyVersion{}, keyVersion.ID, key.withVersion(keyVersion))
+				if err != nil {
+					tx.Rollback()
+					return migrated, fmt.Errorf("failed to migrate RSA key material of version %d of key %s; %s", keyVersion.Version, key.ID, err.Error())
+				}
+				if ok {
+					keyMigrated++
+				}
+			}
+
+			result = tx.Commit()
+			if result.Error != nil {
+				return migrated, fmt.Errorf("failed to commit migrated RSA key material of key %s; %s", key.ID, result.Error.Error())
+			}
+
+			migrated += keyMigrated
+			lastID = key.ID
+		}
+	}
+
+	common.Log.Debugf("migrated RSA key material of %d key(s) and key version(s)", migrated)
+	return migrated, nil
+}
+
+// migrateRSAKey re-encodes the key material of the given key, or key version, if it is stored
+// in the legacy JSON encoding, and persists it to the row of the given model and id; the update
+// is conditional on the wrapped private key being unchanged, such that a concurrent rotation or
+// re-wrap is not overwritten; returns true if the key material was migrated
+func migrateRSAKey(tx *gorm.DB, model interface{}, id uuid.UUID, key *Key) (bool, error) {
+	if key.PrivateKey == nil || key.PublicKey == nil {
+		return false, fmt.Errorf("nil private or public key")
+	}
+
+	wrappedPrivateKey := *key.PrivateKey
+
+	err := key.decryptFields()
+	if err != nil {
+		return false, err
+	}
+
+	rsaKeyPair := crypto.RSAKeyPair{
+		PrivateKey: *key.PrivateKey,
+		PublicKey:  *key.PublicKey,
+	}
+
+	ok, err := rsaKeyPair.Migrate()
+	if err != nil || !ok {
+		key.encryptFields()
+		return false, err
+	}
+
+	key.PrivateKey = &rsaKeyPair.PrivateKey
+	key.PublicKey = &rsaKeyPair.PublicKey
+
+	err = key.encryptFields()
+	if err != nil {
+		return false, err
+	}
+
+	result := tx.Model(model).Where("id = ? AND private_key = ?", id, wrappedPrivateKey).Updates(map[string]interface{}{
+		"public_key":    key.PublicKey,
+		"private_key":   key.PrivateKey,
+		"master_key_id": key.MasterKeyID,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/provideplatform/vault/common"
 	vaultcrypto "github.com/provideplatform/vault/crypto"
@@ -18,30 +19,51 @@ var (
 	// provider is the SealUnseal provider
 	provider providers.SealUnsealKeyProvider
 
+	// providerName is the name of the configured SealUnseal provider
+	providerName string
+
 	// unsealerKey is the encryption/decryption key for the vault keys,
-	// which are used to decrypt the private keys/seeds
-	unsealerKey []byte
+	// which are used to decrypt the private keys/seeds; it remains cloaked
+	// in memory under a key held in guarded memory until required
+	unsealerKey *vaultcrypto.SecureEnclave
+
+	// unsealerKeyMutex synchronizes access to the unsealer key, which is cleared when
+	// the vault is sealed by a request handler or a cluster seal event
+	unsealerKeyMutex sync.RWMutex
+
+	// unsealerKeyShares buffers the shamir shares submitted towards unsealing the vault
+	unsealerKeyShares [][]byte
 
-	// unsealerCloakingKey will ensure Unsealer Key is encrypted in memory until required
-	unsealerCloakingKey []byte
+	// unsealerKeySharesMutex synchronizes access to the buffered unsealer key shares
+	unsealerKeySharesMutex sync.Mutex
 )
 
 func init() {
+	providerName = providers.SealUnsealKeyProviderEnvironment
 	if os.Getenv("SEAL_UNSEAL_PROVIDER") != "" {
-		provider, _ = providers.InitSealUnsealProvider(os.Getenv("SEAL_UNSEAL_PROVIDER"), map[string]interface{}{})
-	} else {
-		provider, _ = providers.InitSealUnsealProvider(providers.SealUnsealKeyProviderEnvironment, map[string]interface{}{})
+		providerName = os.Getenv("SEAL_UNSEAL_PROVIDER")
 	}
 
+	provider, _ = providers.InitSealUnsealProvider(providerName, map[string]interface{}{})
+
 	if reflect.ValueOf(provider).IsNil() {
 		common.Log.Panicf("failed to initialize vault seal/unseal provider")
 	}
 }
 
-// SealUnsealRequestResponse provides the unseal information
+// SealUnsealRequestResponse provides the unseal information; when the unsealer key
+// is split into shamir shares, the shares and threshold are provided when the key is
+// created, and each share is subsequently submitted individually to unseal the vault
 type SealUnsealRequestResponse struct {
-	UnsealerKey    *string `json:"key,omitempty"`
-	ValidationHash *string `json:"validation_hash,omitempty"`
+	UnsealerKey    *string   `json:"key,omitempty"`
+	NewUnsealerKey *string   `json:"new_key,omitempty"`
+	ValidationHash *string   `json:"validation_hash,omitempty"`
+	KeyShare       *string   `json:"key_share,omitempty"`
+	KeyShares      []*string `json:"key_shares,omitempty"`
+	Shares         *int      `json:"shares,omitempty"`
+	Threshold      *int      `json:"threshold,omitempty"`
+	Progress       *int      `json:"progress,omitempty"`
+	Sealed         *bool     `json:"sealed,omitempty"`
 }
 
 // AutoUnseal is the entrypoint for automatically unsealing the vault,
@@ -92,11 +114,25 @@ func ClearUnsealerKey(passphrase string) error {
 	}
 	common.Log.Debugf("sealing vault; valid vault unsealing key received")
 
-	unsealerKey = nil
-	unsealerCloakingKey = nil
+	sealVault()
+	publishSealEvent()
 	return nil
 }
 
+// sealVault seals this vault instance by clearing the unsealer key and any
+// buffered unsealer key shares
+func sealVault() {
+	unsealerKeyMutex.Lock()
+	unsealerKey = nil
+	unsealerKeyMutex.Unlock()
+
+	unsealerKeySharesMutex.Lock()
+	resetUnsealerKeyShares()
+	unsealerKeySharesMutex.Unlock()
+
+	vaultcrypto.ClearRSAKeyCache()
+}
+
 // CreateUnsealerKey creates a fresh unsealer key
 func CreateUnsealerKey() (*SealUnsealRequestResponse, error) {
 	// TODO-- if not environment provider, return err...
@@ -126,12 +162,142 @@ func CreateUnsealerKey() (*SealUnsealRequestResponse, error) {
 	return &response, nil
 }
 
+// CreateUnsealerKeyShares creates a fresh unsealer key and splits its entropy into the
+// given number of shamir shares, any threshold of which are required to unseal the vault;
+// the unsealer key itself is never returned
+func CreateUnsealerKeyShares(shares, threshold int) (*SealUnsealRequestResponse, error) {
+	if threshold < vaultcrypto.ShamirMinThreshold || shares < threshold || shares > vaultcrypto.ShamirMaxShares {
+		return nil, fmt.Errorf("failed to create unsealer key shares; threshold must be at least %d and no greater than shares, which must not exceed %d", vaultcrypto.ShamirMinThreshold, vaultcrypto.ShamirMaxShares)
+	}
+
+	key, err := CreateUnsealerKey()
+	if err != nil {
+		return nil, err
+	}
+
+	entropy, err := vaultcrypto.GetEntropyFromMnemonic(*key.UnsealerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create unsealer key shares; recovering entropy from BIP39 passphrase failed")
+	}
+
+	splitShares, err := vaultcrypto.ShamirSplit(entropy, shares, threshold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create unsealer key shares; %s", err.Error())
+	}
+
+	// wipe the unsealer key entropy in memory before garbage collection
+	vaultcrypto.WipeBytes(entropy)
+
+	keyShares := make([]*string, 0)
+	for _, share := range splitShares {
+		keyShares = append(keyShares, common.StringOrNil(fmt.Sprintf("0x%s", hex.EncodeToString(share))))
+	}
+
+	return &SealUnsealRequestResponse{
+		ValidationHash: key.ValidationHash,
+		KeyShares:      keyShares,
+		Shares:         &shares,
+		Threshold:      &threshold,
+	}, nil
+}
+
+// SubmitUnsealerKeyShare buffers the given shamir share of the unsealer key; once the
+// threshold number of shares has been received, the unsealer key is reconstructed and
+// the vault is unsealed; returns the progress towards the threshold while still sealed
+func SubmitUnsealerKeyShare(share string) (*SealUnsealRequestResponse, error) {
+	if share == "" {
+		return nil, fmt.Errorf("error unsealing vault; no unsealer key share provided")
+	}
+
+	// we can't unseal an unsealed vault
+	if !IsSealed() {
+		sealed := false
+		return &SealUnsealRequestResponse{
+			Sealed: &sealed,
+		}, nil
+	}
+
+	shareBytes, err := hex.DecodeString(strings.TrimPrefix(share, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("error unsealing vault; failed to decode unsealer key share")
+	}
+
+	threshold, err := vaultcrypto.ShamirShareThreshold(shareBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error unsealing vault; %s", err.Error())
+	}
+
+	unsealerKeySharesMutex.Lock()
+	defer unsealerKeySharesMutex.Unlock()
+
+	for _, buffered := range unsealerKeyShares {
+		if len(buffered) != len(shareBytes) || int(buffered[0]) != threshold {
+			resetUnsealerKeyShares()
+			return nil, fmt.Errorf("error unsealing vault; unsealer key share is inconsistent with previously submitted shares; submitted shares have been discarded")
+		}
+
+		if buffered[1] == shareBytes[1] {
+			return nil, fmt.Errorf("error unsealing vault; duplicate unsealer key share provided")
+		}
+	}
+
+	if len(unsealerKeyShares) >= vaultcrypto.ShamirMaxShares {
+		resetUnsealerKeyShares()
+		return nil, fmt.Errorf("error unsealing vault; too many unsealer key shares submitted; submitted shares have been discarded")
+	}
+
+	unsealerKeyShares = append(unsealerKeyShares, shareBytes)
+	progress := len(unsealerKeyShares)
+	common.Log.Debugf("received vault unsealer key share %d of %d", progress, threshold)
+
+	if progress < threshold {
+		sealed := true
+		return &SealUnsealRequestResponse{
+			Progress:  &progress,
+			Threshold: &threshold,
+			Sealed:    &sealed,
+		}, nil
+	}
+
+	entropy, err := vaultcrypto.ShamirCombine(unsealerKeyShares)
+	resetUnsealerKeyShares()
+	if err != nil {
+		return nil, fmt.Errorf("error unsealing vault; failed to combine unsealer key shares; %s", err.Error())
+	}
+
+	passphrase, err := vaultcrypto.GetMnemonicFromEntropy(entropy)
+
+	// wipe the unsealer key entropy in memory before garbage collection
+	vaultcrypto.WipeBytes(entropy)
+
+	if err != nil {
+		return nil, fmt.Errorf("error unsealing vault; failed to recover BIP39 passphrase from unsealer key shares")
+	}
+
+	err = SetUnsealerKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := false
+	return &SealUnsealRequestResponse{
+		Progress:  &progress,
+		Threshold: &threshold,
+		Sealed:    &sealed,
+	}, nil
+}
+
+// resetUnsealerKeyShares wipes and discards any buffered unsealer key shares
+func resetUnsealerKeyShares() {
+	for _, share := range unsealerKeyShares {
+		vaultcrypto.WipeBytes(share)
+	}
+	unsealerKeyShares = nil
+}
+
 // IsSealed checks to see if the vault is sealed (true) or unsealed (false)
 func IsSealed() bool {
-	if unsealerKey == nil {
-		return true
-	}
-	return false
+	return vaultIsSealed()
 }
 
 // SetUnsealerKey sets the unsealer key; this only possible with a SEALED vault
@@ -141,7 +307,7 @@ func SetUnsealerKey(passphrase string) error {
 	}
 
 	// we can't unseal an unsealed vault
-	if unsealerKey != nil {
+	if !vaultIsSealed() {
 		return nil
 	}
 
@@ -170,20 +336,6 @@ func SetUnsealerKey(passphrase string) error {
 	}
 	common.Log.Debugf("valid vault unsealing key received")
 
-	// set up a random cloaking key
-	randomKey, err := vaultcrypto.CreateAES256GCMSeed()
-	if err != nil {
-		return fmt.Errorf("error unsealing vault; failed to generate cloaking key")
-	}
-
-	// set the cloaking key to this random key
-	unsealerCloakingKey = randomKey
-
-	// convert the cloaking key to an AES key to perform encryption
-	cloakingKey := vaultcrypto.AES256GCM{
-		PrivateKey: randomKey,
-	}
-
 	// get the original 32-byte entropy from the seed phrase - we will use this as the AES encryption key for the vaults
 	unsealerKeySeed, err := vaultcrypto.GetEntropyFromMnemonic(passphrase)
 	if err != nil {
@@ -191,62 +343,57 @@ func SetUnsealerKey(passphrase string) error {
 	}
 
 	if len(unsealerKeySeed) != common.UnsealerKeyRequiredBytes {
+		vaultcrypto.WipeBytes(unsealerKeySeed)
 		return fmt.Errorf("error unsealing vault; 32-byte entropy required for AES encryption and is minimum required for vault security")
 	}
 
-	// encrypt the unsealer key with the cloaking key
-	cloakedUnsealerKey, err := cloakingKey.Encrypt(unsealerKeySeed, nil)
-	if err != nil {
-		return fmt.Errorf("error unsealing vault; failed to encrypt unsealer with cloaking key")
-	}
+	// cloak the vault unsealer key; the unsealer key seed is wiped in memory
+	enclave := vaultcrypto.NewSecureEnclave(unsealerKeySeed)
 
-	// wipe the unsealer key seed in memory before garbage collection
-	unsealerKeySeed, _ = common.RandomBytes(32)
+	unsealerKeyMutex.Lock()
+	if unsealerKey == nil {
+		unsealerKey = enclave
+	}
+	unsealerKeyMutex.Unlock()
 
-	// set the vault unsealer key
-	unsealerKey = cloakedUnsealerKey
+	reportInstanceStatus()
 	return nil
 }
 
-func getUnsealerKey() ([]byte, error) {
-	if unsealerCloakingKey == nil {
-		return nil, fmt.Errorf("error unsealing vault; no cloaking key available")
-	}
+// getUnsealerKey uncloaks the unsealer key into guarded memory; the caller must
+// destroy the returned buffer as soon as the unsealer key is no longer required.
+// The unsealer key is resolved once, such that an operation which is underway is
+// unaffected by the vault being sealed concurrently
+func getUnsealerKey() (*vaultcrypto.SecureBuffer, error) {
+	unsealerKeyMutex.RLock()
+	enclave := unsealerKey
+	unsealerKeyMutex.RUnlock()
 
-	// convert the cloaking key into an AES key
-	cloakingKey := vaultcrypto.AES256GCM{
-		PrivateKey: unsealerCloakingKey,
+	if enclave == nil {
+		return nil, fmt.Errorf("vault is sealed")
 	}
 
-	// decrypt the unsealer key with the cloaking key
-	encryptedUnsealerKey := unsealerKey
-
-	unsealerKey, err := cloakingKey.Decrypt(
-		encryptedUnsealerKey[NonceSizeSymmetric:],
-		encryptedUnsealerKey[0:NonceSizeSymmetric],
-	)
-
+	key, err := enclave.Open()
 	if err != nil {
 		return nil, fmt.Errorf("error decrypting unsealer key %s", err.Error())
 	}
 
-	return unsealerKey, nil
+	return key, nil
 }
 
 func seal(unsealedKey []byte) ([]byte, error) {
-	if unsealerKey == nil {
-		return nil, fmt.Errorf("vault is sealed")
+	key, err := getUnsealerKey()
+	if err != nil {
+		return nil, err
 	}
+	defer key.Destroy()
 
 	if unsealedKey == nil {
 		return nil, fmt.Errorf("error sealing vault; no unsealed key")
 	}
 
-	var err error
-	sealerKey := vaultcrypto.AES256GCM{}
-	sealerKey.PrivateKey, err = getUnsealerKey()
-	if err != nil {
-		return nil, fmt.Errorf("error sealing vault %s", err.Error())
+	sealerKey := vaultcrypto.AES256GCM{
+		PrivateKey: key.Bytes(),
 	}
 
 	sealedKey, err := sealerKey.Encrypt(unsealedKey, nil)
@@ -259,19 +406,18 @@ func seal(unsealedKey []byte) ([]byte, error) {
 
 // unseal decrypts the sealed material with the unsealer key
 func unseal(sealedKey []byte) ([]byte, error) {
-	if unsealerKey == nil || len(unsealerKey) == 0 {
-		return nil, fmt.Errorf("vault is sealed")
+	key, err := getUnsealerKey()
+	if err != nil {
+		return nil, err
 	}
+	defer key.Destroy()
 
 	if sealedKey == nil || len(sealedKey) == 0 {
 		return nil, fmt.Errorf("error unsealing vault; no sealed key")
 	}
 
-	var err error
-	unsealerKey := vaultcrypto.AES256GCM{}
-	unsealerKey.PrivateKey, err = getUnsealerKey()
-	if err != nil {
-		return nil, fmt.Errorf("error unsealing vault %s", err.Error())
+	unsealerKey := vaultcrypto.AES256GCM{
+		PrivateKey: key.Bytes(),
 	}
 
 	common.Log.Debugf("resolved %d-byte sealed key", len(sealedKey))
@@ -286,8 +432,8 @@ func unseal(sealedKey []byte) ([]byte, error) {
 
 // vaultIsSealed returns true if the vault is sealed
 func vaultIsSealed() bool {
-	if unsealerKey == nil {
-		return true
-	}
-	return false
+	unsealerKeyMutex.RLock()
+	defer unsealerKeyMutex.RUnlock()
+
+	return unsealerKey == nil
 }
@@ -10,7 +10,6 @@ import (
 	uuid "github.com/kthomas/go.uuid"
 	provide "github.com/provideplatform/provide-go/api"
 	"github.com/provideplatform/vault/common"
-	vaultcrypto "github.com/provideplatform/vault/crypto"
 )
 
 // MaxSecretLengthInBytes is the maximum allowable length of a secret to be stored (currently set to 1GB)
@@ -24,6 +23,7 @@ type Secret struct {
 	Name           *string    `sql:"not null" json:"name"`
 	Description    *string    `json:"description"`
 	Value          *[]byte    `sql:"type:bytea" json:"-"`
+	MasterKeyID    *uuid.UUID `sql:"type:uuid" json:"-"` // master key version which wraps the value
 	DecryptedValue *string    `sql:"-" json:"value,omitempty"`
 	encrypted      *bool      `sql:"-"`
 	mutex          sync.Mutex `sql:"-"`
@@ -177,7 +177,12 @@ func (s *Secret) resolveMasterKey(db *gorm.DB) (*Key, error) {
 		return nil, fmt.Errorf("unable to resolve master key: %s; current key is master; vault id: %s", s.ID, s.VaultID)
 	}
 
-	masterKey, err := s.vault.resolveMasterKey(db)
+	var masterKey *Key
+	if s.MasterKeyID != nil {
+		masterKey, err = s.vault.resolveMasterKeyVersion(db, *s.MasterKeyID)
+	} else {
+		masterKey, err = s.vault.resolveMasterKey(db)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve master key for key: %s; %s", s.ID, err.Error())
 	}
@@ -216,7 +221,7 @@ func (s *Secret) encryptFields() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if unsealerKey == nil {
+	if vaultIsSealed() {
 		return fmt.Errorf("vault is sealed")
 	}
 
@@ -260,6 +265,8 @@ func (s *Secret) encryptFields() error {
 			}
 			s.Value = &encryptedSecret
 		}
+
+		s.MasterKeyID = &masterKey.ID
 	}
 
 	s.setEncrypted(true)
@@ -270,7 +277,7 @@ func (s *Secret) decryptFields() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if unsealerKey == nil {
+	if vaultIsSealed() {
 		return fmt.Errorf("vault is sealed")
 	}
 
@@ -287,10 +294,8 @@ func (s *Secret) decryptFields() error {
 		common.Log.Tracef("decrypting master key fields for vault: %s", s.VaultID)
 
 		if s.Value != nil {
-			masterVaultKey := vaultcrypto.AES256GCM{}
-			masterVaultKey.PrivateKey = unsealerKey
-			encryptedData := *s.Value
-			decryptedData, err := masterVaultKey.Decrypt(encryptedData[NonceSizeSymmetric:], encryptedData[0:NonceSizeSymmetric])
+			// unseal the data with the unsealer key
+			decryptedData, err := unseal(*s.Value)
 			if err != nil {
 				return err
 			}
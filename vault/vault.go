@@ -28,17 +28,17 @@ type Vault struct {
 
 // KeyDetailsQuery returns the fields to SELECT from vault keys table
 func (v *Vault) KeyDetailsQuery(db *gorm.DB, keyID string) *gorm.DB {
-	return db.Select("keys.id, keys.created_at, keys.name, keys.description, keys.type, keys.usage, keys.spec, keys.seed, keys.private_key, keys.public_key, keys.vault_id").Where("keys.vault_id = ? AND keys.id = ?", v.ID, keyID)
+	return db.Select("keys.id, keys.created_at, keys.name, keys.description, keys.type, keys.usage, keys.spec, keys.seed, keys.private_key, keys.public_key, keys.vault_id, keys.master_key_id, keys.version, keys.deterministic").Where("keys.vault_id = ? AND keys.id = ?", v.ID, keyID)
 }
 
 // ListKeysQuery returns the fields to SELECT from vault keys table
 func (v *Vault) ListKeysQuery(db *gorm.DB) *gorm.DB {
-	return db.Select("keys.id, keys.created_at, keys.name, keys.description, keys.type, keys.usage, keys.spec, keys.seed, keys.private_key, keys.public_key, keys.vault_id").Where("keys.vault_id = ?", v.ID)
+	return db.Select("keys.id, keys.created_at, keys.name, keys.description, keys.type, keys.usage, keys.spec, keys.seed, keys.private_key, keys.public_key, keys.vault_id, keys.master_key_id, keys.version, keys.deterministic").Where("keys.vault_id = ?", v.ID)
 }
 
 // ListSecretsQuery returns the fields to SELECT from vault secrets table
 func (v *Vault) ListSecretsQuery(db *gorm.DB) *gorm.DB {
-	return db.Select("secrets.id, secrets.created_at, secrets.vault_id, secrets.name, secrets.value, secrets.description, secrets.type").Where("secrets.vault_id = ?", v.ID)
+	return db.Select("secrets.id, secrets.created_at, secrets.vault_id, secrets.name, secrets.value, secrets.description, secrets.type, secrets.master_key_id").Where("secrets.vault_id = ?", v.ID)
 }
 
 func (v *Vault) resolveMasterKey(db *gorm.DB) (*Key, error) {
@@ -52,6 +52,8 @@ func (v *Vault) resolveMasterKey(db *gorm.DB) (*Key, error) {
 		return nil, fmt.Errorf("failed to resolve master key for vault: %s", v.ID)
 	}
 	masterKey.setEncrypted(true)
+	masterKey.isMaster = true
+	masterKey.vault = v
 
 	v.MasterKey = masterKey
 	v.MasterKeyID = &masterKey.ID
@@ -78,26 +80,8 @@ func (v *Vault) Validate() bool {
 }
 
 func (v *Vault) createMasterKey(tx *gorm.DB) error {
-	masterKey := &Key{
-		VaultID:     &v.ID,
-		Type:        common.StringOrNil(KeyTypeSymmetric),
-		Usage:       common.StringOrNil(KeyUsageEncryptDecrypt),
-		Spec:        common.StringOrNil(KeySpecAES256GCM),
-		Name:        common.StringOrNil(defaultVaultMasterKeyName),
-		Description: common.StringOrNil(fmt.Sprintf("AES-256-GCM master key for vault %s", v.ID)),
-	}
-
-	if !masterKey.createPersisted(tx) {
-		err := fmt.Errorf("failed to create master key for vault: %s; %s", v.ID, *masterKey.Errors[0].Message)
-		return err
-	}
-
-	v.MasterKey = masterKey
-	v.MasterKeyID = &masterKey.ID
-	tx.Save(&v)
-
-	common.Log.Debugf("created master key %s for vault: %s", masterKey.ID, v.ID)
-	return nil
+	_, err := v.createMasterKeyVersion(tx, 0)
+	return err
 }
 
 // Create and persist a vault